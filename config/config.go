@@ -3,12 +3,20 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	// Используется для загрузки переменных окружения из .env файла.
 	_ "github.com/joho/godotenv/autoload"
 )
 
+type LLMParams struct {
+	Model       string
+	Temperature float64
+	MaxTokens   int
+	Timeout     time.Duration
+}
+
 type Config struct {
 	// Postgres
 	PostgresDriverName   string
@@ -26,6 +34,7 @@ type Config struct {
 
 	ServerTimeout time.Duration
 	Port          string
+	OpsPort       string
 
 	// Elasticsearch
 
@@ -43,11 +52,38 @@ type Config struct {
 	// Deepseek
 
 	DeepSeekAPIURL  string
-	DeepSeekAPIKey  string
-	DeepSeekAPIKey2 string
-	DeepSeekAPIKey3 string
-	DeepSeekAPIKey4 string
-	DeepSeekAPIKey5 string
+	DeepSeekAPIKeys []string
+
+	// LLM
+
+	LLMProvider      string
+	LLMAPIURL        string
+	LLMGeneration    LLMParams
+	LLMModernization LLMParams
+	LLMVoice         LLMParams
+
+	LLMRepairAttempts int
+
+	// LLM keys
+
+	LLMKeyMaxConcurrency    int
+	LLMKeyRequestsPerMinute int
+	LLMKeyCooldown          time.Duration
+	LLMKeyWaitTimeout       time.Duration
+	LLMVoiceKeyWaitTimeout  time.Duration
+
+	// Generation jobs
+
+	GenerationWorkers         int
+	GenerationJobPollInterval time.Duration
+	GenerationJobLease        time.Duration
+	GenerationJobMaxAttempts  int
+	GenerationCacheTTL        time.Duration
+
+	// Generation quotas
+
+	GenerationDailyQuota   int
+	GenerationMonthlyQuota int
 
 	// Redis
 
@@ -63,6 +99,53 @@ type Config struct {
 	OauthAppID     string
 	OauthAppKey    string
 	OauthAppSecret string
+
+	// Cooking events
+
+	EventsBufferSize       int
+	EventsKeepAlive        time.Duration
+	TimerSchedulerInterval time.Duration
+
+	// Cooking sessions expiry
+
+	SessionInactivityTTL  time.Duration
+	SessionExpiryInterval time.Duration
+
+	// Cooking sessions sharing
+
+	JoinCodeTTL        time.Duration
+	JoinAttemptsLimit  int
+	JoinAttemptsWindow time.Duration
+
+	// Cooking history
+
+	CookingPhotoMaxSize int
+
+	// Voice
+
+	VoiceClassifierMode string
+	VoiceRulesThreshold float64
+	VoiceAudioMaxSize   int
+
+	// Speech recognition
+
+	SpeechProvider      string
+	SpeechWhisperURL    string
+	SpeechWhisperAPIKey string
+	SpeechWhisperModel  string
+	SpeechLanguage      string
+	SpeechTimeout       time.Duration
+	SpeechFakeText      string
+
+	// Speech synthesis
+
+	TTSProvider string
+	TTSURL      string
+	TTSAPIKey   string
+	TTSModel    string
+	TTSVoice    string
+	TTSFormat   string
+	TTSTimeout  time.Duration
 }
 
 func NewConfig() *Config {
@@ -80,6 +163,7 @@ func NewConfig() *Config {
 
 		ServerTimeout: getEnvTime("SERVER_TIMEOUT", 5*time.Second),
 		Port:          getEnvStr("SERVER_PORT", ":8080"),
+		OpsPort:       getEnvStrOrDefault("OPS_PORT", "127.0.0.1:8081"),
 
 		ElasticsearchAddress:  getEnvStr("ELASTIC_ADDRESS", ""),
 		ElasticsearchUsername: getEnvStr("ELASTIC_USERNAME", ""),
@@ -90,12 +174,37 @@ func NewConfig() *Config {
 		MinioEndpoint: getEnvStr("MINIO_ENDPOINT", ""),
 		MinioBucket:   getEnvStr("MINIO_BUCKET_NAME", ""),
 
-		DeepSeekAPIURL:  getEnvStr("DEEP_SEEK_API_URL", ""),
-		DeepSeekAPIKey:  getEnvStr("DEEP_SEEK_API_KEY", ""),
-		DeepSeekAPIKey2: getEnvStr("DEEP_SEEK_API_KEY_2", ""),
-		DeepSeekAPIKey3: getEnvStr("DEEP_SEEK_API_KEY_3", ""),
-		DeepSeekAPIKey4: getEnvStr("DEEP_SEEK_API_KEY_4", ""),
-		DeepSeekAPIKey5: getEnvStr("DEEP_SEEK_API_KEY_5", ""),
+		DeepSeekAPIURL: getEnvStr("DEEP_SEEK_API_URL", ""),
+		DeepSeekAPIKeys: getAPIKeys("DEEP_SEEK_API_KEYS", "DEEP_SEEK_API_KEY", "DEEP_SEEK_API_KEY_2",
+			"DEEP_SEEK_API_KEY_3", "DEEP_SEEK_API_KEY_4", "DEEP_SEEK_API_KEY_5"),
+
+		LLMProvider: getEnvStr("LLM_PROVIDER", "openai"),
+		LLMAPIURL:   getEnvStr("LLM_API_URL", ""),
+		LLMGeneration: getLLMParams("LLM_GENERATION", LLMParams{
+			Model: "deepseek-chat", Temperature: 1, MaxTokens: 4096, Timeout: 2 * time.Minute,
+		}),
+		LLMModernization: getLLMParams("LLM_MODERNIZATION", LLMParams{
+			Model: "deepseek-chat", Temperature: 1, MaxTokens: 4096, Timeout: 2 * time.Minute,
+		}),
+		LLMVoice: getLLMParams("LLM_VOICE", LLMParams{
+			Model: "deepseek-chat", Temperature: 0, MaxTokens: 8, Timeout: 10 * time.Second,
+		}),
+		LLMRepairAttempts: getEnvInt("LLM_REPAIR_ATTEMPTS", 2),
+
+		LLMKeyMaxConcurrency:    getEnvInt("LLM_KEY_MAX_CONCURRENCY", 1),
+		LLMKeyRequestsPerMinute: getEnvInt("LLM_KEY_REQUESTS_PER_MINUTE", 0),
+		LLMKeyCooldown:          getEnvTime("LLM_KEY_COOLDOWN", 30*time.Second),
+		LLMKeyWaitTimeout:       getEnvTime("LLM_KEY_WAIT_TIMEOUT", 30*time.Second),
+		LLMVoiceKeyWaitTimeout:  getEnvTime("LLM_VOICE_KEY_WAIT_TIMEOUT", time.Second),
+
+		GenerationWorkers:         getEnvInt("GENERATION_WORKERS", 4),
+		GenerationJobPollInterval: getEnvPositiveTime("GENERATION_JOB_POLL_INTERVAL", time.Second),
+		GenerationJobLease:        getEnvTime("GENERATION_JOB_LEASE", 10*time.Minute),
+		GenerationJobMaxAttempts:  getEnvInt("GENERATION_JOB_MAX_ATTEMPTS", 3),
+		GenerationCacheTTL:        getEnvTime("GENERATION_CACHE_TTL", 24*time.Hour),
+
+		GenerationDailyQuota:   getEnvInt("GENERATION_DAILY_QUOTA", 20),
+		GenerationMonthlyQuota: getEnvInt("GENERATION_MONTHLY_QUOTA", 300),
 
 		RedisURL:      getEnvStr("REDIS_URL", ""),
 		RedisPassword: getEnvStr("REDIS_USER_PASSWORD", ""),
@@ -107,7 +216,79 @@ func NewConfig() *Config {
 		OauthAppID:     getEnvStr("OAUTH_APP_ID", ""),
 		OauthAppKey:    getEnvStr("OAUTH_APP_KEY", ""),
 		OauthAppSecret: getEnvStr("OAUTH_APP_SECRET", ""),
+
+		EventsBufferSize:       getEnvInt("EVENTS_BUFFER_SIZE", 16),
+		EventsKeepAlive:        getEnvPositiveTime("EVENTS_KEEP_ALIVE", 15*time.Second),
+		TimerSchedulerInterval: getEnvPositiveTime("TIMER_SCHEDULER_INTERVAL", time.Second),
+
+		SessionInactivityTTL:  getEnvTime("SESSION_INACTIVITY_TTL", 12*time.Hour),
+		SessionExpiryInterval: getEnvPositiveTime("SESSION_EXPIRY_INTERVAL", 10*time.Minute),
+
+		JoinCodeTTL:        getEnvTime("JOIN_CODE_TTL", 15*time.Minute),
+		JoinAttemptsLimit:  getEnvInt("JOIN_ATTEMPTS_LIMIT", 10),
+		JoinAttemptsWindow: getEnvTime("JOIN_ATTEMPTS_WINDOW", 10*time.Minute),
+
+		CookingPhotoMaxSize: getEnvInt("COOKING_PHOTO_MAX_SIZE", 10<<20),
+
+		VoiceClassifierMode: getEnvStr("VOICE_CLASSIFIER_MODE", "rules_first"),
+		VoiceRulesThreshold: getEnvFloat("VOICE_RULES_THRESHOLD", 0.8),
+		VoiceAudioMaxSize:   getEnvInt("VOICE_AUDIO_MAX_SIZE", 10<<20),
+
+		SpeechProvider:      getEnvStr("SPEECH_PROVIDER", "whisper"),
+		SpeechWhisperURL:    getEnvStrOrDefault("SPEECH_WHISPER_URL", "http://whisper:8000/v1/audio/transcriptions"),
+		SpeechWhisperAPIKey: getEnvStr("SPEECH_WHISPER_API_KEY", ""),
+		SpeechWhisperModel:  getEnvStrOrDefault("SPEECH_WHISPER_MODEL", "whisper-1"),
+		SpeechLanguage:      getEnvStrOrDefault("SPEECH_LANGUAGE", "ru"),
+		SpeechTimeout:       getEnvTime("SPEECH_TIMEOUT", 30*time.Second),
+		SpeechFakeText:      getEnvStr("SPEECH_FAKE_TEXT", ""),
+
+		TTSProvider: getEnvStr("TTS_PROVIDER", "http"),
+		TTSURL:      getEnvStrOrDefault("TTS_URL", "http://tts:8000/v1/audio/speech"),
+		TTSAPIKey:   getEnvStr("TTS_API_KEY", ""),
+		TTSModel:    getEnvStrOrDefault("TTS_MODEL", "tts-1"),
+		TTSVoice:    getEnvStrOrDefault("TTS_VOICE", "alloy"),
+		TTSFormat:   getEnvStrOrDefault("TTS_FORMAT", "mp3"),
+		TTSTimeout:  getEnvTime("TTS_TIMEOUT", 30*time.Second),
+	}
+}
+
+func getLLMParams(prefix string, defaultValue LLMParams) LLMParams {
+	model := getEnvStr(prefix+"_MODEL", "")
+	if model == "" {
+		model = defaultValue.Model
+	}
+
+	return LLMParams{
+		Model:       model,
+		Temperature: getEnvFloat(prefix+"_TEMPERATURE", defaultValue.Temperature),
+		MaxTokens:   getEnvInt(prefix+"_MAX_TOKENS", defaultValue.MaxTokens),
+		Timeout:     getEnvTime(prefix+"_TIMEOUT", defaultValue.Timeout),
+	}
+}
+
+// getAPIKeys собирает ключи из списка через запятую в listKey и из отдельных переменных keys
+// без пустых значений и повторов.
+func getAPIKeys(listKey string, keys ...string) []string {
+	values := strings.Split(getEnvStr(listKey, ""), ",")
+
+	for _, key := range keys {
+		values = append(values, getEnvStr(key, ""))
 	}
+
+	apiKeys := make([]string, 0, len(values))
+	seen := make(map[string]bool, len(values))
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+
+		seen[value] = true
+		apiKeys = append(apiKeys, value)
+	}
+
+	return apiKeys
 }
 
 func getEnvStr(key, defaultValue string) string {
@@ -117,6 +298,15 @@ func getEnvStr(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvStrOrDefault возвращает значение по умолчанию и для пустой переменной: docker compose передает
+// незаданные переменные пустыми строками.
+func getEnvStrOrDefault(key, defaultValue string) string {
+	if value := getEnvStr(key, ""); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvTime(key string, defaultValue time.Duration) time.Duration {
 	if value, ok := os.LookupEnv(key); ok {
 		timeout, err := time.ParseDuration(value)
@@ -128,6 +318,26 @@ func getEnvTime(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getEnvPositiveTime возвращает значение по умолчанию и для неположительной длительности. Нужен для
+// интервалов тикеров: time.NewTicker паникует на неположительном интервале.
+func getEnvPositiveTime(key string, defaultValue time.Duration) time.Duration {
+	if value := getEnvTime(key, defaultValue); value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if valueStr, ok := os.LookupEnv(key); ok {
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			return defaultValue
+		}
+		return value
+	}
+	return defaultValue
+}
+
 func getEnvInt(key string, defaultValue int) int {
 	if valueStr, ok := os.LookupEnv(key); ok {
 		value, err := strconv.Atoi(valueStr)
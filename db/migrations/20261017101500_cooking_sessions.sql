@@ -0,0 +1,83 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    ADD COLUMN id BIGSERIAL;
+ALTER TABLE current_recipe
+    ADD COLUMN is_default bool DEFAULT false;
+UPDATE current_recipe SET is_default = true;
+
+ALTER TABLE timers
+    ADD COLUMN session_id BIGINT;
+UPDATE timers AS t SET session_id = cr.id FROM current_recipe AS cr WHERE cr.user_id = t.user_id;
+
+ALTER TABLE current_recipe_step
+    ADD COLUMN session_id BIGINT;
+UPDATE current_recipe_step AS cs SET session_id = cr.id FROM current_recipe AS cr WHERE cr.user_id = cs.user_id;
+
+ALTER TABLE timers DROP CONSTRAINT IF EXISTS timers_user_id_fkey;
+ALTER TABLE timers DROP CONSTRAINT IF EXISTS timers_user_id_step_num_key;
+ALTER TABLE current_recipe_step DROP CONSTRAINT IF EXISTS current_recipe_step_user_id_fkey;
+ALTER TABLE current_recipe DROP CONSTRAINT IF EXISTS current_recipe_user_id_key;
+ALTER TABLE current_recipe DROP CONSTRAINT IF EXISTS current_recipe_pkey;
+
+ALTER TABLE current_recipe
+    ADD PRIMARY KEY (id);
+
+-- у пользователя может быть только одна сессия по умолчанию (старые ручки /recipe)
+CREATE UNIQUE INDEX current_recipe_default_session_idx ON current_recipe (user_id) WHERE is_default;
+CREATE INDEX current_recipe_user_id_idx ON current_recipe (user_id);
+
+ALTER TABLE timers
+    ADD CONSTRAINT timers_session_id_fkey
+        FOREIGN KEY (session_id) REFERENCES current_recipe(id) ON DELETE CASCADE;
+ALTER TABLE timers
+    ADD CONSTRAINT timers_session_id_step_num_key UNIQUE (session_id, step_num);
+
+ALTER TABLE current_recipe_step
+    ADD CONSTRAINT current_recipe_step_session_id_fkey
+        FOREIGN KEY (session_id) REFERENCES current_recipe(id) ON DELETE CASCADE;
+
+ALTER TABLE user_cooking_history
+    ADD COLUMN session_id BIGINT DEFAULT NULL;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_cooking_history
+    DROP COLUMN session_id;
+
+DELETE FROM current_recipe WHERE NOT is_default;
+
+ALTER TABLE current_recipe_step DROP CONSTRAINT current_recipe_step_session_id_fkey;
+ALTER TABLE timers DROP CONSTRAINT timers_session_id_step_num_key;
+ALTER TABLE timers DROP CONSTRAINT timers_session_id_fkey;
+
+DROP INDEX current_recipe_user_id_idx;
+DROP INDEX current_recipe_default_session_idx;
+
+ALTER TABLE current_recipe DROP CONSTRAINT current_recipe_pkey;
+ALTER TABLE current_recipe
+    ADD PRIMARY KEY (user_id);
+ALTER TABLE current_recipe
+    ADD CONSTRAINT current_recipe_user_id_key UNIQUE (user_id);
+
+ALTER TABLE timers
+    ADD CONSTRAINT timers_user_id_step_num_key UNIQUE (user_id, step_num);
+ALTER TABLE timers
+    ADD CONSTRAINT timers_user_id_fkey
+        FOREIGN KEY (user_id) REFERENCES current_recipe(user_id) ON DELETE CASCADE;
+ALTER TABLE current_recipe_step
+    ADD CONSTRAINT current_recipe_step_user_id_fkey
+        FOREIGN KEY (user_id) REFERENCES current_recipe(user_id) ON DELETE CASCADE;
+
+ALTER TABLE current_recipe_step
+    DROP COLUMN session_id;
+ALTER TABLE timers
+    DROP COLUMN session_id;
+ALTER TABLE current_recipe
+    DROP COLUMN is_default;
+ALTER TABLE current_recipe
+    DROP COLUMN id;
+
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE timers
+    ADD COLUMN is_expired bool DEFAULT false;
+
+UPDATE timers SET is_expired = true WHERE end_time <= NOW();
+
+CREATE INDEX timers_not_expired_end_time_idx ON timers (end_time) WHERE NOT is_expired;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX timers_not_expired_end_time_idx;
+
+ALTER TABLE timers
+    DROP COLUMN is_expired;
+-- +goose StatementEnd
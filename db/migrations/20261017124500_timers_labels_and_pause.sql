@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE timers DROP CONSTRAINT IF EXISTS timers_session_id_step_num_key;
+
+ALTER TABLE timers
+    ADD COLUMN label TEXT DEFAULT '';
+ALTER TABLE timers
+    ADD COLUMN is_paused bool DEFAULT false;
+-- сколько секунд оставалось на момент паузы
+ALTER TABLE timers
+    ADD COLUMN remaining_sec INT DEFAULT 0 CHECK (remaining_sec >= 0);
+
+CREATE INDEX timers_session_id_idx ON timers (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX timers_session_id_idx;
+
+DELETE FROM timers AS t USING timers AS t2
+    WHERE t.session_id = t2.session_id AND t.step_num = t2.step_num AND t.timer_id > t2.timer_id;
+
+ALTER TABLE timers
+    DROP COLUMN remaining_sec;
+ALTER TABLE timers
+    DROP COLUMN is_paused;
+ALTER TABLE timers
+    DROP COLUMN label;
+
+ALTER TABLE timers
+    ADD CONSTRAINT timers_session_id_step_num_key UNIQUE (session_id, step_num);
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE current_recipe_step
+    ADD COLUMN is_done bool DEFAULT false;
+
+CREATE INDEX current_recipe_step_session_id_idx ON current_recipe_step (session_id, step_num);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX current_recipe_step_session_id_idx;
+
+ALTER TABLE current_recipe_step
+    DROP COLUMN is_done;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    ADD COLUMN servings INT DEFAULT 0;
+-- ингредиенты рецепта, пересчитанные на servings
+ALTER TABLE current_recipe
+    ADD COLUMN ingredients JSON DEFAULT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    DROP COLUMN ingredients;
+ALTER TABLE current_recipe
+    DROP COLUMN servings;
+-- +goose StatementEnd
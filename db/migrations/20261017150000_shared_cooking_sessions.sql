@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    ADD COLUMN join_code TEXT DEFAULT NULL UNIQUE;
+
+CREATE TABLE IF NOT EXISTS current_recipe_member (
+    session_id BIGINT,
+    user_id INT,
+    joined_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (session_id, user_id),
+    FOREIGN KEY (session_id) REFERENCES current_recipe(id) ON DELETE CASCADE
+);
+
+CREATE INDEX current_recipe_member_user_id_idx ON current_recipe_member (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS current_recipe_member;
+
+ALTER TABLE current_recipe
+    DROP COLUMN join_code;
+-- +goose StatementEnd
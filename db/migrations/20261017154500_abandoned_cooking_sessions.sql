@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    ADD COLUMN last_activity_at TIMESTAMP DEFAULT NOW();
+
+CREATE INDEX current_recipe_last_activity_at_idx ON current_recipe (last_activity_at);
+
+ALTER TABLE user_cooking_history
+    ADD COLUMN status TEXT DEFAULT 'finished';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_cooking_history
+    DROP COLUMN status;
+
+DROP INDEX current_recipe_last_activity_at_idx;
+
+ALTER TABLE current_recipe
+    DROP COLUMN last_activity_at;
+-- +goose StatementEnd
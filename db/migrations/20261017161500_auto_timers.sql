@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    ADD COLUMN auto_timers bool DEFAULT false;
+
+ALTER TABLE timers
+    ADD COLUMN is_auto bool DEFAULT false;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE timers
+    DROP COLUMN is_auto;
+
+ALTER TABLE current_recipe
+    DROP COLUMN auto_timers;
+-- +goose StatementEnd
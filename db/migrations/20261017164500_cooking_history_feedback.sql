@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_cooking_history
+    ADD COLUMN rating int CHECK (rating BETWEEN 1 AND 5),
+    ADD COLUMN notes text,
+    ADD COLUMN photo text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_cooking_history
+    DROP COLUMN photo,
+    DROP COLUMN notes,
+    DROP COLUMN rating;
+-- +goose StatementEnd
@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS current_recipe_prep (
+    session_id BIGINT,
+    item_id TEXT,
+    checked_at TIMESTAMP DEFAULT NOW(),
+    PRIMARY KEY (session_id, item_id),
+    FOREIGN KEY (session_id) REFERENCES current_recipe(id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS current_recipe_prep;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS generation_jobs (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('generation', 'modernization')),
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    query TEXT NOT NULL DEFAULT '',
+    products JSONB NOT NULL DEFAULT '[]',
+    recipe_id BIGINT,
+    version_id INT,
+    result JSONB,
+    error TEXT,
+    attempts INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT NOW(),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX generation_jobs_status_idx ON generation_jobs (status, id);
+CREATE INDEX generation_jobs_user_id_idx ON generation_jobs (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX generation_jobs_user_id_idx;
+DROP INDEX generation_jobs_status_idx;
+DROP TABLE IF EXISTS generation_jobs;
+-- +goose StatementEnd
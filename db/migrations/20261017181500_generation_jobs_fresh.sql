@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE generation_jobs
+    ADD COLUMN fresh BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generation_jobs
+    DROP COLUMN fresh;
+-- +goose StatementEnd
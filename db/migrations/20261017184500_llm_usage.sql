@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS llm_usage (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INT,
+    use_case TEXT NOT NULL,
+    model TEXT NOT NULL DEFAULT '',
+    attempt INT NOT NULL DEFAULT 1,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    latency_ms INT NOT NULL DEFAULT 0,
+    outcome TEXT NOT NULL CHECK (outcome IN ('success', 'error', 'canceled')),
+    error TEXT,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX llm_usage_user_id_created_at_idx ON llm_usage (user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX llm_usage_user_id_created_at_idx;
+DROP TABLE IF EXISTS llm_usage;
+-- +goose StatementEnd
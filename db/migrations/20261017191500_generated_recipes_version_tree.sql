@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE generated_recipes_versions
+    ADD COLUMN parent_version INT;
+
+UPDATE generated_recipes_versions AS v SET parent_version = (
+    SELECT MAX(p.version) FROM generated_recipes_versions AS p WHERE p.id = v.id AND p.version < v.version
+);
+
+ALTER TABLE generated_recipes_versions
+    DROP CONSTRAINT IF EXISTS generated_recipes_versions_id_user_id_version_key;
+ALTER TABLE generated_recipes_versions
+    ADD CONSTRAINT generated_recipes_versions_id_version_key UNIQUE (id, version);
+ALTER TABLE generated_recipes_versions
+    ADD CONSTRAINT generated_recipes_versions_parent_fkey
+        FOREIGN KEY (id, parent_version) REFERENCES generated_recipes_versions (id, version);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE generated_recipes_versions
+    DROP CONSTRAINT IF EXISTS generated_recipes_versions_parent_fkey;
+ALTER TABLE generated_recipes_versions
+    DROP CONSTRAINT IF EXISTS generated_recipes_versions_id_version_key;
+ALTER TABLE generated_recipes_versions
+    ADD CONSTRAINT generated_recipes_versions_id_user_id_version_key UNIQUE (id, user_id, version);
+ALTER TABLE generated_recipes_versions
+    DROP COLUMN parent_version;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE llm_usage
+    ADD COLUMN job_id INT,
+    ADD COLUMN job_attempt INT,
+    ADD COLUMN produced_content BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE llm_usage SET produced_content = TRUE WHERE outcome = 'success';
+
+CREATE INDEX llm_usage_job_id_idx ON llm_usage (job_id) WHERE job_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX llm_usage_job_id_idx;
+
+ALTER TABLE llm_usage
+    DROP COLUMN produced_content,
+    DROP COLUMN job_attempt,
+    DROP COLUMN job_id;
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    ADD COLUMN join_code_expires_at TIMESTAMP;
+
+-- Старые коды выдавались без срока действия, отзываем их.
+UPDATE current_recipe SET join_code = NULL WHERE join_code IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE current_recipe
+    DROP COLUMN join_code_expires_at;
+-- +goose StatementEnd
@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE generation_jobs
+    DROP CONSTRAINT generation_jobs_status_check,
+    ADD CONSTRAINT generation_jobs_status_check
+        CHECK (status IN ('pending', 'running', 'streaming', 'done', 'failed'));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE generation_jobs SET status = 'failed', finished_at = COALESCE(finished_at, NOW())
+WHERE status = 'streaming';
+
+ALTER TABLE generation_jobs
+    DROP CONSTRAINT generation_jobs_status_check,
+    ADD CONSTRAINT generation_jobs_status_check
+        CHECK (status IN ('pending', 'running', 'done', 'failed'));
+-- +goose StatementEnd
@@ -0,0 +1,98 @@
+package broker
+
+import (
+	"sync"
+
+	"github.com/Olegsandrik/Exponenta/config"
+)
+
+type Adapter[T any] struct {
+	mu          sync.RWMutex
+	subscribers map[int]map[chan T]struct{}
+	bufferSize  int
+	closed      bool
+}
+
+func NewBrokerAdapter[T any](cfg *config.Config) *Adapter[T] {
+	return &Adapter[T]{
+		subscribers: make(map[int]map[chan T]struct{}),
+		bufferSize:  cfg.EventsBufferSize,
+	}
+}
+
+func (a *Adapter[T]) Subscribe(topic int) (<-chan T, func()) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	ch := make(chan T, a.bufferSize)
+
+	if a.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if _, ok := a.subscribers[topic]; !ok {
+		a.subscribers[topic] = make(map[chan T]struct{})
+	}
+	a.subscribers[topic][ch] = struct{}{}
+
+	once := sync.Once{}
+	unsubscribe := func() {
+		once.Do(func() {
+			a.mu.Lock()
+			defer a.mu.Unlock()
+
+			if _, ok := a.subscribers[topic][ch]; !ok {
+				return
+			}
+
+			delete(a.subscribers[topic], ch)
+			if len(a.subscribers[topic]) == 0 {
+				delete(a.subscribers, topic)
+			}
+			close(ch)
+		})
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish не блокируется: если подписчик не успевает читать, событие для него отбрасывается.
+func (a *Adapter[T]) Publish(topic int, event T) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for ch := range a.subscribers[topic] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+func (a *Adapter[T]) Topics() []int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	topics := make([]int, 0, len(a.subscribers))
+	for topic := range a.subscribers {
+		topics = append(topics, topic)
+	}
+
+	return topics
+}
+
+func (a *Adapter[T]) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for topic, channels := range a.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+		delete(a.subscribers, topic)
+	}
+	a.closed = true
+
+	return nil
+}
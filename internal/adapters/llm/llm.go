@@ -0,0 +1,392 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Olegsandrik/Exponenta/config"
+)
+
+const (
+	ProviderOpenAI = "openai"
+	ProviderFake   = "fake"
+
+	fakeChunkSize     = 16
+	fakeRunesPerToken = 4
+	maxStreamLine     = 1 << 20
+	streamDataDone    = "[DONE]"
+
+	UseCaseGeneration    = "generation"
+	UseCaseModernization = "modernization"
+	UseCaseVoice         = "voice"
+)
+
+// Request описывает вызов модели. UserID, Attempt, JobID и JobAttempt не уходят в API и нужны для учета
+// использования. JobID равен нулю, если вызов сделан не из задачи генерации.
+type Request struct {
+	UseCase    string
+	Prompt     string
+	Input      string
+	APIKey     string
+	Params     config.LLMParams
+	UserID     uint
+	Attempt    int
+	JobID      int
+	JobAttempt int
+}
+
+// Usage - число токенов запроса и ответа, которое вернул провайдер.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type Response struct {
+	Content string
+	Usage   Usage
+}
+
+// StatusError - ответ API с кодом, отличным от 200. RetryAfter берется из заголовка Retry-After, если он есть.
+type StatusError struct {
+	Code       int
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("status code %d", e.Code)
+}
+
+// OnDelta получает очередной кусок ответа модели. Ошибка из OnDelta прерывает стрим.
+type OnDelta func(delta string) error
+
+// Provider отправляет запрос в языковую модель. Реализации подключаются через LLM_PROVIDER.
+// Stream отдает ответ по мере генерации и возвращает его целиком после завершения, а при ошибке -
+// ту часть, что успела прийти.
+type Provider interface {
+	Complete(ctx context.Context, req Request) (Response, error)
+	Stream(ctx context.Context, req Request, onDelta OnDelta) (Response, error)
+}
+
+func NewProvider(cfg *config.Config) (Provider, error) {
+	switch cfg.LLMProvider {
+	case ProviderOpenAI, "":
+		return NewOpenAIProvider(cfg), nil
+	case ProviderFake:
+		return NewFakeProvider(DefaultFakeResponses()), nil
+	default:
+		return nil, fmt.Errorf("unknown llm provider: %s", cfg.LLMProvider)
+	}
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type streamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type chatReq struct {
+	Model         string         `json:"model"`
+	Messages      []message      `json:"messages"`
+	Temperature   float64        `json:"temperature"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *streamOptions `json:"stream_options,omitempty"`
+}
+
+type chatResp struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// chatChunk - кусок стрима. Usage приходит отдельным последним куском без choices.
+type chatChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *Usage `json:"usage"`
+}
+
+// OpenAIProvider работает с любым API, совместимым с OpenAI /v1/chat/completions:
+// DeepSeek, Ollama, llama.cpp server и т.п.
+type OpenAIProvider struct {
+	client *http.Client
+	url    string
+}
+
+// NewOpenAIProvider по умолчанию ходит в DeepSeek, если LLM_API_URL не задан.
+func NewOpenAIProvider(cfg *config.Config) *OpenAIProvider {
+	url := cfg.LLMAPIURL
+	if url == "" {
+		url = cfg.DeepSeekAPIURL
+	}
+
+	return &OpenAIProvider{
+		client: &http.Client{},
+		url:    url,
+	}
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, req Request) (Response, error) {
+	if req.Params.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Params.Timeout)
+		defer cancel()
+	}
+
+	resp, err := p.do(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var response chatResp
+
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return Response{}, err
+	}
+
+	if len(response.Choices) == 0 {
+		return Response{}, fmt.Errorf("empty choices in response")
+	}
+
+	result := Response{Content: response.Choices[0].Message.Content}
+	if response.Usage != nil {
+		result.Usage = *response.Usage
+	}
+
+	return result, nil
+}
+
+// Stream читает ответ в формате server-sent events: строки "data: {...}" с дельтами и "data: [DONE]" в конце.
+func (p *OpenAIProvider) Stream(ctx context.Context, req Request, onDelta OnDelta) (Response, error) {
+	if req.Params.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, req.Params.Timeout)
+		defer cancel()
+	}
+
+	resp, err := p.do(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var content strings.Builder
+	var usage Usage
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxStreamLine)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+
+		data = strings.TrimSpace(data)
+		if data == streamDataDone {
+			return Response{Content: content.String(), Usage: usage}, nil
+		}
+
+		var chunk chatChunk
+
+		if err = json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Response{Content: content.String(), Usage: usage}, err
+		}
+
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		delta := chunk.Choices[0].Delta.Content
+		content.WriteString(delta)
+
+		if err = onDelta(delta); err != nil {
+			return Response{Content: content.String(), Usage: usage}, err
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return Response{Content: content.String(), Usage: usage}, err
+	}
+
+	return Response{Content: content.String(), Usage: usage}, fmt.Errorf("stream ended without [DONE]")
+}
+
+func (p *OpenAIProvider) do(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	body := chatReq{
+		Model: req.Params.Model,
+		Messages: []message{
+			{Role: "system", Content: req.Prompt},
+			{Role: "user", Content: req.Input},
+		},
+		Temperature: req.Params.Temperature,
+		MaxTokens:   req.Params.MaxTokens,
+		Stream:      stream,
+	}
+
+	if stream {
+		body.StreamOptions = &streamOptions{IncludeUsage: true}
+	}
+
+	reqBytes, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if stream {
+		httpReq.Header.Set("Accept", "text/event-stream")
+	}
+	if req.APIKey != "" {
+		httpReq.Header.Set("Authorization", fmt.Sprintf("Bearer %s", req.APIKey))
+	}
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, newStatusError(resp)
+	}
+
+	return resp, nil
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	statusErr := &StatusError{Code: resp.StatusCode}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+		statusErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return statusErr
+}
+
+// FakeProvider отвечает заранее заданным текстом для каждого сценария и не ходит в сеть.
+// Нужен для тестов и локального запуска. Токены оценивает грубо, по fakeRunesPerToken символов на токен.
+type FakeProvider struct {
+	responses map[string]string
+}
+
+func NewFakeProvider(responses map[string]string) *FakeProvider {
+	return &FakeProvider{responses: responses}
+}
+
+func (p *FakeProvider) Complete(_ context.Context, req Request) (Response, error) {
+	response, ok := p.responses[req.UseCase]
+	if !ok {
+		return Response{}, fmt.Errorf("no fake response for use case: %s", req.UseCase)
+	}
+
+	return Response{
+		Content: response,
+		Usage: Usage{
+			PromptTokens:     fakeTokens(req.Prompt) + fakeTokens(req.Input),
+			CompletionTokens: fakeTokens(response),
+		},
+	}, nil
+}
+
+// Stream отдает заранее заданный ответ кусками по fakeChunkSize символов.
+func (p *FakeProvider) Stream(ctx context.Context, req Request, onDelta OnDelta) (Response, error) {
+	response, err := p.Complete(ctx, req)
+	if err != nil {
+		return Response{}, err
+	}
+
+	runes := []rune(response.Content)
+
+	for start := 0; start < len(runes); start += fakeChunkSize {
+		end := min(start+fakeChunkSize, len(runes))
+
+		if err = onDelta(string(runes[start:end])); err != nil {
+			partial := string(runes[:end])
+
+			return Response{
+				Content: partial,
+				Usage: Usage{
+					PromptTokens:     response.Usage.PromptTokens,
+					CompletionTokens: fakeTokens(partial),
+				},
+			}, err
+		}
+	}
+
+	return response, nil
+}
+
+func fakeTokens(text string) int {
+	return (utf8.RuneCountInString(text) + fakeRunesPerToken - 1) / fakeRunesPerToken
+}
+
+func DefaultFakeResponses() map[string]string {
+	recipe := `{
+		"name": "Омлет",
+		"description": "Простой омлет на завтрак.",
+		"servingsNum": 1,
+		"dishTypes": ["завтрак"],
+		"diets": ["вегетарианская"],
+		"ingredients": [
+			{"id": 1, "name": "яйцо", "amount": 2, "unit": "шт"},
+			{"id": 2, "name": "молоко", "amount": 50, "unit": "мл"},
+			{"id": 3, "name": "соль", "amount": 1, "unit": "г"}
+		],
+		"totalSteps": 2,
+		"readyInMinutes": 10,
+		"steps": [
+			{
+				"number": 1,
+				"step": "Взбейте яйца с молоком и солью.",
+				"ingredients": [
+					{"name": "яйцо", "localizedName": "яйцо"},
+					{"name": "молоко", "localizedName": "молоко"},
+					{"name": "соль", "localizedName": "соль"}
+				],
+				"equipment": [{"name": "миска", "localizedName": "миска"}],
+				"length": {"number": 2, "unit": "minutes"}
+			},
+			{
+				"number": 2,
+				"step": "Жарьте на сковороде под крышкой на среднем огне.",
+				"ingredients": [],
+				"equipment": [{"name": "сковорода", "localizedName": "сковорода"}],
+				"length": {"number": 8, "unit": "minutes"}
+			}
+		]
+	}`
+
+	return map[string]string{
+		UseCaseGeneration:    recipe,
+		UseCaseModernization: recipe,
+		UseCaseVoice:         "0",
+	}
+}
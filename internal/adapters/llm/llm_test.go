@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFakeProviderComplete(t *testing.T) {
+	tests := []struct {
+		name      string
+		responses map[string]string
+		req       Request
+		wantResp  Response
+		wantErr   bool
+	}{
+		{
+			name:      "known use case",
+			responses: map[string]string{UseCaseVoice: "12345"},
+			req:       Request{UseCase: UseCaseVoice, Prompt: "abcd", Input: "привет"},
+			wantResp:  Response{Content: "12345", Usage: Usage{PromptTokens: 3, CompletionTokens: 2}},
+		},
+		{
+			name:      "empty prompt and input",
+			responses: map[string]string{UseCaseGeneration: "{}"},
+			req:       Request{UseCase: UseCaseGeneration},
+			wantResp:  Response{Content: "{}", Usage: Usage{PromptTokens: 0, CompletionTokens: 1}},
+		},
+		{
+			name:      "unknown use case",
+			responses: map[string]string{UseCaseVoice: "0"},
+			req:       Request{UseCase: UseCaseGeneration},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := NewFakeProvider(tt.responses).Complete(context.Background(), tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Complete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if resp != tt.wantResp {
+				t.Errorf("Complete() = %+v, want %+v", resp, tt.wantResp)
+			}
+		})
+	}
+}
+
+func TestFakeProviderStream(t *testing.T) {
+	tests := []struct {
+		name       string
+		response   string
+		wantChunks int
+	}{
+		{name: "shorter than chunk", response: "0", wantChunks: 1},
+		{name: "exactly one chunk", response: strings.Repeat("a", fakeChunkSize), wantChunks: 1},
+		{name: "several chunks", response: strings.Repeat("b", 2*fakeChunkSize+1), wantChunks: 3},
+		{name: "multibyte runes are not split", response: strings.Repeat("щ", fakeChunkSize+3), wantChunks: 2},
+		{name: "empty response", response: "", wantChunks: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := NewFakeProvider(map[string]string{UseCaseGeneration: tt.response})
+
+			var chunks []string
+
+			resp, err := provider.Stream(context.Background(), Request{UseCase: UseCaseGeneration},
+				func(delta string) error {
+					chunks = append(chunks, delta)
+					return nil
+				})
+			if err != nil {
+				t.Fatalf("Stream() error = %v", err)
+			}
+
+			if len(chunks) != tt.wantChunks {
+				t.Errorf("Stream() sent %d chunks, want %d", len(chunks), tt.wantChunks)
+			}
+
+			for _, chunk := range chunks {
+				if !utf8.ValidString(chunk) || utf8.RuneCountInString(chunk) > fakeChunkSize {
+					t.Errorf("Stream() sent invalid chunk %q", chunk)
+				}
+			}
+
+			if joined := strings.Join(chunks, ""); joined != tt.response || resp.Content != tt.response {
+				t.Errorf("Stream() chunks = %q, content = %q, want %q", joined, resp.Content, tt.response)
+			}
+		})
+	}
+}
+
+func TestFakeProviderStreamInterrupted(t *testing.T) {
+	errStop := errors.New("client gone")
+	response := strings.Repeat("я", 3*fakeChunkSize)
+	provider := NewFakeProvider(map[string]string{UseCaseGeneration: response})
+
+	calls := 0
+
+	resp, err := provider.Stream(context.Background(), Request{UseCase: UseCaseGeneration},
+		func(string) error {
+			calls++
+			if calls == 2 {
+				return errStop
+			}
+			return nil
+		})
+
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Stream() error = %v, want %v", err, errStop)
+	}
+
+	wantContent := strings.Repeat("я", 2*fakeChunkSize)
+	if resp.Content != wantContent {
+		t.Errorf("Stream() content = %q, want already sent part %q", resp.Content, wantContent)
+	}
+
+	if resp.Usage.CompletionTokens != fakeTokens(wantContent) {
+		t.Errorf("Stream() completion tokens = %d, want %d", resp.Usage.CompletionTokens, fakeTokens(wantContent))
+	}
+}
@@ -30,3 +30,11 @@ func NewMinioAdapter(cfg *config.Config) (*Adapter, error) {
 func NewEmptyObjectOptions() minio.GetObjectOptions {
 	return minio.GetObjectOptions{}
 }
+
+func NewPutObjectOptions(contentType string) minio.PutObjectOptions {
+	return minio.PutObjectOptions{ContentType: contentType}
+}
+
+func NewEmptyRemoveObjectOptions() minio.RemoveObjectOptions {
+	return minio.RemoveObjectOptions{}
+}
@@ -79,6 +79,40 @@ func (a *Adapter) Set(key string, value uint) error {
 	return nil
 }
 
+func (a *Adapter) GetBytes(key string) ([]byte, error) {
+	conn := a.pool.Get()
+	defer conn.Close()
+	return redis.Bytes(conn.Do("GET", key))
+}
+
+func (a *Adapter) SetBytes(key string, value []byte, ttl time.Duration) error {
+	conn := a.pool.Get()
+	defer conn.Close()
+	_, err := conn.Do("SET", key, value, "PX", ttl.Milliseconds())
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// incrScript увеличивает счетчик и ставит ttl одной командой: если соединение оборвется между INCR и
+// PEXPIRE, счетчик останется без срока жизни. Ttl ставится и счетчику, у которого его почему-то нет.
+var incrScript = redis.NewScript(1, `
+local value = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return value
+`)
+
+// Incr увеличивает счетчик key и возвращает новое значение. Новому счетчику ставится ttl.
+func (a *Adapter) Incr(key string, ttl time.Duration) (int, error) {
+	conn := a.pool.Get()
+	defer conn.Close()
+
+	return redis.Int(incrScript.Do(conn, key, ttl.Milliseconds()))
+}
+
 func (a *Adapter) Delete(key string) error {
 	conn := a.pool.Get()
 	defer conn.Close()
@@ -0,0 +1,154 @@
+package speech
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/Olegsandrik/Exponenta/config"
+)
+
+const (
+	ProviderWhisper = "whisper"
+	ProviderFake    = "fake"
+)
+
+type Audio struct {
+	Data        io.Reader
+	ContentType string
+	Filename    string
+}
+
+// Recognizer переводит аудио в текст. Реализации подключаются через SPEECH_PROVIDER.
+type Recognizer interface {
+	Recognize(ctx context.Context, audio Audio) (string, error)
+}
+
+func NewRecognizer(cfg *config.Config) (Recognizer, error) {
+	switch cfg.SpeechProvider {
+	case ProviderWhisper, "":
+		return NewWhisperRecognizer(cfg), nil
+	case ProviderFake:
+		return NewFakeRecognizer(cfg.SpeechFakeText), nil
+	default:
+		return nil, fmt.Errorf("unknown speech provider: %s", cfg.SpeechProvider)
+	}
+}
+
+// WhisperRecognizer работает с self-hosted сервером, совместимым с OpenAI /v1/audio/transcriptions
+// (faster-whisper-server, whisper.cpp server и т.п.).
+type WhisperRecognizer struct {
+	client   *http.Client
+	url      string
+	apiKey   string
+	model    string
+	language string
+}
+
+func NewWhisperRecognizer(cfg *config.Config) *WhisperRecognizer {
+	return &WhisperRecognizer{
+		client:   &http.Client{Timeout: cfg.SpeechTimeout},
+		url:      cfg.SpeechWhisperURL,
+		apiKey:   cfg.SpeechWhisperAPIKey,
+		model:    cfg.SpeechWhisperModel,
+		language: cfg.SpeechLanguage,
+	}
+}
+
+type whisperResp struct {
+	Text string `json:"text"`
+}
+
+func (r *WhisperRecognizer) Recognize(ctx context.Context, audio Audio) (string, error) {
+	body, contentType := r.buildBody(audio)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, body)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	if r.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", r.apiKey))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	var transcription whisperResp
+
+	if err = json.NewDecoder(resp.Body).Decode(&transcription); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(transcription.Text), nil
+}
+
+// buildBody отдает multipart-тело потоком, чтобы не держать аудио в памяти целиком.
+func (r *WhisperRecognizer) buildBody(audio Audio) (io.Reader, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+
+	go func() {
+		pw.CloseWithError(r.writeBody(writer, audio))
+	}()
+
+	return pr, writer.FormDataContentType()
+}
+
+func (r *WhisperRecognizer) writeBody(writer *multipart.Writer, audio Audio) error {
+	fields := map[string]string{
+		"model":           r.model,
+		"language":        r.language,
+		"response_format": "json",
+	}
+
+	for name, value := range fields {
+		if value == "" {
+			continue
+		}
+		if err := writer.WriteField(name, value); err != nil {
+			return err
+		}
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, audio.Filename))
+	header.Set("Content-Type", audio.ContentType)
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(part, audio.Data); err != nil {
+		return err
+	}
+
+	return writer.Close()
+}
+
+// FakeRecognizer всегда возвращает заданный текст, не читая аудио. Нужен для тестов и локального запуска.
+type FakeRecognizer struct {
+	text string
+}
+
+func NewFakeRecognizer(text string) *FakeRecognizer {
+	return &FakeRecognizer{text: text}
+}
+
+func (r *FakeRecognizer) Recognize(_ context.Context, _ Audio) (string, error) {
+	return r.text, nil
+}
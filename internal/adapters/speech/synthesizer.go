@@ -0,0 +1,160 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Olegsandrik/Exponenta/config"
+)
+
+const (
+	SynthesizerHTTP = "http"
+
+	fakeSampleRate = 16000
+)
+
+type SynthesizedAudio struct {
+	Data        []byte
+	ContentType string
+}
+
+// Synthesizer озвучивает текст. Реализации подключаются через TTS_PROVIDER.
+type Synthesizer interface {
+	Synthesize(ctx context.Context, text string) (SynthesizedAudio, error)
+}
+
+func NewSynthesizer(cfg *config.Config) (Synthesizer, error) {
+	switch cfg.TTSProvider {
+	case SynthesizerHTTP, "":
+		return NewHTTPSynthesizer(cfg), nil
+	case ProviderFake:
+		return NewFakeSynthesizer(), nil
+	default:
+		return nil, fmt.Errorf("unknown tts provider: %s", cfg.TTSProvider)
+	}
+}
+
+func GetSpeechContentType(format string) string {
+	switch format {
+	case "mp3":
+		return "audio/mpeg"
+	case "wav":
+		return "audio/wav"
+	case "opus":
+		return "audio/ogg"
+	case "aac":
+		return "audio/aac"
+	case "flac":
+		return "audio/flac"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// HTTPSynthesizer работает с сервером, совместимым с OpenAI /v1/audio/speech (openedai-speech, Piper и т.п.).
+type HTTPSynthesizer struct {
+	client *http.Client
+	url    string
+	apiKey string
+	model  string
+	voice  string
+	format string
+}
+
+func NewHTTPSynthesizer(cfg *config.Config) *HTTPSynthesizer {
+	return &HTTPSynthesizer{
+		client: &http.Client{Timeout: cfg.TTSTimeout},
+		url:    cfg.TTSURL,
+		apiKey: cfg.TTSAPIKey,
+		model:  cfg.TTSModel,
+		voice:  cfg.TTSVoice,
+		format: cfg.TTSFormat,
+	}
+}
+
+type speechReq struct {
+	Model          string `json:"model"`
+	Input          string `json:"input"`
+	Voice          string `json:"voice"`
+	ResponseFormat string `json:"response_format"`
+}
+
+func (s *HTTPSynthesizer) Synthesize(ctx context.Context, text string) (SynthesizedAudio, error) {
+	reqBytes, err := json.Marshal(speechReq{
+		Model:          s.model,
+		Input:          text,
+		Voice:          s.voice,
+		ResponseFormat: s.format,
+	})
+	if err != nil {
+		return SynthesizedAudio{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return SynthesizedAudio{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if s.apiKey != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", s.apiKey))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return SynthesizedAudio{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return SynthesizedAudio{}, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return SynthesizedAudio{}, err
+	}
+
+	if len(data) == 0 {
+		return SynthesizedAudio{}, fmt.Errorf("empty audio")
+	}
+
+	return SynthesizedAudio{Data: data, ContentType: GetSpeechContentType(s.format)}, nil
+}
+
+// FakeSynthesizer возвращает тишину в WAV, длина которой зависит от длины текста.
+// Нужен для тестов и локального запуска.
+type FakeSynthesizer struct{}
+
+func NewFakeSynthesizer() *FakeSynthesizer {
+	return &FakeSynthesizer{}
+}
+
+func (s *FakeSynthesizer) Synthesize(_ context.Context, text string) (SynthesizedAudio, error) {
+	samples := len([]rune(text)) * fakeSampleRate / 100
+	dataSize := uint32(samples * 2)
+
+	buf := bytes.NewBuffer(make([]byte, 0, 44+dataSize))
+
+	header := []interface{}{
+		[4]byte{'R', 'I', 'F', 'F'}, 36 + dataSize, [4]byte{'W', 'A', 'V', 'E'},
+		[4]byte{'f', 'm', 't', ' '}, uint32(16), uint16(1), uint16(1),
+		uint32(fakeSampleRate), uint32(fakeSampleRate * 2), uint16(2), uint16(16),
+		[4]byte{'d', 'a', 't', 'a'}, dataSize,
+	}
+
+	for _, field := range header {
+		if err := binary.Write(buf, binary.LittleEndian, field); err != nil {
+			return SynthesizedAudio{}, err
+		}
+	}
+
+	buf.Write(make([]byte, dataSize))
+
+	return SynthesizedAudio{Data: buf.Bytes(), ContentType: "audio/wav"}, nil
+}
@@ -12,11 +12,15 @@ import (
 	"time"
 
 	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/broker"
 	"github.com/Olegsandrik/Exponenta/internal/adapters/elasticsearch"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
 	"github.com/Olegsandrik/Exponenta/internal/adapters/minio"
 	"github.com/Olegsandrik/Exponenta/internal/adapters/postgres"
 	"github.com/Olegsandrik/Exponenta/internal/adapters/redis"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/speech"
 	"github.com/Olegsandrik/Exponenta/internal/delivery"
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
 	"github.com/Olegsandrik/Exponenta/internal/middleware"
 	"github.com/Olegsandrik/Exponenta/internal/repository"
 	"github.com/Olegsandrik/Exponenta/internal/usecase"
@@ -29,10 +33,11 @@ const (
 )
 
 type App struct {
-	router  *mux.Router
-	server  *http.Server
-	logger  *slog.Logger
-	closers []io.Closer
+	router    *mux.Router
+	server    *http.Server
+	opsServer *http.Server
+	logger    *slog.Logger
+	closers   []io.Closer
 }
 
 func (app *App) StartServer() error {
@@ -43,6 +48,14 @@ func (app *App) StopServer(ctx context.Context) error {
 	return app.server.Shutdown(ctx)
 }
 
+func (app *App) StartOpsServer() error {
+	return app.opsServer.ListenAndServe()
+}
+
+func (app *App) StopOpsServer(ctx context.Context) error {
+	return app.opsServer.Shutdown(ctx)
+}
+
 func InitServer(router *mux.Router, config *config.Config) *http.Server {
 	return &http.Server{
 		Addr:         config.Port,
@@ -52,6 +65,16 @@ func InitServer(router *mux.Router, config *config.Config) *http.Server {
 	}
 }
 
+// InitOpsServer поднимает внутренний сервер для служебных ручек на отдельном адресе, который не публикуется наружу.
+func InitOpsServer(router *mux.Router, config *config.Config) *http.Server {
+	return &http.Server{
+		Addr:         config.OpsPort,
+		Handler:      router,
+		WriteTimeout: config.ServerTimeout,
+		ReadTimeout:  config.ServerTimeout,
+	}
+}
+
 func InitApp() *App {
 	cfg := config.NewConfig()
 
@@ -93,6 +116,10 @@ func InitApp() *App {
 		panic(err)
 	}
 
+	// Cooking events
+
+	eventsBroker := broker.NewBrokerAdapter[dto.CookingEventDto](cfg)
+
 	// Router
 
 	r := mux.NewRouter()
@@ -108,6 +135,13 @@ func InitApp() *App {
 
 	server := InitServer(r, cfg)
 
+	// Стримы событий не завершаются сами, закрываем их до ожидания активных соединений.
+	server.RegisterOnShutdown(func() {
+		if err := eventsBroker.Close(); err != nil {
+			logger.Error("Error closing events broker: " + err.Error())
+		}
+	})
+
 	// Favorite
 
 	favoriteRecipeRepo := repository.NewFavoriteRecipeRepository(postgresAdapter)
@@ -125,17 +159,54 @@ func InitApp() *App {
 	// Cooking recipe
 
 	cookingRecipeRepo := repository.NewCookingRecipeRepo(postgresAdapter)
-	cookingRecipeUsecase := usecase.NewCookingRecipeUsecase(cookingRecipeRepo, favoriteRecipeRepo)
-	cookingRecipeHandler := delivery.NewCookingRecipeHandler(cookingRecipeUsecase)
+	joinAttemptRepo := repository.NewJoinAttemptRepo(redisAdapter, cfg)
+	cookingRecipeUsecase := usecase.NewCookingRecipeUsecase(cookingRecipeRepo, favoriteRecipeRepo, eventsBroker,
+		imageRepo, joinAttemptRepo, cfg)
+	cookingRecipeHandler := delivery.NewCookingRecipeHandler(cookingRecipeUsecase, cfg)
 	cookingRecipeHandler.InitRouter(apiRouter)
 
+	timerScheduler := usecase.NewTimerScheduler(cookingRecipeRepo, eventsBroker, cfg)
+	timerScheduler.Start()
+
+	sessionExpirer := usecase.NewSessionExpirer(cookingRecipeRepo, eventsBroker, cfg)
+	sessionExpirer.Start()
+
+	// LLM
+
+	rawLLMProvider, err := llm.NewProvider(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	llmUsageRepo := repository.NewLLMUsageRepo(postgresAdapter)
+	llmProvider := repository.NewMeteredProvider(rawLLMProvider, llmUsageRepo)
+	llmKeyPool := repository.NewKeyPool(cfg)
+
 	// Generation recipe
 
-	generationRecipeRepo := repository.NewGeneratedRecipeRepo(postgresAdapter, cfg)
-	generationRecipeUsecase := usecase.NewGenerateUsecase(generationRecipeRepo, cookingRecipeRepo)
+	generationRecipeRepo := repository.NewGeneratedRecipeRepo(postgresAdapter, redisAdapter, cfg, llmProvider,
+		llmKeyPool)
+	generationJobRepo := repository.NewGenerationJobRepo(postgresAdapter)
+
+	generationWorkerPool := usecase.NewGenerationWorkerPool(generationJobRepo, generationRecipeRepo, cfg)
+	generationWorkerPool.Start()
+
+	generationRecipeUsecase := usecase.NewGenerateUsecase(generationRecipeRepo, cookingRecipeRepo,
+		generationJobRepo, llmUsageRepo, generationWorkerPool, cfg)
 	generationRecipeHandler := delivery.NewGeneratedHandler(generationRecipeUsecase)
 	generationRecipeHandler.InitRouter(apiRouter)
 
+	// Ops
+
+	opsRouter := mux.NewRouter()
+	opsHandler := delivery.NewOpsHandler(generationRecipeUsecase)
+	opsHandler.InitRouter(opsRouter)
+
+	opsRouter.Use(middleware.PanicMiddleware)
+	opsRouter.Use(middleware.LoggingMiddleware)
+
+	opsServer := InitOpsServer(opsRouter, cfg)
+
 	// Search
 
 	searchRepo := repository.NewSearchRepository(elasticsearchAdapter, postgresAdapter)
@@ -145,7 +216,20 @@ func InitApp() *App {
 
 	// Voice
 
-	voiceHandler := delivery.NewVoiceHandler(cfg)
+	speechRecognizer, err := speech.NewRecognizer(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	speechSynthesizer, err := speech.NewSynthesizer(cfg)
+	if err != nil {
+		panic(err)
+	}
+
+	voiceRepo := repository.NewVoiceRepo(cfg, llmProvider, llmKeyPool, speechRecognizer)
+	speechRepo := repository.NewSpeechRepo(minioAdapter, speechSynthesizer, cfg)
+	voiceUsecase := usecase.NewVoiceUsecase(voiceRepo, speechRepo, cookingRecipeUsecase, cfg)
+	voiceHandler := delivery.NewVoiceHandler(voiceUsecase, cfg)
 	voiceHandler.InitRouter(apiRouter)
 
 	// Auth and Profile
@@ -178,12 +262,13 @@ func InitApp() *App {
 	r.Use(middleware.LoggingMiddleware)
 	r.Use(middleware.NewAuthMiddleware(userRepo))
 
-	closers := []io.Closer{postgresAdapter}
+	closers := []io.Closer{timerScheduler, sessionExpirer, generationWorkerPool, postgresAdapter}
 	return &App{
-		router:  r,
-		server:  server,
-		closers: closers,
-		logger:  logger,
+		router:    r,
+		server:    server,
+		opsServer: opsServer,
+		closers:   closers,
+		logger:    logger,
 	}
 }
 
@@ -195,6 +280,13 @@ func (app *App) Start() {
 		}
 	}()
 
+	go func() {
+		app.logger.Info("Ops server is running...")
+		if err := app.StartOpsServer(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			app.logger.Error("Ops server ListenAndServe error: " + err.Error())
+		}
+	}()
+
 	exit := make(chan os.Signal, 1)
 	signal.Notify(exit, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
@@ -208,6 +300,10 @@ func (app *App) Start() {
 		app.logger.Error("HTTP server shutdown error: " + err.Error())
 	}
 
+	if err := app.StopOpsServer(ctx); err != nil {
+		app.logger.Error("Ops server shutdown error: " + err.Error())
+	}
+
 	for _, closer := range app.closers {
 		if err := closer.Close(); err != nil {
 			app.logger.Error("Error closing resource: " + err.Error())
@@ -0,0 +1,62 @@
+package dto
+
+type IntChangeDto struct {
+	From int `json:"from"`
+	To   int `json:"to"`
+}
+
+type StringChangeDto struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type ListChangeDto struct {
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+}
+
+type IngredientDiffDto struct {
+	Name       string  `json:"name"`
+	FromAmount float64 `json:"fromAmount,omitempty"`
+	FromUnit   string  `json:"fromUnit,omitempty"`
+	ToAmount   float64 `json:"toAmount,omitempty"`
+	ToUnit     string  `json:"toUnit,omitempty"`
+}
+
+type IngredientsDiffDto struct {
+	Added   []IngredientDiffDto `json:"added"`
+	Removed []IngredientDiffDto `json:"removed"`
+	Changed []IngredientDiffDto `json:"changed"`
+}
+
+type TextDiffOpDto struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type StepDiffDto struct {
+	FromNumber int             `json:"fromNumber,omitempty"`
+	ToNumber   int             `json:"toNumber,omitempty"`
+	FromStep   string          `json:"fromStep,omitempty"`
+	ToStep     string          `json:"toStep,omitempty"`
+	TextDiff   []TextDiffOpDto `json:"textDiff,omitempty"`
+}
+
+type StepsDiffDto struct {
+	Inserted []StepDiffDto `json:"inserted"`
+	Removed  []StepDiffDto `json:"removed"`
+	Edited   []StepDiffDto `json:"edited"`
+}
+
+type RecipeDiffDto struct {
+	RecipeID       int                `json:"recipeId"`
+	FromVersion    int                `json:"fromVersion"`
+	ToVersion      int                `json:"toVersion"`
+	Name           *StringChangeDto   `json:"name,omitempty"`
+	Servings       *IntChangeDto      `json:"servings,omitempty"`
+	ReadyInMinutes *IntChangeDto      `json:"readyInMinutes,omitempty"`
+	Diets          ListChangeDto      `json:"diets"`
+	DishTypes      ListChangeDto      `json:"dishTypes"`
+	Ingredients    IngredientsDiffDto `json:"ingredients"`
+	Steps          StepsDiffDto       `json:"steps"`
+}
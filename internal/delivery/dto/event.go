@@ -0,0 +1,31 @@
+package dto
+
+const (
+	EventStepChanged   = "step_changed"
+	EventStepChecked   = "step_checked"
+	EventPrepChecked   = "prep_checked"
+	EventTimerCreated  = "timer_created"
+	EventTimerDeleted  = "timer_deleted"
+	EventTimerPaused   = "timer_paused"
+	EventTimerResumed  = "timer_resumed"
+	EventTimerExtended = "timer_extended"
+	EventTimerTick     = "timer_tick"
+	EventTimerExpired  = "timer_expired"
+	EventSessionEnded  = "session_ended"
+	EventMemberJoined  = "member_joined"
+	EventMemberLeft    = "member_left"
+
+	EventGenerationChunk  = "chunk"
+	EventGenerationRecipe = "recipe"
+	EventGenerationError  = "error"
+)
+
+type CookingEventDto struct {
+	Type      string      `json:"type"`
+	SessionID int         `json:"sessionId"`
+	Data      interface{} `json:"data,omitempty"`
+}
+
+type SessionMemberDto struct {
+	UserID uint `json:"userId"`
+}
@@ -0,0 +1,34 @@
+package dto
+
+import "time"
+
+type LLMKeyStatsDto struct {
+	Key                string     `json:"key"`
+	Healthy            bool       `json:"healthy"`
+	InFlight           int        `json:"inFlight"`
+	RequestsLastMinute int        `json:"requestsLastMinute"`
+	TotalRequests      int64      `json:"totalRequests"`
+	TotalErrors        int64      `json:"totalErrors"`
+	RateLimited        int64      `json:"rateLimited"`
+	CooldownUntil      *time.Time `json:"cooldownUntil,omitempty"`
+	LastError          string     `json:"lastError,omitempty"`
+	LastErrorAt        *time.Time `json:"lastErrorAt,omitempty"`
+}
+
+type LLMKeysStatsDto struct {
+	Waiting int              `json:"waiting"`
+	Keys    []LLMKeyStatsDto `json:"keys"`
+}
+
+type GenerationQuotaPeriodDto struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	Unlimited bool      `json:"unlimited"`
+	ResetsAt  time.Time `json:"resetsAt"`
+}
+
+type GenerationQuotaDto struct {
+	Daily   GenerationQuotaPeriodDto `json:"daily"`
+	Monthly GenerationQuotaPeriodDto `json:"monthly"`
+}
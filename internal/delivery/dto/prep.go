@@ -0,0 +1,43 @@
+package dto
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+type PrepItemDto struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Image   string  `json:"image,omitempty"`
+	Amount  float64 `json:"amount,omitempty"`
+	Unit    string  `json:"unit,omitempty"`
+	Steps   []int   `json:"steps,omitempty"`
+	Checked bool    `json:"checked"`
+}
+
+type PrepChecklistDto struct {
+	SessionID   int           `json:"sessionId,omitempty"`
+	RecipeID    int           `json:"recipeId,omitempty"`
+	Name        string        `json:"name,omitempty"`
+	IsGenerated bool          `json:"isGenerated,omitempty"`
+	Ingredients []PrepItemDto `json:"ingredients"`
+	Equipment   []PrepItemDto `json:"equipment"`
+	Checked     int           `json:"checked"`
+	Total       int           `json:"total"`
+}
+
+type PrepItemDataDto struct {
+	ID string `json:"id"`
+}
+
+func GetPrepItemData(r *http.Request) (PrepItemDataDto, error) {
+	var item PrepItemDataDto
+
+	err := json.NewDecoder(r.Body).Decode(&item)
+
+	if err != nil {
+		return PrepItemDataDto{}, err
+	}
+
+	return item, nil
+}
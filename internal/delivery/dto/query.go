@@ -9,6 +9,12 @@ import (
 	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 )
 
+// DefaultSessionID обозначает сессию готовки по умолчанию (старые ручки /recipe без id сессии).
+const DefaultSessionID = 0
+
+// multipartFormOverhead - запас на текстовые поля и заголовки частей multipart-формы сверх размера файла.
+const multipartFormOverhead = 1 << 20
+
 func GetIntQueryParam(r *http.Request, name string) (int, error) {
 	paramStr := r.URL.Query().Get(name)
 	if paramStr == "" {
@@ -44,3 +50,20 @@ func GetStringQueryParam(r *http.Request, name string) (string, error) {
 	}
 	return dishTypeParam, nil
 }
+
+func GetSessionIDURLParam(r *http.Request, name string) (int, error) {
+	if _, ok := mux.Vars(r)[name]; !ok {
+		return DefaultSessionID, nil
+	}
+
+	sessionID, err := GetIntURLParam(r, name)
+	if err != nil {
+		return 0, err
+	}
+
+	if sessionID <= 0 {
+		return 0, internalErrors.ErrParamNotInteger
+	}
+
+	return sessionID, nil
+}
@@ -2,8 +2,14 @@ package dto
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
+
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 )
 
 type RecipeDto struct {
@@ -24,14 +30,60 @@ type RecipeDto struct {
 	IsFavorite      bool            `json:"isFavorite,omitempty"`
 	IsGenerated     bool            `json:"isGenerated,omitempty"`
 	CreatedAt       *time.Time      `json:"createdAt,omitempty"`
+	SessionID       int             `json:"sessionId,omitempty"`
+	Status          string          `json:"status,omitempty"`
+	Rating          int             `json:"rating,omitempty"`
+	Notes           string          `json:"notes,omitempty"`
+	Photo           string          `json:"photo,omitempty"`
+}
+
+// RecipeVersionNodeDto - версия сгенерированного рецепта в дереве истории, Children - версии, полученные из нее.
+type RecipeVersionNodeDto struct {
+	RecipeDto
+	ParentVersion int                    `json:"parentVersion,omitempty"`
+	Children      []RecipeVersionNodeDto `json:"children"`
 }
 
 type CurrentRecipeDto struct {
+	SessionID   int                  `json:"sessionId,omitempty"`
+	IsDefault   bool                 `json:"isDefault,omitempty"`
 	ID          int                  `json:"id,omitempty"`
 	Name        string               `json:"name,omitempty"`
 	TotalSteps  int                  `json:"totalSteps,omitempty"`
 	IsGenerated bool                 `json:"isGenerated,omitempty"`
+	ServingsNum int                  `json:"servingsNum,omitempty"`
+	Ingredients json.RawMessage      `json:"ingredients,omitempty"`
+	JoinCode    string               `json:"joinCode,omitempty"`
+	IsGuest     bool                 `json:"isGuest,omitempty"`
+	AutoTimers  bool                 `json:"autoTimers,omitempty"`
 	CurrentStep CurrentStepRecipeDto `json:"currentStep,omitempty"`
+	Progress    *CookingProgressDto  `json:"progress,omitempty"`
+}
+
+type CookingProgressDto struct {
+	Done      int   `json:"done"`
+	Total     int   `json:"total"`
+	Percent   int   `json:"percent"`
+	DoneSteps []int `json:"doneSteps"`
+}
+
+type AutoTimersDataDto struct {
+	Enabled bool `json:"enabled"`
+}
+
+type CookingFeedbackDto struct {
+	Rating int    `json:"rating"`
+	Notes  string `json:"notes"`
+	Photo  *Image `json:"-"`
+}
+
+type JoinCodeDto struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type StepRecipeDataDto struct {
+	StepNum int `json:"step"`
 }
 
 type CurrentStepRecipeDto struct {
@@ -43,19 +95,47 @@ type CurrentStepRecipeDto struct {
 }
 
 type TimerRecipeDto struct {
-	Length  json.RawMessage `json:"length,omitempty"`
-	Step    string          `json:"step,omitempty"`
-	StepNum int             `json:"stepNum,omitempty"`
+	ID       int             `json:"id,omitempty"`
+	Label    string          `json:"label,omitempty"`
+	IsPaused bool            `json:"isPaused,omitempty"`
+	IsAuto   bool            `json:"isAuto,omitempty"`
+	Length   json.RawMessage `json:"length,omitempty"`
+	Step     string          `json:"step,omitempty"`
+	StepNum  int             `json:"stepNum,omitempty"`
 }
 
 type TimerRecipeDataDto struct {
-	Time    int `json:"length"`
-	StepNum int `json:"step"`
+	ID      int    `json:"id"`
+	Label   string `json:"label"`
+	Time    int    `json:"length"`
+	StepNum int    `json:"step"`
 }
 
 type GenerationRecipeDto struct {
 	Query       string   `json:"query"`
 	Ingredients []string `json:"ingredients"`
+	Fresh       bool     `json:"fresh"`
+}
+
+type GenerationJobDto struct {
+	ID         int             `json:"id"`
+	Kind       string          `json:"kind"`
+	Status     string          `json:"status"`
+	Query      string          `json:"query,omitempty"`
+	Products   []string        `json:"products,omitempty"`
+	Fresh      bool            `json:"fresh,omitempty"`
+	RecipeID   int             `json:"recipeId,omitempty"`
+	VersionID  int             `json:"versionId,omitempty"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	CreatedAt  time.Time       `json:"createdAt"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+}
+
+type GenerationChunkDto struct {
+	Attempt int    `json:"attempt"`
+	Delta   string `json:"delta"`
 }
 
 type RecipePage struct {
@@ -87,6 +167,122 @@ func GetGenerationData(r *http.Request) (GenerationRecipeDto, error) {
 	return generateDTO, nil
 }
 
+func GetAutoTimersData(r *http.Request) (AutoTimersDataDto, error) {
+	var autoTimers AutoTimersDataDto
+
+	err := json.NewDecoder(r.Body).Decode(&autoTimers)
+
+	if err != nil {
+		return AutoTimersDataDto{}, err
+	}
+
+	return autoTimers, nil
+}
+
+func GetCookingFeedbackData(w http.ResponseWriter, r *http.Request, maxPhotoSize int64) (CookingFeedbackDto, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return getCookingFeedbackForm(w, r, maxPhotoSize)
+	}
+
+	var feedback CookingFeedbackDto
+
+	err := json.NewDecoder(r.Body).Decode(&feedback)
+
+	if errors.Is(err, io.EOF) {
+		return CookingFeedbackDto{}, nil
+	}
+
+	if err != nil {
+		return CookingFeedbackDto{}, err
+	}
+
+	return feedback, nil
+}
+
+func getCookingFeedbackForm(w http.ResponseWriter, r *http.Request, maxPhotoSize int64) (CookingFeedbackDto, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxPhotoSize+multipartFormOverhead)
+
+	err := r.ParseMultipartForm(maxPhotoSize)
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return CookingFeedbackDto{}, internalErrors.ErrInvalidImage
+	}
+
+	if err != nil {
+		return CookingFeedbackDto{}, err
+	}
+
+	var feedback CookingFeedbackDto
+
+	if rating := r.FormValue("rating"); rating != "" {
+		feedback.Rating, err = strconv.Atoi(rating)
+		if err != nil {
+			return CookingFeedbackDto{}, internalErrors.ErrInvalidRating
+		}
+	}
+
+	feedback.Notes = r.FormValue("notes")
+
+	file, header, err := r.FormFile("photo")
+	if errors.Is(err, http.ErrMissingFile) {
+		return feedback, nil
+	}
+
+	if err != nil {
+		return CookingFeedbackDto{}, err
+	}
+
+	if header.Size > maxPhotoSize {
+		file.Close()
+		return CookingFeedbackDto{}, internalErrors.ErrInvalidImage
+	}
+
+	head := make([]byte, 512)
+	n, err := file.Read(head)
+	if err != nil && !errors.Is(err, io.EOF) {
+		file.Close()
+		return CookingFeedbackDto{}, err
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return CookingFeedbackDto{}, err
+	}
+
+	feedback.Photo = &Image{
+		ImageSize:   header.Size,
+		Image:       file,
+		ContentType: http.DetectContentType(head[:n]),
+	}
+
+	return feedback, nil
+}
+
+func GetJoinCodeData(r *http.Request) (JoinCodeDto, error) {
+	var joinCode JoinCodeDto
+
+	err := json.NewDecoder(r.Body).Decode(&joinCode)
+
+	if err != nil {
+		return JoinCodeDto{}, err
+	}
+
+	return joinCode, nil
+}
+
+func GetStepRecipeData(r *http.Request) (StepRecipeDataDto, error) {
+	var step StepRecipeDataDto
+
+	err := json.NewDecoder(r.Body).Decode(&step)
+
+	if err != nil {
+		return StepRecipeDataDto{}, err
+	}
+
+	return step, nil
+}
+
 func GetTimerRecipeData(r *http.Request) (TimerRecipeDataDto, error) {
 	var timer TimerRecipeDataDto
 
@@ -1,25 +1,99 @@
 package dto
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
+	"mime"
 	"net/http"
-	"strconv"
+	"strings"
+
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+)
+
+const (
+	VoiceActionUnknown    = "unknown"
+	VoiceActionNextStep   = "next_step"
+	VoiceActionPrevStep   = "prev_step"
+	VoiceActionEndCooking = "end_cooking"
+	VoiceActionStopTimer  = "stop_timer"
+	VoiceActionStartTimer = "start_timer"
+	VoiceActionGetTimers  = "get_timers"
+	VoiceActionGoToStep   = "go_to_step"
+	VoiceActionRepeatStep = "repeat_step"
+
+	VoiceActionAnswerQuestion = "answer_question"
 )
 
 type VoiceDto struct {
-	Text string `json:"text"`
+	Text  string         `json:"text"`
+	Audio *VoiceAudioDto `json:"-"`
+}
+
+type VoiceAudioDto struct {
+	Audio       io.Reader
+	Size        int64
+	ContentType string
+	Filename    string
+}
+
+type VoiceSlotsDto struct {
+	DurationSec int    `json:"durationSec,omitempty"`
+	StepNum     int    `json:"step,omitempty"`
+	Label       string `json:"label,omitempty"`
+}
+
+type VoiceCommandDto struct {
+	Intent     int           `json:"intent"`
+	Action     string        `json:"action"`
+	Source     string        `json:"source"`
+	Confidence float64       `json:"confidence"`
+	Text       string        `json:"text"`
+	Slots      VoiceSlotsDto `json:"slots"`
+	Result     interface{}   `json:"result,omitempty"`
+}
+
+type SpeechDto struct {
+	Audio       io.ReadSeeker
+	Size        int64
+	ContentType string
+	Name        string
+}
+
+type VoiceAnswerDto struct {
+	Answer  string `json:"answer"`
+	StepNum int    `json:"step,omitempty"`
 }
 
-type DeepSeekAPIResp struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+// HasVoiceAudio сообщает, что запрос может нести аудио: multipart-форму или тело с аудио.
+func HasVoiceAudio(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	_, _, ok := getAudioType(mediaType)
+	return ok
 }
 
-func GetVoiceData(r *http.Request) (VoiceDto, error) {
+func GetVoiceData(w http.ResponseWriter, r *http.Request, maxAudioSize int64) (VoiceDto, error) {
+	contentType := r.Header.Get("Content-Type")
+
+	if strings.HasPrefix(contentType, "multipart/form-data") {
+		return getVoiceForm(w, r, maxAudioSize)
+	}
+
+	if HasVoiceAudio(r) {
+		return getVoiceBody(r, maxAudioSize)
+	}
+
 	var voice VoiceDto
 
 	err := json.NewDecoder(r.Body).Decode(&voice)
@@ -31,29 +105,101 @@ func GetVoiceData(r *http.Request) (VoiceDto, error) {
 	return voice, nil
 }
 
-func ConvertVoiceData(closer io.ReadCloser) (int, error) {
-	var response DeepSeekAPIResp
-	err := json.NewDecoder(closer).Decode(&response)
+// getAudioType приводит тип аудио к одному виду: http.DetectContentType определяет
+// WAV как audio/wave, OGG как application/ogg, а WebM как video/webm.
+func getAudioType(contentType string) (string, string, bool) {
+	switch contentType {
+	case "audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave":
+		return "audio/wav", ".wav", true
+	case "audio/ogg", "application/ogg", "audio/opus":
+		return "audio/ogg", ".ogg", true
+	case "audio/webm", "video/webm":
+		return "audio/webm", ".webm", true
+	default:
+		return "", "", false
+	}
+}
+
+func getVoiceForm(w http.ResponseWriter, r *http.Request, maxAudioSize int64) (VoiceDto, error) {
+	// ParseMultipartForm ограничивает только память, остальное пишется во временные файлы без предела.
+	r.Body = http.MaxBytesReader(w, r.Body, maxAudioSize+multipartFormOverhead)
+
+	err := r.ParseMultipartForm(maxAudioSize)
+
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return VoiceDto{}, internalErrors.ErrInvalidAudio
+	}
+
 	if err != nil {
-		return 0, err
+		return VoiceDto{}, err
 	}
 
-	id, err := strconv.Atoi(response.Choices[0].Message.Content)
+	voice := VoiceDto{Text: r.FormValue("text")}
+
+	file, header, err := r.FormFile("audio")
+	if errors.Is(err, http.ErrMissingFile) {
+		return voice, nil
+	}
 
 	if err != nil {
-		return 0, err
+		return VoiceDto{}, err
 	}
 
-	return id, nil
-}
+	if header.Size > maxAudioSize {
+		file.Close()
+		return VoiceDto{}, internalErrors.ErrInvalidAudio
+	}
 
-func ConvertGenerationData(closer io.ReadCloser) (string, error) {
-	var response DeepSeekAPIResp
-	err := json.NewDecoder(closer).Decode(&response)
+	head := make([]byte, 512)
+	n, err := file.Read(head)
+	if err != nil && !errors.Is(err, io.EOF) {
+		file.Close()
+		return VoiceDto{}, err
+	}
 
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return VoiceDto{}, err
+	}
+
+	audioType, ext, ok := getAudioType(http.DetectContentType(head[:n]))
+	if !ok {
+		file.Close()
+		return VoiceDto{}, internalErrors.ErrInvalidAudio
+	}
+
+	voice.Audio = &VoiceAudioDto{
+		Audio:       file,
+		Size:        header.Size,
+		ContentType: audioType,
+		Filename:    "voice" + ext,
+	}
+
+	return voice, nil
+}
+
+func getVoiceBody(r *http.Request, maxAudioSize int64) (VoiceDto, error) {
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxAudioSize+1))
 	if err != nil {
-		return "", err
+		return VoiceDto{}, err
+	}
+
+	if len(data) == 0 || int64(len(data)) > maxAudioSize {
+		return VoiceDto{}, internalErrors.ErrInvalidAudio
+	}
+
+	audioType, ext, ok := getAudioType(http.DetectContentType(data))
+	if !ok {
+		return VoiceDto{}, internalErrors.ErrInvalidAudio
 	}
 
-	return response.Choices[0].Message.Content, nil
+	return VoiceDto{
+		Audio: &VoiceAudioDto{
+			Audio:       bytes.NewReader(data),
+			Size:        int64(len(data)),
+			ContentType: audioType,
+			Filename:    "voice" + ext,
+		},
+	}, nil
 }
@@ -3,6 +3,7 @@ package delivery
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gorilla/mux"
@@ -10,20 +11,32 @@ import (
 	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
 	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 	"github.com/Olegsandrik/Exponenta/internal/utils"
+	"github.com/Olegsandrik/Exponenta/logger"
 )
 
 const (
 	versionID = "versionID"
+	servings  = "servings"
+	jobID     = "jobID"
+	diffFrom  = "from"
+	diffTo    = "to"
 )
 
 type GeneratedUsecase interface {
 	GetAllRecipes(ctx context.Context, num int) ([]dto.RecipeDto, error)
 	GetRecipeByID(ctx context.Context, recipeID int) (dto.RecipeDto, error)
-	CreateRecipe(ctx context.Context, products []string, query string) (dto.RecipeDto, error)
-	UpdateRecipe(ctx context.Context, query string, recipeID int, versionID int) (dto.RecipeDto, error)
-	GetHistoryByID(ctx context.Context, recipeID int) ([]dto.RecipeDto, error)
+	CreateRecipeJob(ctx context.Context, products []string, query string, fresh bool) (dto.GenerationJobDto, error)
+	CreateRecipeStream(ctx context.Context, products []string, query string, fresh bool,
+		onChunk func(chunk dto.GenerationChunkDto) error) (dto.RecipeDto, error)
+	UpdateRecipeJob(ctx context.Context, query string, recipeID int, versionID int) (dto.GenerationJobDto, error)
+	GetJob(ctx context.Context, jobID int) (dto.GenerationJobDto, error)
+	GetQuota(ctx context.Context) (dto.GenerationQuotaDto, error)
+	GetHistoryByID(ctx context.Context, recipeID int) ([]dto.RecipeVersionNodeDto, error)
+	ForkRecipe(ctx context.Context, recipeID int, versionID int) (dto.RecipeDto, error)
+	GetVersionsDiff(ctx context.Context, recipeID int, fromVersion int, toVersion int) (dto.RecipeDiffDto, error)
 	SetNewMainVersion(ctx context.Context, recipeID int, versionID int) error
-	StartCookingByRecipeID(ctx context.Context, recipeID int) (dto.CurrentStepRecipeDto, error)
+	StartCookingByRecipeID(ctx context.Context, recipeID int, servings int) (dto.CurrentStepRecipeDto, error)
+	GetPrepByRecipeID(ctx context.Context, recipeID int) (dto.PrepChecklistDto, error)
 }
 
 type GeneratedHandler struct {
@@ -42,12 +55,21 @@ func (h *GeneratedHandler) InitRouter(r *mux.Router) {
 	h.router = r.PathPrefix("/generate").Subrouter()
 	{
 		h.router.Handle("/all", http.HandlerFunc(h.GetAllGeneratedRecipes)).Methods(http.MethodGet)
+		h.router.Handle("/jobs/{jobID}", http.HandlerFunc(h.GetGenerationJob)).Methods(http.MethodGet)
+		h.router.Handle("/quota", http.HandlerFunc(h.GetGenerationQuota)).Methods(http.MethodGet)
 		h.router.Handle("/{recipeID}/history",
 			http.HandlerFunc(h.GetGeneratedRecipeHistoryByID)).Methods(http.MethodGet)
+		h.router.Handle("/{recipeID}/diff",
+			http.HandlerFunc(h.GetGeneratedRecipeDiff)).Methods(http.MethodGet)
+		h.router.Handle("/{recipeID}/prep",
+			http.HandlerFunc(h.GetGeneratedRecipePrepByID)).Methods(http.MethodGet)
 		h.router.Handle("/{recipeID}", http.HandlerFunc(h.GetGeneratedRecipeByID)).Methods(http.MethodGet)
 		h.router.Handle("/make", http.HandlerFunc(h.CreateGeneratedRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/make/stream", http.HandlerFunc(h.CreateGeneratedRecipeStream)).Methods(http.MethodPost)
 		h.router.Handle("/{recipeID}/modern/{versionID}",
 			http.HandlerFunc(h.UpgradeGeneratedRecipeByIDByVersion)).Methods(http.MethodPost)
+		h.router.Handle("/{recipeID}/fork/{versionID}",
+			http.HandlerFunc(h.ForkGeneratedRecipeVersion)).Methods(http.MethodPost)
 		h.router.Handle("/{recipeID}/main/{versionID}",
 			http.HandlerFunc(h.SetNewMainVersionGeneratedRecipe)).Methods(http.MethodPost)
 		h.router.Handle("/{recipeID}/start",
@@ -137,6 +159,44 @@ func (h *GeneratedHandler) GetGeneratedRecipeByID(w http.ResponseWriter, r *http
 	})
 }
 
+func (h *GeneratedHandler) GetGeneratedRecipePrepByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	recipeIDParam, err := dto.GetIntURLParam(r, recipeID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр recipeID",
+		})
+		return
+	}
+
+	prep, err := h.usecase.GetPrepByRecipeID(ctx, recipeIDParam)
+
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось собрать список подготовки",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   prep,
+	})
+}
+
 func (h *GeneratedHandler) CreateGeneratedRecipe(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	generatedRecipeData, err := dto.GetGenerationData(r)
@@ -158,7 +218,99 @@ func (h *GeneratedHandler) CreateGeneratedRecipe(w http.ResponseWriter, r *http.
 		return
 	}
 
-	generatedRecipe, err := h.usecase.CreateRecipe(ctx, generatedRecipeData.Ingredients, generatedRecipeData.Query)
+	job, err := h.usecase.CreateRecipeJob(ctx, generatedRecipeData.Ingredients, generatedRecipeData.Query,
+		generatedRecipeData.Fresh)
+	if err != nil {
+		h.writeCreateJobError(ctx, w, err)
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   job,
+	})
+}
+
+// CreateGeneratedRecipeStream отдает ответ модели событиями chunk по мере генерации и завершается событием
+// recipe с сохраненным рецептом или событием error.
+func (h *GeneratedHandler) CreateGeneratedRecipeStream(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	generatedRecipeData, err := dto.GetGenerationData(r)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректные данные рецепта для генерации",
+		})
+		return
+	}
+
+	if generatedRecipeData.Ingredients == nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "err with request",
+			MsgRus: "некорректные данные рецепта для генерации",
+		})
+		return
+	}
+
+	rc, err := utils.PrepareSSEResponse(w)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to prepare generation stream: %v", err))
+		return
+	}
+
+	recipe, err := h.usecase.CreateRecipeStream(ctx, generatedRecipeData.Ingredients, generatedRecipeData.Query,
+		generatedRecipeData.Fresh, func(chunk dto.GenerationChunkDto) error {
+			return utils.WriteSSEEvent(w, rc, dto.EventGenerationChunk, chunk)
+		})
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		errResponse := utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось сгенерировать рецепт",
+		}
+
+		switch {
+		case errors.Is(err, internalErrors.ErrUserNotAuth):
+			errResponse.Status = http.StatusUnauthorized
+			errResponse.MsgRus = "пользователь не авторизован"
+		case errors.Is(err, internalErrors.ErrAllKeysAreUsing):
+			errResponse.Status = http.StatusServiceUnavailable
+			errResponse.MsgRus = "На данный момент шеф занят, попробуйте позднее"
+		case errors.Is(err, internalErrors.ErrGenerationQuotaExceeded):
+			errResponse.Status = http.StatusTooManyRequests
+			errResponse.MsgRus = "лимит генераций исчерпан, попробуйте позднее"
+		}
+
+		if err = utils.WriteSSEEvent(w, rc, dto.EventGenerationError, errResponse); err != nil {
+			logger.Error(ctx, fmt.Sprintf("failed to write generation error: %v", err))
+		}
+		return
+	}
+
+	if err = utils.WriteSSEEvent(w, rc, dto.EventGenerationRecipe, recipe); err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to write generated recipe: %v", err))
+	}
+}
+
+func (h *GeneratedHandler) GetGeneratedRecipeHistoryByID(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	recipeIDParam, err := dto.GetIntURLParam(r, recipeID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр recipeID",
+		})
+		return
+	}
+
+	history, err := h.usecase.GetHistoryByID(ctx, recipeIDParam)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -167,29 +319,22 @@ func (h *GeneratedHandler) CreateGeneratedRecipe(w http.ResponseWriter, r *http.
 				MsgRus: "пользователь не авторизован",
 			})
 			return
-		} else if errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
-			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
-				Status: http.StatusUnauthorized,
-				Msg:    internalErrors.ErrAllKeysAreUsing.Error(),
-				MsgRus: "На данный момент шеф занят, попробуйте позднее",
-			})
-			return
 		}
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
-			MsgRus: "не получилось сгенерировать рецепт",
+			MsgRus: "не получилось получить историю",
 		})
 		return
 	}
 
 	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
 		Status: http.StatusOK,
-		Data:   generatedRecipe,
+		Data:   history,
 	})
 }
 
-func (h *GeneratedHandler) GetGeneratedRecipeHistoryByID(w http.ResponseWriter, r *http.Request) {
+func (h *GeneratedHandler) GetGeneratedRecipeDiff(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	recipeIDParam, err := dto.GetIntURLParam(r, recipeID)
 	if err != nil {
@@ -201,27 +346,107 @@ func (h *GeneratedHandler) GetGeneratedRecipeHistoryByID(w http.ResponseWriter,
 		return
 	}
 
-	history, err := h.usecase.GetHistoryByID(ctx, recipeIDParam)
+	fromParam, err := dto.GetIntQueryParam(r, diffFrom)
+	if err != nil || fromParam < 1 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    internalErrors.ErrBadVersionID.Error(),
+			MsgRus: "некорректный параметр from",
+		})
+		return
+	}
+
+	toParam, err := dto.GetIntQueryParam(r, diffTo)
+	if err != nil || toParam < 1 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    internalErrors.ErrBadVersionID.Error(),
+			MsgRus: "некорректный параметр to",
+		})
+		return
+	}
+
+	diff, err := h.usecase.GetVersionsDiff(ctx, recipeIDParam, fromParam, toParam)
 	if err != nil {
-		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+		switch {
+		case errors.Is(err, internalErrors.ErrUserNotAuth):
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 				Status: http.StatusUnauthorized,
 				Msg:    internalErrors.ErrUserNotAuth.Error(),
 				MsgRus: "пользователь не авторизован",
 			})
-			return
+		case errors.Is(err, internalErrors.ErrVersionNotFound):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    internalErrors.ErrVersionNotFound.Error(),
+				MsgRus: "данной версии не существует",
+			})
+		default:
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusInternalServerError,
+				Msg:    err.Error(),
+				MsgRus: "не получилось сравнить версии рецепта",
+			})
 		}
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   diff,
+	})
+}
+
+func (h *GeneratedHandler) ForkGeneratedRecipeVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	recipeIDParam, err := dto.GetIntURLParam(r, recipeID)
+	if err != nil {
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
-			Status: http.StatusInternalServerError,
+			Status: http.StatusBadRequest,
 			Msg:    err.Error(),
-			MsgRus: "не получилось получить историю",
+			MsgRus: "некорректный параметр recipeID",
+		})
+		return
+	}
+
+	versionIDParam, err := dto.GetIntURLParam(r, versionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр versionID",
 		})
 		return
 	}
 
+	recipe, err := h.usecase.ForkRecipe(ctx, recipeIDParam, versionIDParam)
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.ErrUserNotAuth):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+		case errors.Is(err, internalErrors.ErrVersionNotFound):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    internalErrors.ErrVersionNotFound.Error(),
+				MsgRus: "данной версии не существует",
+			})
+		default:
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusInternalServerError,
+				Msg:    err.Error(),
+				MsgRus: "не получилось создать новый рецепт из версии",
+			})
+		}
+		return
+	}
+
 	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
 		Status: http.StatusOK,
-		Data:   history,
+		Data:   recipe,
 	})
 }
 
@@ -257,34 +482,111 @@ func (h *GeneratedHandler) UpgradeGeneratedRecipeByIDByVersion(w http.ResponseWr
 		return
 	}
 
-	recipeData, err := h.usecase.UpdateRecipe(ctx, generatedRecipeData.Query, recipeIDParam, versionIDParam)
+	job, err := h.usecase.UpdateRecipeJob(ctx, generatedRecipeData.Query, recipeIDParam, versionIDParam)
 	if err != nil {
-		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+		h.writeCreateJobError(ctx, w, err)
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   job,
+	})
+}
+
+func (h *GeneratedHandler) GetGenerationJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	jobIDParam, err := dto.GetIntURLParam(r, jobID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр jobID",
+		})
+		return
+	}
+
+	job, err := h.usecase.GetJob(ctx, jobIDParam)
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.ErrUserNotAuth):
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 				Status: http.StatusUnauthorized,
 				Msg:    internalErrors.ErrUserNotAuth.Error(),
 				MsgRus: "пользователь не авторизован",
 			})
-			return
-		} else if errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
+		case errors.Is(err, internalErrors.ErrGenerationJobNotFound):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    internalErrors.ErrGenerationJobNotFound.Error(),
+				MsgRus: "задача генерации не найдена",
+			})
+		default:
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusInternalServerError,
+				Msg:    err.Error(),
+				MsgRus: "не получилось получить статус генерации",
+			})
+		}
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   job,
+	})
+}
+
+func (h *GeneratedHandler) GetGenerationQuota(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	quota, err := h.usecase.GetQuota(ctx)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 				Status: http.StatusUnauthorized,
-				Msg:    internalErrors.ErrAllKeysAreUsing.Error(),
-				MsgRus: "На данный момент шеф занят, попробуйте позднее",
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
 			})
 			return
 		}
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
-			MsgRus: "не получилось сгенерировать рецепт",
+			MsgRus: "не получилось получить лимит генераций",
 		})
 		return
 	}
 
 	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
 		Status: http.StatusOK,
-		Data:   recipeData,
+		Data:   quota,
+	})
+}
+
+func (h *GeneratedHandler) writeCreateJobError(ctx context.Context, w http.ResponseWriter, err error) {
+	if errors.Is(err, internalErrors.ErrUserNotAuth) {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusUnauthorized,
+			Msg:    internalErrors.ErrUserNotAuth.Error(),
+			MsgRus: "пользователь не авторизован",
+		})
+		return
+	}
+
+	if errors.Is(err, internalErrors.ErrGenerationQuotaExceeded) {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusTooManyRequests,
+			Msg:    internalErrors.ErrGenerationQuotaExceeded.Error(),
+			MsgRus: "лимит генераций исчерпан, попробуйте позднее",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+		Status: http.StatusInternalServerError,
+		Msg:    err.Error(),
+		MsgRus: "не получилось поставить рецепт в очередь на генерацию",
 	})
 }
 
@@ -300,7 +602,17 @@ func (h *GeneratedHandler) StartCookingGeneratedRecipe(w http.ResponseWriter, r
 		return
 	}
 
-	currentRecipeData, err := h.usecase.StartCookingByRecipeID(ctx, recipeIDParam)
+	servingsParam, err := dto.GetIntQueryParam(r, servings)
+	if (err != nil && !errors.Is(err, internalErrors.ErrParamNotFound)) || servingsParam < 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    internalErrors.ErrParamNotInteger.Error(),
+			MsgRus: "некорректный параметр servings",
+		})
+		return
+	}
+
+	currentRecipeData, err := h.usecase.StartCookingByRecipeID(ctx, recipeIDParam, servingsParam)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
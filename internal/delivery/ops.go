@@ -0,0 +1,55 @@
+package delivery
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	"github.com/Olegsandrik/Exponenta/internal/utils"
+)
+
+type OpsUsecase interface {
+	GetKeysStats(ctx context.Context) (dto.LLMKeysStatsDto, error)
+}
+
+// OpsHandler отдает служебные данные для эксплуатации. Подключается только к внутреннему серверу,
+// наружу эти ручки не публикуются.
+type OpsHandler struct {
+	router  *mux.Router
+	usecase OpsUsecase
+}
+
+func NewOpsHandler(usecase OpsUsecase) *OpsHandler {
+	return &OpsHandler{
+		mux.NewRouter(),
+		usecase,
+	}
+}
+
+func (h *OpsHandler) InitRouter(r *mux.Router) {
+	h.router = r.PathPrefix("/ops").Subrouter()
+	{
+		h.router.Handle("/llm/keys", http.HandlerFunc(h.GetKeysStats)).Methods(http.MethodGet)
+	}
+}
+
+func (h *OpsHandler) GetKeysStats(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	stats, err := h.usecase.GetKeysStats(ctx)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось получить статистику ключей",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   stats,
+	})
+}
@@ -3,41 +3,65 @@ package delivery
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/Olegsandrik/Exponenta/config"
 	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
 	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 	"github.com/Olegsandrik/Exponenta/internal/utils"
+	"github.com/Olegsandrik/Exponenta/logger"
 )
 
 const (
-	num = "num"
+	num       = "num"
+	sessionID = "sessionID"
 )
 
 type CookingRecipeUsecase interface {
 	GetAllRecipe(context.Context, int) ([]dto.RecipeDto, error)
 	GetRecipeByID(context.Context, int) (dto.RecipeDto, error)
-	StartCookingRecipe(context.Context, int) (dto.CurrentStepRecipeDto, error)
-	EndCookingRecipe(context.Context) error
-	GetCurrentRecipe(context.Context) (dto.CurrentRecipeDto, error)
-	NextStepRecipe(context.Context) (dto.CurrentStepRecipeDto, error)
-	PreviousStepRecipe(context.Context) (dto.CurrentStepRecipeDto, error)
-	AddTimerRecipe(context.Context, int, int) error
-	DeleteTimerRecipe(context.Context, int) error
-	GetTimersRecipe(context.Context) ([]dto.TimerRecipeDto, error)
+	StartCookingRecipe(context.Context, int, int) (dto.CurrentStepRecipeDto, error)
+	StartCookingSession(context.Context, int, bool, int) (dto.CurrentRecipeDto, error)
+	GetCookingSessions(context.Context) ([]dto.CurrentRecipeDto, error)
+	EndCookingRecipe(context.Context, int, dto.CookingFeedbackDto) error
+	GetCurrentRecipe(context.Context, int) (dto.CurrentRecipeDto, error)
+	NextStepRecipe(context.Context, int) (dto.CurrentStepRecipeDto, error)
+	PreviousStepRecipe(context.Context, int) (dto.CurrentStepRecipeDto, error)
+	GoToStepRecipe(context.Context, int, int) (dto.CurrentStepRecipeDto, error)
+	MarkStepRecipe(context.Context, int, int, bool) (dto.CurrentRecipeDto, error)
+	SetAutoTimersRecipe(context.Context, int, bool) (dto.CurrentRecipeDto, error)
+	AddTimerRecipe(context.Context, int, int, int, string) (dto.TimerRecipeDto, error)
+	DeleteTimerRecipe(context.Context, int, int, int) error
+	PauseTimerRecipe(context.Context, int, int) (dto.TimerRecipeDto, error)
+	ResumeTimerRecipe(context.Context, int, int) (dto.TimerRecipeDto, error)
+	ExtendTimerRecipe(context.Context, int, int, int) (dto.TimerRecipeDto, error)
+	GetTimersRecipe(context.Context, int) ([]dto.TimerRecipeDto, error)
+	SubscribeCookingEvents(context.Context, int) (<-chan dto.CookingEventDto, func(), error)
+	CreateJoinCode(context.Context, int) (dto.JoinCodeDto, error)
+	JoinCookingSession(context.Context, string) (dto.CurrentRecipeDto, error)
+	GetRecipePrep(context.Context, int) (dto.PrepChecklistDto, error)
+	GetPrepRecipe(context.Context, int) (dto.PrepChecklistDto, error)
+	CheckPrepItemRecipe(context.Context, int, string, bool) (dto.PrepChecklistDto, error)
 }
 
 type CookingRecipeHandler struct {
-	router  *mux.Router
-	usecase CookingRecipeUsecase
+	router          *mux.Router
+	usecase         CookingRecipeUsecase
+	eventsKeepAlive time.Duration
+	maxPhotoSize    int64
 }
 
-func NewCookingRecipeHandler(usecase CookingRecipeUsecase) *CookingRecipeHandler {
+func NewCookingRecipeHandler(usecase CookingRecipeUsecase, cfg *config.Config) *CookingRecipeHandler {
 	return &CookingRecipeHandler{
-		router:  mux.NewRouter(),
-		usecase: usecase,
+		router:          mux.NewRouter(),
+		usecase:         usecase,
+		eventsKeepAlive: cfg.EventsKeepAlive,
+		maxPhotoSize:    int64(cfg.CookingPhotoMaxSize),
 	}
 }
 
@@ -47,13 +71,65 @@ func (h *CookingRecipeHandler) InitRouter(r *mux.Router) {
 		h.router.Handle("", http.HandlerFunc(h.GetCurrentRecipe)).Methods(http.MethodGet)
 		h.router.Handle("/all", http.HandlerFunc(h.GetAllRecipes)).Methods(http.MethodGet)
 		h.router.Handle("/timers", http.HandlerFunc(h.GetAllTimersCookingRecipe)).Methods(http.MethodGet)
+		h.router.Handle("/sessions", http.HandlerFunc(h.GetCookingSessions)).Methods(http.MethodGet)
+		h.router.Handle("/events", http.HandlerFunc(h.CookingEvents)).Methods(http.MethodGet)
+		h.router.Handle("/prep", http.HandlerFunc(h.GetPrepCookingRecipe)).Methods(http.MethodGet)
 		h.router.Handle("/{recipeID}", http.HandlerFunc(h.GetRecipeByID)).Methods(http.MethodGet)
+		h.router.Handle("/{recipeID}/prep", http.HandlerFunc(h.GetRecipePrep)).Methods(http.MethodGet)
 		h.router.Handle("/start", http.HandlerFunc(h.StartCookingRecipe)).Methods(http.MethodPost)
 		h.router.Handle("/end", http.HandlerFunc(h.EndCookingRecipe)).Methods(http.MethodPost)
 		h.router.Handle("/next", http.HandlerFunc(h.NextStepCookingRecipe)).Methods(http.MethodPost)
 		h.router.Handle("/prev", http.HandlerFunc(h.PrevStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/step", http.HandlerFunc(h.GoToStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/step/done", http.HandlerFunc(h.DoneStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/step/undo", http.HandlerFunc(h.UndoStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/prep/check", http.HandlerFunc(h.CheckPrepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/prep/uncheck", http.HandlerFunc(h.UncheckPrepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/timer/auto", http.HandlerFunc(h.AutoTimersCookingRecipe)).Methods(http.MethodPost)
 		h.router.Handle("/timer/add", http.HandlerFunc(h.AddTimerCookingRecipe)).Methods(http.MethodPost)
 		h.router.Handle("/timer/finish", http.HandlerFunc(h.FinishTimerCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/timer/pause", http.HandlerFunc(h.PauseTimerCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/timer/resume", http.HandlerFunc(h.ResumeTimerCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/timer/extend", http.HandlerFunc(h.ExtendTimerCookingRecipe)).Methods(http.MethodPost)
+
+		h.router.Handle("/invite", http.HandlerFunc(h.InviteCookingSession)).Methods(http.MethodPost)
+
+		h.router.Handle("/session/start", http.HandlerFunc(h.StartCookingSession)).Methods(http.MethodPost)
+		h.router.Handle("/session/join", http.HandlerFunc(h.JoinCookingSession)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/invite",
+			http.HandlerFunc(h.InviteCookingSession)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}", http.HandlerFunc(h.GetCurrentRecipe)).Methods(http.MethodGet)
+		h.router.Handle("/session/{sessionID}/events", http.HandlerFunc(h.CookingEvents)).Methods(http.MethodGet)
+		h.router.Handle("/session/{sessionID}/timers",
+			http.HandlerFunc(h.GetAllTimersCookingRecipe)).Methods(http.MethodGet)
+		h.router.Handle("/session/{sessionID}/end", http.HandlerFunc(h.EndCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/next",
+			http.HandlerFunc(h.NextStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/prev",
+			http.HandlerFunc(h.PrevStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/step",
+			http.HandlerFunc(h.GoToStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/step/done",
+			http.HandlerFunc(h.DoneStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/step/undo",
+			http.HandlerFunc(h.UndoStepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/prep", http.HandlerFunc(h.GetPrepCookingRecipe)).Methods(http.MethodGet)
+		h.router.Handle("/session/{sessionID}/prep/check",
+			http.HandlerFunc(h.CheckPrepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/prep/uncheck",
+			http.HandlerFunc(h.UncheckPrepCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/timer/auto",
+			http.HandlerFunc(h.AutoTimersCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/timer/add",
+			http.HandlerFunc(h.AddTimerCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/timer/finish",
+			http.HandlerFunc(h.FinishTimerCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/timer/pause",
+			http.HandlerFunc(h.PauseTimerCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/timer/resume",
+			http.HandlerFunc(h.ResumeTimerCookingRecipe)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/timer/extend",
+			http.HandlerFunc(h.ExtendTimerCookingRecipe)).Methods(http.MethodPost)
 	}
 }
 
@@ -119,7 +195,17 @@ func (h *CookingRecipeHandler) GetRecipeByID(w http.ResponseWriter, r *http.Requ
 func (h *CookingRecipeHandler) GetCurrentRecipe(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	recipeData, err := h.usecase.GetCurrentRecipe(ctx)
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	recipeData, err := h.usecase.GetCurrentRecipe(ctx, sessionIDParam)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -165,7 +251,16 @@ func (h *CookingRecipeHandler) StartCookingRecipe(w http.ResponseWriter, r *http
 		return
 	}
 
-	currentRecipe, err := h.usecase.StartCookingRecipe(ctx, recipeData.ID)
+	if recipeData.ServingsNum < 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid servings number",
+			MsgRus: "некорректное количество порций",
+		})
+		return
+	}
+
+	currentRecipe, err := h.usecase.StartCookingRecipe(ctx, recipeData.ID, recipeData.ServingsNum)
 
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
@@ -193,7 +288,41 @@ func (h *CookingRecipeHandler) StartCookingRecipe(w http.ResponseWriter, r *http
 func (h *CookingRecipeHandler) EndCookingRecipe(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	err := h.usecase.EndCookingRecipe(ctx)
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	feedbackData, err := dto.GetCookingFeedbackData(w, r, h.maxPhotoSize)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrInvalidImage) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "фото больше допустимого размера",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректные данные отзыва о рецепте",
+		})
+		return
+	}
+
+	if feedbackData.Photo != nil {
+		if closer, ok := feedbackData.Photo.Image.(io.Closer); ok {
+			defer closer.Close()
+		}
+	}
+
+	err = h.usecase.EndCookingRecipe(ctx, sessionIDParam, feedbackData)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -203,6 +332,30 @@ func (h *CookingRecipeHandler) EndCookingRecipe(w http.ResponseWriter, r *http.R
 			})
 			return
 		}
+		if errors.Is(err, internalErrors.ErrInvalidRating) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "оценка должна быть от 1 до 5",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrNotesTooLong) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "слишком длинная заметка",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrInvalidImage) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "фото должно быть в формате jpeg, png или webp",
+			})
+			return
+		}
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
@@ -220,7 +373,17 @@ func (h *CookingRecipeHandler) EndCookingRecipe(w http.ResponseWriter, r *http.R
 func (h *CookingRecipeHandler) NextStepCookingRecipe(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	nextStepData, err := h.usecase.NextStepRecipe(ctx)
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	nextStepData, err := h.usecase.NextStepRecipe(ctx, sessionIDParam)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -230,6 +393,14 @@ func (h *CookingRecipeHandler) NextStepCookingRecipe(w http.ResponseWriter, r *h
 			})
 			return
 		}
+		if errors.Is(err, internalErrors.ErrStepOutOfRange) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "нет следующего шага рецепта",
+			})
+			return
+		}
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
@@ -247,7 +418,17 @@ func (h *CookingRecipeHandler) NextStepCookingRecipe(w http.ResponseWriter, r *h
 func (h *CookingRecipeHandler) PrevStepCookingRecipe(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	prevStepData, err := h.usecase.PreviousStepRecipe(ctx)
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	prevStepData, err := h.usecase.PreviousStepRecipe(ctx, sessionIDParam)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -257,6 +438,14 @@ func (h *CookingRecipeHandler) PrevStepCookingRecipe(w http.ResponseWriter, r *h
 			})
 			return
 		}
+		if errors.Is(err, internalErrors.ErrStepOutOfRange) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "нет предыдущего шага рецепта",
+			})
+			return
+		}
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
@@ -271,10 +460,30 @@ func (h *CookingRecipeHandler) PrevStepCookingRecipe(w http.ResponseWriter, r *h
 	})
 }
 
-func (h *CookingRecipeHandler) GetAllTimersCookingRecipe(w http.ResponseWriter, r *http.Request) {
+func (h *CookingRecipeHandler) GoToStepCookingRecipe(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	timersData, err := h.usecase.GetTimersRecipe(ctx)
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	stepData, err := dto.GetStepRecipeData(r)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid step data",
+			MsgRus: "некорректные данные шага",
+		})
+		return
+	}
+
+	stepRecipeData, err := h.usecase.GoToStepRecipe(ctx, sessionIDParam, stepData.StepNum)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -284,54 +493,134 @@ func (h *CookingRecipeHandler) GetAllTimersCookingRecipe(w http.ResponseWriter,
 			})
 			return
 		}
+		if errors.Is(err, internalErrors.ErrStepOutOfRange) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "такого шага нет в рецепте",
+			})
+			return
+		}
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
-			MsgRus: "не получилось получить список таймеров",
+			MsgRus: "не получилось перейти к шагу рецепта",
 		})
 		return
 	}
 
 	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
 		Status: http.StatusOK,
-		Data:   timersData,
+		Data:   stepRecipeData,
 	})
 }
 
-func (h *CookingRecipeHandler) AddTimerCookingRecipe(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+func (h *CookingRecipeHandler) DoneStepCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	h.markStepCookingRecipe(w, r, true)
+}
 
-	TimerData, err := dto.GetTimerRecipeData(r)
+func (h *CookingRecipeHandler) UndoStepCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	h.markStepCookingRecipe(w, r, false)
+}
+
+func (h *CookingRecipeHandler) markStepCookingRecipe(w http.ResponseWriter, r *http.Request, isDone bool) {
+	ctx := r.Context()
 
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
 	if err != nil {
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusBadRequest,
-			Msg:    "invalid timer data",
-			MsgRus: "некорректны данные таймера",
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
 		})
 		return
 	}
 
-	if TimerData.StepNum == 0 {
+	stepData, err := dto.GetStepRecipeData(r)
+	if err != nil {
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusBadRequest,
-			Msg:    "step not found",
-			MsgRus: "step не найден",
+			Msg:    "invalid step data",
+			MsgRus: "некорректные данные шага",
 		})
 		return
 	}
 
-	if TimerData.Time == 0 {
+	recipeData, err := h.usecase.MarkStepRecipe(ctx, sessionIDParam, stepData.StepNum, isDone)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrStepOutOfRange) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "такого шага нет в рецепте",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось отметить шаг рецепта",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   recipeData,
+	})
+}
+
+func (h *CookingRecipeHandler) GetRecipePrep(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	recipeIDParam, err := dto.GetIntURLParam(r, recipeID)
+	if err != nil {
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusBadRequest,
-			Msg:    "time not found",
-			MsgRus: "time не найден",
+			Msg:    err.Error(),
+			MsgRus: "id должен быть целым числом",
+		})
+		return
+	}
+
+	prep, err := h.usecase.GetRecipePrep(ctx, recipeIDParam)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось собрать список подготовки",
 		})
 		return
 	}
 
-	err = h.usecase.AddTimerRecipe(ctx, TimerData.StepNum, TimerData.Time)
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   prep,
+	})
+}
+
+func (h *CookingRecipeHandler) GetPrepCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
 
+	prep, err := h.usecase.GetPrepRecipe(ctx, sessionIDParam)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -344,42 +633,104 @@ func (h *CookingRecipeHandler) AddTimerCookingRecipe(w http.ResponseWriter, r *h
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
-			MsgRus: "не получилось добавить таймер",
+			MsgRus: "не получилось собрать список подготовки",
 		})
 		return
 	}
 
 	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
 		Status: http.StatusOK,
-		Data:   nil,
+		Data:   prep,
 	})
 }
 
-func (h *CookingRecipeHandler) FinishTimerCookingRecipe(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
+func (h *CookingRecipeHandler) CheckPrepCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	h.checkPrepCookingRecipe(w, r, true)
+}
 
-	TimerData, err := dto.GetTimerRecipeData(r)
+func (h *CookingRecipeHandler) UncheckPrepCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	h.checkPrepCookingRecipe(w, r, false)
+}
+
+func (h *CookingRecipeHandler) checkPrepCookingRecipe(w http.ResponseWriter, r *http.Request, isChecked bool) {
+	ctx := r.Context()
 
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
 	if err != nil {
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusBadRequest,
-			Msg:    "invalid timer data",
-			MsgRus: "некорректны данные таймера",
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
 		})
 		return
 	}
 
-	if TimerData.StepNum == 0 {
+	itemData, err := dto.GetPrepItemData(r)
+	if err != nil || itemData.ID == "" {
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusBadRequest,
-			Msg:    "step not found",
-			MsgRus: "step не найден",
+			Msg:    "invalid prep item data",
+			MsgRus: "некорректные данные позиции списка подготовки",
+		})
+		return
+	}
+
+	prep, err := h.usecase.CheckPrepItemRecipe(ctx, sessionIDParam, itemData.ID, isChecked)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrPrepItemNotFound) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "такой позиции нет в списке подготовки",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось отметить позицию списка подготовки",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   prep,
+	})
+}
+
+func (h *CookingRecipeHandler) AutoTimersCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
 		})
 		return
 	}
 
-	err = h.usecase.DeleteTimerRecipe(ctx, TimerData.StepNum)
+	autoTimersData, err := dto.GetAutoTimersData(r)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid auto timers data",
+			MsgRus: "некорректные данные автотаймеров",
+		})
+		return
+	}
 
+	recipeData, err := h.usecase.SetAutoTimersRecipe(ctx, sessionIDParam, autoTimersData.Enabled)
 	if err != nil {
 		if errors.Is(err, internalErrors.ErrUserNotAuth) {
 			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
@@ -392,13 +743,555 @@ func (h *CookingRecipeHandler) FinishTimerCookingRecipe(w http.ResponseWriter, r
 		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
 			Msg:    err.Error(),
-			MsgRus: "не получилось завершить таймер",
+			MsgRus: "не получилось изменить режим автотаймеров",
 		})
 		return
 	}
 
 	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
 		Status: http.StatusOK,
-		Data:   nil,
+		Data:   recipeData,
 	})
 }
+
+func (h *CookingRecipeHandler) GetAllTimersCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	timersData, err := h.usecase.GetTimersRecipe(ctx, sessionIDParam)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось получить список таймеров",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   timersData,
+	})
+}
+
+func (h *CookingRecipeHandler) AddTimerCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	TimerData, err := dto.GetTimerRecipeData(r)
+
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid timer data",
+			MsgRus: "некорректны данные таймера",
+		})
+		return
+	}
+
+	if TimerData.StepNum == 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "step not found",
+			MsgRus: "step не найден",
+		})
+		return
+	}
+
+	if TimerData.Time == 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "time not found",
+			MsgRus: "time не найден",
+		})
+		return
+	}
+
+	timer, err := h.usecase.AddTimerRecipe(ctx, sessionIDParam, TimerData.StepNum, TimerData.Time, TimerData.Label)
+
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось добавить таймер",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   timer,
+	})
+}
+
+func (h *CookingRecipeHandler) FinishTimerCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	TimerData, err := dto.GetTimerRecipeData(r)
+
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid timer data",
+			MsgRus: "некорректны данные таймера",
+		})
+		return
+	}
+
+	if TimerData.StepNum == 0 && TimerData.ID == 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "step not found",
+			MsgRus: "step не найден",
+		})
+		return
+	}
+
+	err = h.usecase.DeleteTimerRecipe(ctx, sessionIDParam, TimerData.ID, TimerData.StepNum)
+
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrTimerNotFound) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    err.Error(),
+				MsgRus: "таймер не найден",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось завершить таймер",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   nil,
+	})
+}
+
+func (h *CookingRecipeHandler) PauseTimerCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	h.updateTimerCookingRecipe(w, r, "не получилось поставить таймер на паузу",
+		func(ctx context.Context, sessionID int, timerData dto.TimerRecipeDataDto) (dto.TimerRecipeDto, error) {
+			return h.usecase.PauseTimerRecipe(ctx, sessionID, timerData.ID)
+		})
+}
+
+func (h *CookingRecipeHandler) ResumeTimerCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	h.updateTimerCookingRecipe(w, r, "не получилось возобновить таймер",
+		func(ctx context.Context, sessionID int, timerData dto.TimerRecipeDataDto) (dto.TimerRecipeDto, error) {
+			return h.usecase.ResumeTimerRecipe(ctx, sessionID, timerData.ID)
+		})
+}
+
+func (h *CookingRecipeHandler) ExtendTimerCookingRecipe(w http.ResponseWriter, r *http.Request) {
+	h.updateTimerCookingRecipe(w, r, "не получилось продлить таймер",
+		func(ctx context.Context, sessionID int, timerData dto.TimerRecipeDataDto) (dto.TimerRecipeDto, error) {
+			if timerData.Time <= 0 {
+				return dto.TimerRecipeDto{}, internalErrors.ErrInvalidTimerLength
+			}
+			return h.usecase.ExtendTimerRecipe(ctx, sessionID, timerData.ID, timerData.Time)
+		})
+}
+
+func (h *CookingRecipeHandler) updateTimerCookingRecipe(w http.ResponseWriter, r *http.Request, errMsgRus string,
+	update func(context.Context, int, dto.TimerRecipeDataDto) (dto.TimerRecipeDto, error)) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	TimerData, err := dto.GetTimerRecipeData(r)
+
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid timer data",
+			MsgRus: "некорректны данные таймера",
+		})
+		return
+	}
+
+	if TimerData.ID == 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "timer id not found",
+			MsgRus: "id таймера не найден",
+		})
+		return
+	}
+
+	timer, err := update(ctx, sessionIDParam, TimerData)
+
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrInvalidTimerLength) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "time не найден",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrTimerNotFound) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    err.Error(),
+				MsgRus: "таймер не найден",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: errMsgRus,
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   timer,
+	})
+}
+
+func (h *CookingRecipeHandler) StartCookingSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	recipeData, err := dto.GetCookingRecipeData(r)
+
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid recipe id",
+			MsgRus: "некорректный recipe id",
+		})
+		return
+	}
+
+	if recipeData.ID == 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "not found recipe id",
+			MsgRus: "не найден recipe id",
+		})
+		return
+	}
+
+	if recipeData.ServingsNum < 0 {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid servings number",
+			MsgRus: "некорректное количество порций",
+		})
+		return
+	}
+
+	session, err := h.usecase.StartCookingSession(ctx, recipeData.ID, recipeData.IsGenerated, recipeData.ServingsNum)
+
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		} else if errors.Is(err, internalErrors.ErrNoSuchRecipeWithID) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    err.Error(),
+				MsgRus: "рецепт не найден",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось начать готовку",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   session,
+	})
+}
+
+func (h *CookingRecipeHandler) InviteCookingSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	joinCode, err := h.usecase.CreateJoinCode(ctx, sessionIDParam)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrNotSessionOwner) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusForbidden,
+				Msg:    err.Error(),
+				MsgRus: "пригласить может только владелец сессии",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrNoCurrentRecipe) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    err.Error(),
+				MsgRus: "сессия готовки не найдена",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось создать код приглашения",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   joinCode,
+	})
+}
+
+func (h *CookingRecipeHandler) JoinCookingSession(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	joinCodeData, err := dto.GetJoinCodeData(r)
+
+	if err != nil || joinCodeData.Code == "" {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "invalid join code",
+			MsgRus: "некорректный код приглашения",
+		})
+		return
+	}
+
+	session, err := h.usecase.JoinCookingSession(ctx, joinCodeData.Code)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrInvalidJoinCode) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    err.Error(),
+				MsgRus: "сессия с таким кодом не найдена или код истек",
+			})
+			return
+		}
+		if errors.Is(err, internalErrors.ErrTooManyJoinAttempts) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusTooManyRequests,
+				Msg:    err.Error(),
+				MsgRus: "слишком много попыток, попробуйте позднее",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось присоединиться к готовке",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   session,
+	})
+}
+
+func (h *CookingRecipeHandler) GetCookingSessions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessions, err := h.usecase.GetCookingSessions(ctx)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		} else if errors.Is(err, internalErrors.ErrNoCurrentRecipe) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusNotFound,
+				Msg:    err.Error(),
+				MsgRus: "на данный момент вы ничего не готовите",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось получить список готовок",
+		})
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   sessions,
+	})
+}
+
+func (h *CookingRecipeHandler) CookingEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
+		})
+		return
+	}
+
+	events, unsubscribe, err := h.usecase.SubscribeCookingEvents(ctx, sessionIDParam)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrUserNotAuth) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+			return
+		}
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusInternalServerError,
+			Msg:    err.Error(),
+			MsgRus: "не получилось подписаться на события готовки",
+		})
+		return
+	}
+
+	defer unsubscribe()
+
+	rc, err := utils.PrepareSSEResponse(w)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to prepare event stream: %v", err))
+		return
+	}
+
+	keepAlive := time.NewTicker(h.eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepAlive.C:
+			if err = utils.WriteSSEKeepAlive(w, rc); err != nil {
+				logger.Error(ctx, fmt.Sprintf("failed to write keep-alive: %v", err))
+				return
+			}
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+
+			if err = utils.WriteSSEEvent(w, rc, event.Type, event); err != nil {
+				logger.Error(ctx, fmt.Sprintf("failed to write event %s: %v", event.Type, err))
+				return
+			}
+
+			if event.Type == dto.EventSessionEnded {
+				return
+			}
+		}
+	}
+}
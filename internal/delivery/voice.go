@@ -1,107 +1,336 @@
 package delivery
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/Olegsandrik/Exponenta/config"
 	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 	"github.com/Olegsandrik/Exponenta/internal/utils"
 	"github.com/Olegsandrik/Exponenta/logger"
 )
 
-const promptVoice = `You are a helpful assistant, you need to recognize main idea of russian 
-	text and send me only a number.
-	You should send me 1 if main idea of text is next step or switch step.
-	You should send me 2 if main idea of text is previous step or switch step to previous.
-	You should send me 3 if main idea of text is end cooking.
-	You should send me 4 if main idea of text is end timer.
-	You should send me 5 if main idea of text is start timer.
-	You should send me 6 if main idea of text is get all timers.
-	You should send me 0 on other ideas.`
+const timerID = "timerID"
+
+type VoiceUsecase interface {
+	RecognizeIntent(context.Context, dto.VoiceDto) (int, error)
+	ExecuteCommand(context.Context, int, dto.VoiceDto) (dto.VoiceCommandDto, error)
+	SpeakCurrentStep(context.Context, int) (dto.SpeechDto, error)
+	SpeakTimer(context.Context, int, int) (dto.SpeechDto, error)
+}
 
 type VoiceHandler struct {
-	config *config.Config
-	router *mux.Router
+	usecase       VoiceUsecase
+	router        *mux.Router
+	maxAudioSize  int64
+	serverTimeout time.Duration
+	speechTimeout time.Duration
+	ttsTimeout    time.Duration
+	intentTimeout time.Duration
 }
 
-func NewVoiceHandler(cfg *config.Config) *VoiceHandler {
-	return &VoiceHandler{cfg, mux.NewRouter()}
+func NewVoiceHandler(usecase VoiceUsecase, cfg *config.Config) *VoiceHandler {
+	return &VoiceHandler{
+		usecase:       usecase,
+		router:        mux.NewRouter(),
+		maxAudioSize:  int64(cfg.VoiceAudioMaxSize),
+		serverTimeout: cfg.ServerTimeout,
+		speechTimeout: cfg.SpeechTimeout,
+		ttsTimeout:    cfg.TTSTimeout,
+		intentTimeout: cfg.LLMVoiceKeyWaitTimeout + cfg.LLMVoice.Timeout,
+	}
 }
 
 func (h *VoiceHandler) InitRouter(r *mux.Router) {
 	h.router = r.PathPrefix("/voice").Subrouter()
 	{
 		h.router.Handle("", http.HandlerFunc(h.ServeHTTP)).Methods(http.MethodPost)
+		h.router.Handle("/command", http.HandlerFunc(h.ExecuteCommand)).Methods(http.MethodPost)
+		h.router.Handle("/session/{sessionID}/command",
+			http.HandlerFunc(h.ExecuteCommand)).Methods(http.MethodPost)
+		h.router.Handle("/speech/step", http.HandlerFunc(h.SpeakCurrentStep)).Methods(http.MethodGet)
+		h.router.Handle("/speech/timer/{timerID}", http.HandlerFunc(h.SpeakTimer)).Methods(http.MethodGet)
+		h.router.Handle("/session/{sessionID}/speech/step",
+			http.HandlerFunc(h.SpeakCurrentStep)).Methods(http.MethodGet)
+		h.router.Handle("/session/{sessionID}/speech/timer/{timerID}",
+			http.HandlerFunc(h.SpeakTimer)).Methods(http.MethodGet)
 	}
 }
 
 func (h *VoiceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	APIURL := h.config.DeepSeekAPIURL
-	APIKey := h.config.DeepSeekAPIKey
-	voiceData, err := dto.GetVoiceData(r)
+	voiceData, ok := h.getVoiceData(w, r)
+	if !ok {
+		return
+	}
+
+	defer closeVoiceAudio(voiceData)
+
+	id, err := h.usecase.RecognizeIntent(ctx, voiceData)
+
+	if err != nil {
+		switch {
+		case errors.Is(err, internalErrors.ErrVoiceTextEmpty):
+			utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "не найден text или не удалось распознать речь",
+			})
+		case errors.Is(err, internalErrors.ErrFailedToTranscribeAudio):
+			utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
+				Status: http.StatusInternalServerError,
+				Msg:    err.Error(),
+				MsgRus: "не получилось распознать речь",
+			})
+		default:
+			utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
+				Status: http.StatusInternalServerError,
+				Msg:    "internal server error",
+			})
+		}
+		return
+	}
+
+	utils.JSONResponse(ctx, w, 200, utils.SuccessResponse{
+		Status: 200,
+		Data:   id,
+	})
+}
+
+func (h *VoiceHandler) ExecuteCommand(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
 	if err != nil {
-		utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusBadRequest,
-			Msg:    "Bad Request",
-			MsgRus: "не найден text",
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
 		})
 		return
 	}
 
-	req, err := utils.BuildRequest(ctx, voiceData.Text, APIURL, APIKey, promptVoice)
+	voiceData, ok := h.getVoiceData(w, r)
+	if !ok {
+		return
+	}
+
+	defer closeVoiceAudio(voiceData)
 
+	command, err := h.usecase.ExecuteCommand(ctx, sessionIDParam, voiceData)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("Build request fail with text: %s , URL: %s", voiceData.Text, APIURL))
-		utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
-			Status: http.StatusInternalServerError,
-			Msg:    "internal server error",
+		switch {
+		case errors.Is(err, internalErrors.ErrUserNotAuth):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusUnauthorized,
+				Msg:    internalErrors.ErrUserNotAuth.Error(),
+				MsgRus: "пользователь не авторизован",
+			})
+		case errors.Is(err, internalErrors.ErrVoiceTextEmpty):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "не найден text или не удалось распознать речь",
+			})
+		case errors.Is(err, internalErrors.ErrFailedToTranscribeAudio):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusInternalServerError,
+				Msg:    err.Error(),
+				MsgRus: "не получилось распознать речь",
+			})
+		case errors.Is(err, internalErrors.ErrStepOutOfRange):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "такого шага нет в рецепте",
+			})
+		case errors.Is(err, internalErrors.ErrVoiceTimerLengthUnknown):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "у текущего шага нет длительности для таймера",
+			})
+		case errors.Is(err, internalErrors.ErrVoiceStepNumUnknown):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "не удалось понять номер шага",
+			})
+		case errors.Is(err, internalErrors.ErrVoiceNoTimers):
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "нет запущенных таймеров",
+			})
+		default:
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusInternalServerError,
+				Msg:    err.Error(),
+				MsgRus: "не получилось выполнить голосовую команду",
+			})
+		}
+		return
+	}
+
+	utils.JSONResponse(ctx, w, http.StatusOK, utils.SuccessResponse{
+		Status: http.StatusOK,
+		Data:   command,
+	})
+}
+
+func (h *VoiceHandler) SpeakCurrentStep(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	h.extendSpeechDeadline(w, r)
+
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
 		})
 		return
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	speech, err := h.usecase.SpeakCurrentStep(ctx, sessionIDParam)
+	if err != nil {
+		writeSpeechError(ctx, w, err)
+		return
+	}
+
+	writeSpeech(w, r, speech)
+}
+
+func (h *VoiceHandler) SpeakTimer(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	h.extendSpeechDeadline(w, r)
 
+	sessionIDParam, err := dto.GetSessionIDURLParam(r, sessionID)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("Request failed: %v", err))
-		utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
-			Status: http.StatusInternalServerError,
-			Msg:    "internal server error",
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр sessionID",
 		})
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		logger.Error(ctx, fmt.Sprintf("Do req fail with req: %v", req))
-		utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
-			Status: http.StatusInternalServerError,
-			Msg:    "internal server error",
+	timerIDParam, err := dto.GetIntURLParam(r, timerID)
+	if err != nil {
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "некорректный параметр timerID",
 		})
 		return
 	}
 
-	defer resp.Body.Close()
+	speech, err := h.usecase.SpeakTimer(ctx, sessionIDParam, timerIDParam)
+	if err != nil {
+		writeSpeechError(ctx, w, err)
+		return
+	}
 
-	id, err := dto.ConvertVoiceData(resp.Body)
+	writeSpeech(w, r, speech)
+}
 
-	if err != nil {
-		body, _ := io.ReadAll(resp.Body)
-		logger.Error(ctx, fmt.Sprintf("Unexpected status code: %d, body: %s", resp.StatusCode, string(body)))
-		utils.JSONResponse(ctx, w, 200, utils.ErrResponse{
+func writeSpeech(w http.ResponseWriter, r *http.Request, speech dto.SpeechDto) {
+	if closer, ok := speech.Audio.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	w.Header().Set("Content-Type", speech.ContentType)
+	http.ServeContent(w, r, speech.Name, time.Now(), speech.Audio)
+}
+
+func writeSpeechError(ctx context.Context, w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, internalErrors.ErrUserNotAuth):
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusUnauthorized,
+			Msg:    internalErrors.ErrUserNotAuth.Error(),
+			MsgRus: "пользователь не авторизован",
+		})
+	case errors.Is(err, internalErrors.ErrTimerNotFound):
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusNotFound,
+			Msg:    err.Error(),
+			MsgRus: "таймер не найден",
+		})
+	case errors.Is(err, internalErrors.ErrNothingToSpeak):
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    err.Error(),
+			MsgRus: "у шага нет текста для озвучки",
+		})
+	default:
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
 			Status: http.StatusInternalServerError,
-			Msg:    "internal server error",
+			Msg:    err.Error(),
+			MsgRus: "не получилось озвучить текст",
+		})
+	}
+}
+
+// extendSpeechDeadline дает синтезу речи TTSTimeout сверх таймаута сервера.
+func (h *VoiceHandler) extendSpeechDeadline(w http.ResponseWriter, r *http.Request) {
+	if err := utils.ExtendWriteDeadline(w, h.serverTimeout+h.ttsTimeout); err != nil {
+		logger.Error(r.Context(), fmt.Sprintf("failed to extend write deadline for speech: %v", err))
+	}
+}
+
+func (h *VoiceHandler) getVoiceData(w http.ResponseWriter, r *http.Request) (dto.VoiceDto, bool) {
+	ctx := r.Context()
+
+	// Ожидание ключа и запрос интента к LLM не укладываются в таймаут сервера, иначе при медленной модели
+	// клиент получит обрыв соединения вместо ответа правил. Загрузке и распознаванию аудио даем еще SpeechTimeout.
+	timeout := h.serverTimeout + h.intentTimeout
+
+	if dto.HasVoiceAudio(r) {
+		if err := utils.ExtendDeadlines(w, timeout+h.speechTimeout); err != nil {
+			logger.Error(ctx, fmt.Sprintf("failed to extend deadlines for voice audio: %v", err))
+		}
+	} else if err := utils.ExtendWriteDeadline(w, timeout); err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to extend write deadline for voice intent: %v", err))
+	}
+
+	voiceData, err := dto.GetVoiceData(w, r, h.maxAudioSize)
+	if err != nil {
+		if errors.Is(err, internalErrors.ErrInvalidAudio) {
+			utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+				Status: http.StatusBadRequest,
+				Msg:    err.Error(),
+				MsgRus: "аудио должно быть в формате WAV, OGG или WebM и не больше допустимого размера",
+			})
+			return dto.VoiceDto{}, false
+		}
+
+		utils.JSONResponse(ctx, w, http.StatusOK, utils.ErrResponse{
+			Status: http.StatusBadRequest,
+			Msg:    "Bad Request",
+			MsgRus: "не найден text",
 		})
+		return dto.VoiceDto{}, false
+	}
+
+	return voiceData, true
+}
+
+func closeVoiceAudio(voiceData dto.VoiceDto) {
+	if voiceData.Audio == nil {
 		return
 	}
 
-	logger.Info(ctx, fmt.Sprintf("Text: %s, recognize like: %v", voiceData.Text, id))
-	utils.JSONResponse(ctx, w, 200, utils.SuccessResponse{
-		Status: 200,
-		Data:   id,
-	})
+	if closer, ok := voiceData.Audio.Audio.(io.Closer); ok {
+		closer.Close()
+	}
 }
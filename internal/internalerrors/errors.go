@@ -71,4 +71,50 @@ var (
 	ErrGetZeroRowsWithPageGreaterThanOne = fmt.Errorf("get zero rows with page greater than one")
 	ErrGetCollections                    = fmt.Errorf("failed to get collections")
 	ErrAddRecipeToUserCookingHistory     = fmt.Errorf("failed to add recipe to user cooking history")
+	ErrFailedToExpireTimers              = fmt.Errorf("failed to expire timers")
+	ErrFailedToUpdateTimer               = fmt.Errorf("failed to update timer")
+	ErrTimerNotFound                     = fmt.Errorf("timer not found")
+	ErrInvalidTimerLength                = fmt.Errorf("invalid timer length")
+	ErrStepOutOfRange                    = fmt.Errorf("step out of recipe range")
+	ErrFailedToMarkStep                  = fmt.Errorf("failed to mark step")
+	ErrJoinCodeAlreadyExists             = fmt.Errorf("join code already exists")
+	ErrFailedToCreateJoinCode            = fmt.Errorf("failed to create join code")
+	ErrInvalidJoinCode                   = fmt.Errorf("invalid join code")
+	ErrFailedToJoinSession               = fmt.Errorf("failed to join cooking session")
+	ErrNotSessionOwner                   = fmt.Errorf("user is not the owner of cooking session")
+	ErrFailedToExpireSessions            = fmt.Errorf("failed to expire cooking sessions")
+	ErrFailedToSetAutoTimers             = fmt.Errorf("failed to set auto timers")
+	ErrFailedToUploadImage               = fmt.Errorf("failed to upload image")
+	ErrFailedToDeleteImage               = fmt.Errorf("failed to delete image")
+	ErrInvalidRating                     = fmt.Errorf("rating must be between 1 and 5")
+	ErrNotesTooLong                      = fmt.Errorf("notes are too long")
+	ErrInvalidImage                      = fmt.Errorf("invalid image")
+	ErrFailedToGetPrepChecklist          = fmt.Errorf("failed to get prep checklist")
+	ErrFailedToCheckPrepItem             = fmt.Errorf("failed to check prep item")
+	ErrPrepItemNotFound                  = fmt.Errorf("prep item not found")
+	ErrFailedToRecognizeVoice            = fmt.Errorf("failed to recognize voice command")
+	ErrVoiceTimerLengthUnknown           = fmt.Errorf("timer length is unknown for voice command")
+	ErrVoiceNoTimers                     = fmt.Errorf("no timers to stop")
+	ErrVoiceStepNumUnknown               = fmt.Errorf("step number is unknown for voice command")
+	ErrInvalidAudio                      = fmt.Errorf("invalid audio, expected wav, ogg or webm")
+	ErrFailedToTranscribeAudio           = fmt.Errorf("failed to transcribe audio")
+	ErrVoiceTextEmpty                    = fmt.Errorf("voice command text is empty")
+	ErrFailedToSynthesizeSpeech          = fmt.Errorf("failed to synthesize speech")
+	ErrSpeechNotCached                   = fmt.Errorf("speech is not cached")
+	ErrFailedToCacheSpeech               = fmt.Errorf("failed to cache speech")
+	ErrNothingToSpeak                    = fmt.Errorf("nothing to speak")
+	ErrGenerationJobNotFound             = fmt.Errorf("generation job not found")
+	ErrFailedToCreateGenerationJob       = fmt.Errorf("failed to create generation job")
+	ErrFailedToGetGenerationJob          = fmt.Errorf("failed to get generation job")
+	ErrFailedToUpdateGenerationJob       = fmt.Errorf("failed to update generation job")
+	ErrNoGenerationJobs                  = fmt.Errorf("no pending generation jobs")
+	ErrGenerationQuotaExceeded           = fmt.Errorf("generation quota exceeded")
+	ErrFailedToGetLLMUsage               = fmt.Errorf("failed to get llm usage")
+	ErrFailedToRecordLLMUsage            = fmt.Errorf("failed to record llm usage")
+	ErrFailedToDiffVersions              = fmt.Errorf("failed to diff recipe versions")
+	ErrBadVersionID                      = fmt.Errorf("version must be a positive integer")
+	ErrWithForkRecipe                    = fmt.Errorf("failed to fork recipe")
+	ErrTooManyJoinAttempts               = fmt.Errorf("too many attempts to join cooking session")
+	ErrFailedToCheckJoinAttempts         = fmt.Errorf("failed to check join attempts")
+	ErrFailedToClearJoinCodes            = fmt.Errorf("failed to clear expired join codes")
 )
@@ -23,12 +23,16 @@ func NewCookingHistoryRepo(adapter *postgres.Adapter) *CookingHistoryRepo {
 
 func (r *CookingHistoryRepo) GetRecipesFromHistory(
 	ctx context.Context, uID uint, page int) ([]models.RecipeModel, error) {
-	q := `SELECT r.id, r.name, r.description, r.image, r.ready_in_minutes, uch.is_generated, uch.created_at
+	q := `SELECT r.id, r.name, r.description, r.image, r.ready_in_minutes, uch.is_generated, uch.created_at,
+		  COALESCE(uch.session_id, 0) AS session_id, COALESCE(uch.status, '') AS status,
+		  COALESCE(uch.rating, 0) AS rating, COALESCE(uch.notes, '') AS notes, COALESCE(uch.photo, '') AS photo
 		  FROM public.recipes r
 		  JOIN public.user_cooking_history uch ON r.id = uch.recipe_id
 		  WHERE uch.user_id = $1 AND uch.is_generated = false
 		  UNION ALL
-		  SELECT gr.id, gr.name, gr.description, 'null', gr.ready_in_minutes, uch.is_generated, uch.created_at
+		  SELECT gr.id, gr.name, gr.description, 'null', gr.ready_in_minutes, uch.is_generated, uch.created_at,
+		  COALESCE(uch.session_id, 0) AS session_id, COALESCE(uch.status, '') AS status,
+		  COALESCE(uch.rating, 0) AS rating, COALESCE(uch.notes, '') AS notes, COALESCE(uch.photo, '') AS photo
 		  FROM public.generated_recipes gr
 	      JOIN public.user_cooking_history uch ON gr.id = uch.recipe_id
 		  WHERE uch.user_id = $1 AND uch.is_generated = true ORDER BY created_at DESC LIMIT $2 OFFSET $3;`
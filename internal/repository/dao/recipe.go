@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
@@ -28,6 +29,12 @@ type RecipeTable struct {
 	UserIngredients json.RawMessage `db:"user_ingredients" json:"user_ingredients,omitempty"`
 	IsGenerated     bool            `db:"is_generated" json:"is_generated,omitempty"`
 	CreatedAt       time.Time       `db:"created_at" json:"created_at,omitempty"`
+	SessionID       int             `db:"session_id" json:"session_id,omitempty"`
+	Status          string          `db:"status" json:"status,omitempty"`
+	Rating          int             `db:"rating" json:"rating,omitempty"`
+	Notes           string          `db:"notes" json:"notes,omitempty"`
+	Photo           string          `db:"photo" json:"photo,omitempty"`
+	ParentVersion   sql.NullInt64   `db:"parent_version" json:"-"`
 }
 
 type MainPageRecipeTable struct {
@@ -45,15 +52,22 @@ type CollectionTable struct {
 }
 
 type CurrentRecipeTable struct {
-	ID          int             `db:"recipe_id"`
-	Name        string          `db:"name"`
-	NumStep     int             `db:"step_num"`
-	Step        string          `db:"step"`
-	TotalSteps  int             `db:"total_steps"`
-	Ingredients json.RawMessage `db:"ingredients"`
-	Equipment   json.RawMessage `db:"equipment"`
-	Length      json.RawMessage `db:"length"`
-	IsGenerated bool            `db:"is_generated"`
+	SessionID         int             `db:"session_id"`
+	IsDefault         bool            `db:"is_default"`
+	ID                int             `db:"recipe_id"`
+	Name              string          `db:"name"`
+	NumStep           int             `db:"step_num"`
+	Step              string          `db:"step"`
+	TotalSteps        int             `db:"total_steps"`
+	Servings          int             `db:"servings"`
+	RecipeIngredients json.RawMessage `db:"recipe_ingredients"`
+	JoinCode          string          `db:"join_code"`
+	IsGuest           bool            `db:"is_guest"`
+	AutoTimers        bool            `db:"auto_timers"`
+	Ingredients       json.RawMessage `db:"ingredients"`
+	Equipment         json.RawMessage `db:"equipment"`
+	Length            json.RawMessage `db:"length"`
+	IsGenerated       bool            `db:"is_generated"`
 }
 
 type CurrentRecipeStepTable struct {
@@ -65,9 +79,15 @@ type CurrentRecipeStepTable struct {
 }
 
 type TimerTable struct {
-	StepNum     int       `db:"step_num"`
-	Description string    `db:"description"`
-	EndTime     time.Time `db:"end_time"`
+	ID           int       `db:"timer_id"`
+	SessionID    int       `db:"session_id"`
+	StepNum      int       `db:"step_num"`
+	Description  string    `db:"description"`
+	Label        string    `db:"label"`
+	EndTime      time.Time `db:"end_time"`
+	IsPaused     bool      `db:"is_paused"`
+	IsAuto       bool      `db:"is_auto"`
+	RemainingSec int       `db:"remaining_sec"`
 }
 
 type LengthTimer struct {
@@ -99,11 +119,49 @@ type GeneratedRecipe struct {
 	UserIngredients json.RawMessage `db:"user_ingredients" json:"user_ingredient"`
 }
 
+type GenerationJobTable struct {
+	ID         int            `db:"id"`
+	UserID     uint           `db:"user_id"`
+	Kind       string         `db:"kind"`
+	Status     string         `db:"status"`
+	Query      string         `db:"query"`
+	Products   []byte         `db:"products"`
+	Fresh      bool           `db:"fresh"`
+	RecipeID   sql.NullInt64  `db:"recipe_id"`
+	VersionID  sql.NullInt64  `db:"version_id"`
+	Result     sql.NullString `db:"result"`
+	Error      sql.NullString `db:"error"`
+	Attempts   int            `db:"attempts"`
+	CreatedAt  time.Time      `db:"created_at"`
+	StartedAt  sql.NullTime   `db:"started_at"`
+	FinishedAt sql.NullTime   `db:"finished_at"`
+}
+
+type GenerationUsageTable struct {
+	DailyUsed      int       `db:"daily_used"`
+	MonthlyUsed    int       `db:"monthly_used"`
+	DailyResetAt   time.Time `db:"daily_reset_at"`
+	MonthlyResetAt time.Time `db:"monthly_reset_at"`
+}
+
+func ConvertGenerationUsageToModel(usage GenerationUsageTable) models.GenerationUsageModel {
+	return models.GenerationUsageModel{
+		DailyUsed:      usage.DailyUsed,
+		MonthlyUsed:    usage.MonthlyUsed,
+		DailyResetAt:   usage.DailyResetAt,
+		MonthlyResetAt: usage.MonthlyResetAt,
+	}
+}
+
 func ConvertTimerToDAO(tt []TimerTable) ([]models.TimerRecipeModel, error) {
 	timers := make([]models.TimerRecipeModel, len(tt))
 	for i, timer := range tt {
 		diff := int(math.Round(time.Until(timer.EndTime).Seconds()))
 
+		if timer.IsPaused {
+			diff = timer.RemainingSec
+		}
+
 		if diff < 0 {
 			diff = 0
 		}
@@ -119,9 +177,14 @@ func ConvertTimerToDAO(tt []TimerTable) ([]models.TimerRecipeModel, error) {
 		}
 
 		timers[i] = models.TimerRecipeModel{
-			Step:    timer.Description,
-			Length:  jsonLength,
-			StepNum: timer.StepNum,
+			ID:        timer.ID,
+			SessionID: timer.SessionID,
+			Label:     timer.Label,
+			IsPaused:  timer.IsPaused,
+			IsAuto:    timer.IsAuto,
+			Step:      timer.Description,
+			Length:    jsonLength,
+			StepNum:   timer.StepNum,
 		}
 	}
 	return timers, nil
@@ -129,10 +192,17 @@ func ConvertTimerToDAO(tt []TimerTable) ([]models.TimerRecipeModel, error) {
 
 func ConvertDaoToCurrentRecipe(cr CurrentRecipeTable) models.CurrentRecipeModel {
 	return models.CurrentRecipeModel{
+		SessionID:   cr.SessionID,
+		IsDefault:   cr.IsDefault,
 		ID:          cr.ID,
 		Name:        cr.Name,
 		TotalSteps:  cr.TotalSteps,
 		IsGenerated: cr.IsGenerated,
+		Servings:    cr.Servings,
+		Ingredients: cr.RecipeIngredients,
+		JoinCode:    cr.JoinCode,
+		IsGuest:     cr.IsGuest,
+		AutoTimers:  cr.AutoTimers,
 		CurrentStep: models.CurrentStepRecipeModel{
 			NumStep:     cr.NumStep,
 			Step:        cr.Step,
@@ -197,21 +267,27 @@ func ConvertDaoToRecipe(rt []RecipeTable) []models.RecipeModel {
 	RecipeItems := make([]models.RecipeModel, 0, len(rt))
 	for _, r := range rt {
 		RecipeItems = append(RecipeItems, models.RecipeModel{
-			ID:          r.ID,
-			Name:        r.Name,
-			Desc:        r.Desc,
-			Img:         r.Img,
-			CookingTime: r.CookingTime,
-			ServingsNum: r.ServingsNum,
-			Steps:       r.Steps,
-			HealthScore: r.HealthScore,
-			Diets:       string(r.Diets),
-			DishTypes:   string(r.DishTypes),
-			Version:     r.Version,
-			Query:       r.Query,
-			Ingredients: r.Ingredients,
-			IsGenerated: r.IsGenerated,
-			CreatedAt:   r.CreatedAt,
+			ID:            r.ID,
+			Name:          r.Name,
+			Desc:          r.Desc,
+			Img:           r.Img,
+			CookingTime:   r.CookingTime,
+			ServingsNum:   r.ServingsNum,
+			Steps:         r.Steps,
+			HealthScore:   r.HealthScore,
+			Diets:         string(r.Diets),
+			DishTypes:     string(r.DishTypes),
+			Version:       r.Version,
+			Query:         r.Query,
+			Ingredients:   r.Ingredients,
+			IsGenerated:   r.IsGenerated,
+			CreatedAt:     r.CreatedAt,
+			SessionID:     r.SessionID,
+			Status:        r.Status,
+			Rating:        r.Rating,
+			Notes:         r.Notes,
+			Photo:         r.Photo,
+			ParentVersion: int(r.ParentVersion.Int64),
 		})
 	}
 	return RecipeItems
@@ -254,6 +330,10 @@ func ConvertModelToDao(rm []models.RecipeModel) []RecipeTable {
 }
 
 func ParseGeneratedRecipe(rawData json.RawMessage) (GeneratedRecipe, error) {
+	if err := ValidateGeneratedRecipe(rawData); err != nil {
+		return GeneratedRecipe{}, err
+	}
+
 	var recipe GeneratedRecipe
 	err := json.Unmarshal(rawData, &recipe)
 	if err != nil {
@@ -292,3 +372,106 @@ func ConvertCollectionTableToModel(collectionTable []CollectionTable) []models.C
 	}
 	return recipeItems
 }
+
+// FillStepsIngredientsAmount проставляет ингредиентам шагов количество и единицы из списка ингредиентов рецепта.
+// Ингредиенты сопоставляются по id, а если его нет - по названию.
+func FillStepsIngredientsAmount(steps []CurrentRecipeStepTable, ingredients json.RawMessage) error {
+	if len(ingredients) == 0 {
+		return nil
+	}
+
+	var recipeIngredients []IngredientTable
+
+	if err := json.Unmarshal(ingredients, &recipeIngredients); err != nil {
+		return fmt.Errorf("failed to unmarshal recipe ingredients: %w", err)
+	}
+
+	byID := make(map[int]IngredientTable, len(recipeIngredients))
+	byName := make(map[string]IngredientTable, len(recipeIngredients))
+
+	for _, ingredient := range recipeIngredients {
+		if ingredient.IngredientID != 0 {
+			byID[ingredient.IngredientID] = ingredient
+		}
+		byName[strings.ToLower(strings.TrimSpace(ingredient.Name))] = ingredient
+	}
+
+	for i, step := range steps {
+		if len(step.Ingredients) == 0 {
+			continue
+		}
+
+		var stepIngredients []map[string]interface{}
+
+		if err := json.Unmarshal(step.Ingredients, &stepIngredients); err != nil {
+			return fmt.Errorf("failed to unmarshal ingredients of step %d: %w", step.NumStep, err)
+		}
+
+		for _, stepIngredient := range stepIngredients {
+			ingredient, ok := findStepIngredient(stepIngredient, byID, byName)
+			if !ok {
+				continue
+			}
+			stepIngredient["amount"] = ingredient.Amount
+			stepIngredient["unit"] = ingredient.Unit
+		}
+
+		stepIngredientsJSON, err := json.Marshal(stepIngredients)
+		if err != nil {
+			return fmt.Errorf("failed to marshal ingredients of step %d: %w", step.NumStep, err)
+		}
+
+		steps[i].Ingredients = stepIngredientsJSON
+	}
+
+	return nil
+}
+
+func findStepIngredient(stepIngredient map[string]interface{}, byID map[int]IngredientTable,
+	byName map[string]IngredientTable) (IngredientTable, bool) {
+	if id, ok := stepIngredient["id"].(float64); ok {
+		if ingredient, ok := byID[int(id)]; ok {
+			return ingredient, true
+		}
+	}
+
+	for _, key := range []string{"name", "localizedName"} {
+		name, ok := stepIngredient[key].(string)
+		if !ok {
+			continue
+		}
+		if ingredient, ok := byName[strings.ToLower(strings.TrimSpace(name))]; ok {
+			return ingredient, true
+		}
+	}
+
+	return IngredientTable{}, false
+}
+
+func ConvertGenerationJobToModel(job GenerationJobTable) (models.GenerationJobModel, error) {
+	products := make([]string, 0)
+
+	if len(job.Products) > 0 {
+		if err := json.Unmarshal(job.Products, &products); err != nil {
+			return models.GenerationJobModel{}, err
+		}
+	}
+
+	return models.GenerationJobModel{
+		ID:         job.ID,
+		UserID:     job.UserID,
+		Kind:       job.Kind,
+		Status:     job.Status,
+		Query:      job.Query,
+		Products:   products,
+		Fresh:      job.Fresh,
+		RecipeID:   int(job.RecipeID.Int64),
+		VersionID:  int(job.VersionID.Int64),
+		Result:     []byte(job.Result.String),
+		Error:      job.Error.String,
+		Attempts:   job.Attempts,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt.Time,
+		FinishedAt: job.FinishedAt.Time,
+	}, nil
+}
@@ -0,0 +1,316 @@
+package dao
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// Сумма длительностей шагов может отличаться от readyInMinutes на 30%, но не меньше чем на 10 минут.
+	readyInMinutesTolerance    = 0.3
+	readyInMinutesMinTolerance = 10
+)
+
+// RecipeValidationError содержит все найденные в ответе модели нарушения схемы рецепта.
+type RecipeValidationError struct {
+	Problems []string
+}
+
+func (e *RecipeValidationError) Error() string {
+	return "invalid generated recipe: " + strings.Join(e.Problems, "; ")
+}
+
+type recipeValidator struct {
+	problems []string
+}
+
+func (v *recipeValidator) addf(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func jsonKind(raw json.RawMessage) string {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return "missing"
+	}
+
+	switch raw[0] {
+	case '"':
+		return "string"
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "boolean"
+	case 'n':
+		return "null"
+	default:
+		return "number"
+	}
+}
+
+func (v *recipeValidator) object(path string, raw json.RawMessage) (map[string]json.RawMessage, bool) {
+	var obj map[string]json.RawMessage
+
+	if jsonKind(raw) != "object" || json.Unmarshal(raw, &obj) != nil {
+		v.addf("%s: expected object, got %s", path, jsonKind(raw))
+		return nil, false
+	}
+
+	return obj, true
+}
+
+func (v *recipeValidator) array(path string, raw json.RawMessage, required bool) ([]json.RawMessage, bool) {
+	if !required && (jsonKind(raw) == "missing" || jsonKind(raw) == "null") {
+		return nil, true
+	}
+
+	var arr []json.RawMessage
+
+	if jsonKind(raw) != "array" || json.Unmarshal(raw, &arr) != nil {
+		v.addf("%s: expected array, got %s", path, jsonKind(raw))
+		return nil, false
+	}
+
+	return arr, true
+}
+
+func (v *recipeValidator) str(path string, raw json.RawMessage, required bool) (string, bool) {
+	var value string
+
+	if jsonKind(raw) != "string" || json.Unmarshal(raw, &value) != nil {
+		v.addf("%s: expected string, got %s", path, jsonKind(raw))
+		return "", false
+	}
+
+	if required && strings.TrimSpace(value) == "" {
+		v.addf("%s: must not be empty", path)
+		return "", false
+	}
+
+	return value, true
+}
+
+func (v *recipeValidator) number(path string, raw json.RawMessage) (float64, bool) {
+	var value float64
+
+	if jsonKind(raw) != "number" || json.Unmarshal(raw, &value) != nil {
+		v.addf("%s: expected number, got %s", path, jsonKind(raw))
+		return 0, false
+	}
+
+	return value, true
+}
+
+func (v *recipeValidator) integer(path string, raw json.RawMessage, minValue int) (int, bool) {
+	value, ok := v.number(path, raw)
+	if !ok {
+		return 0, false
+	}
+
+	if value != math.Trunc(value) {
+		v.addf("%s: expected integer, got %v", path, value)
+		return 0, false
+	}
+
+	if int(value) < minValue {
+		v.addf("%s: must be at least %d, got %d", path, minValue, int(value))
+		return 0, false
+	}
+
+	return int(value), true
+}
+
+func (v *recipeValidator) stringArray(path string, raw json.RawMessage) {
+	items, ok := v.array(path, raw, true)
+	if !ok {
+		return
+	}
+
+	for i, item := range items {
+		v.str(fmt.Sprintf("%s[%d]", path, i), item, true)
+	}
+}
+
+func (v *recipeValidator) ingredients(raw json.RawMessage) {
+	items, ok := v.array("ingredients", raw, true)
+	if !ok {
+		return
+	}
+
+	if len(items) == 0 {
+		v.addf("ingredients: must not be empty")
+	}
+
+	for i, item := range items {
+		path := fmt.Sprintf("ingredients[%d]", i)
+
+		ingredient, ok := v.object(path, item)
+		if !ok {
+			continue
+		}
+
+		if id, ok := ingredient["id"]; ok {
+			v.integer(path+".id", id, 0)
+		}
+
+		v.str(path+".name", ingredient["name"], true)
+		if amount, ok := v.number(path+".amount", ingredient["amount"]); ok && amount < 0 {
+			v.addf("%s.amount: must not be negative", path)
+		}
+		v.str(path+".unit", ingredient["unit"], false)
+	}
+}
+
+func (v *recipeValidator) stepItems(path string, raw json.RawMessage) {
+	items, ok := v.array(path, raw, false)
+	if !ok {
+		return
+	}
+
+	for i, item := range items {
+		itemPath := fmt.Sprintf("%s[%d]", path, i)
+
+		stepItem, ok := v.object(itemPath, item)
+		if !ok {
+			continue
+		}
+
+		v.str(itemPath+".name", stepItem["name"], true)
+		if localizedName, ok := stepItem["localizedName"]; ok {
+			v.str(itemPath+".localizedName", localizedName, false)
+		}
+	}
+}
+
+// stepLength возвращает длительность шага в минутах, false - если длительность не указана.
+func (v *recipeValidator) stepLength(path string, raw json.RawMessage) (float64, bool) {
+	if jsonKind(raw) == "missing" || jsonKind(raw) == "null" {
+		return 0, false
+	}
+
+	length, ok := v.object(path, raw)
+	if !ok {
+		return 0, false
+	}
+
+	number, ok := v.number(path+".number", length["number"])
+	if !ok {
+		return 0, false
+	}
+
+	if number <= 0 {
+		v.addf("%s.number: must be positive", path)
+		return 0, false
+	}
+
+	unit, ok := v.str(path+".unit", length["unit"], true)
+	if !ok {
+		return 0, false
+	}
+
+	switch strings.ToLower(unit) {
+	case "minutes", "minute", "min":
+		return number, true
+	case "hours", "hour":
+		return number * 60, true
+	case "seconds", "second", "sec":
+		return number / 60, true
+	default:
+		v.addf("%s.unit: expected minutes, got %q", path, unit)
+		return 0, false
+	}
+}
+
+// steps проверяет шаги и возвращает их количество, сумму длительностей и признак того, что длительность есть у всех.
+func (v *recipeValidator) steps(raw json.RawMessage) (int, float64, bool) {
+	items, ok := v.array("steps", raw, true)
+	if !ok {
+		return 0, 0, false
+	}
+
+	if len(items) == 0 {
+		v.addf("steps: must not be empty")
+	}
+
+	var total float64
+	allTimed := true
+
+	for i, item := range items {
+		path := fmt.Sprintf("steps[%d]", i)
+
+		step, ok := v.object(path, item)
+		if !ok {
+			allTimed = false
+			continue
+		}
+
+		if number, ok := v.integer(path+".number", step["number"], 1); ok && number != i+1 {
+			v.addf("%s.number: expected %d, got %d", path, i+1, number)
+		}
+
+		v.str(path+".step", step["step"], true)
+		v.stepItems(path+".ingredients", step["ingredients"])
+		v.stepItems(path+".equipment", step["equipment"])
+
+		minutes, ok := v.stepLength(path+".length", step["length"])
+		if !ok {
+			allTimed = false
+		}
+		total += minutes
+	}
+
+	return len(items), total, allTimed
+}
+
+func (v *recipeValidator) readyInMinutes(readyInMinutes int, stepsMinutes float64, allTimed bool) {
+	tolerance := math.Max(float64(readyInMinutes)*readyInMinutesTolerance, readyInMinutesMinTolerance)
+
+	if stepsMinutes > float64(readyInMinutes)+tolerance {
+		v.addf("readyInMinutes: steps take %.0f minutes in total, but readyInMinutes is %d",
+			stepsMinutes, readyInMinutes)
+	}
+
+	if allTimed && stepsMinutes < float64(readyInMinutes)-tolerance {
+		v.addf("readyInMinutes: steps take only %.0f minutes in total, but readyInMinutes is %d",
+			stepsMinutes, readyInMinutes)
+	}
+}
+
+// ValidateGeneratedRecipe проверяет ответ модели по схеме рецепта: обязательные поля, числовые типы,
+// нумерацию шагов, totalSteps и соответствие суммы длительностей шагов readyInMinutes.
+func ValidateGeneratedRecipe(rawData json.RawMessage) error {
+	v := &recipeValidator{}
+
+	recipe, ok := v.object("recipe", rawData)
+	if !ok {
+		return &RecipeValidationError{Problems: v.problems}
+	}
+
+	v.str("name", recipe["name"], true)
+	v.str("description", recipe["description"], true)
+	v.integer("servingsNum", recipe["servingsNum"], 1)
+	v.stringArray("dishTypes", recipe["dishTypes"])
+	v.stringArray("diets", recipe["diets"])
+	v.ingredients(recipe["ingredients"])
+
+	stepsCount, stepsMinutes, allTimed := v.steps(recipe["steps"])
+
+	if totalSteps, ok := v.integer("totalSteps", recipe["totalSteps"], 1); ok && totalSteps != stepsCount {
+		v.addf("totalSteps: expected %d (number of steps), got %d", stepsCount, totalSteps)
+	}
+
+	if readyInMinutes, ok := v.integer("readyInMinutes", recipe["readyInMinutes"], 1); ok {
+		v.readyInMinutes(readyInMinutes, stepsMinutes, allTimed)
+	}
+
+	if len(v.problems) > 0 {
+		return &RecipeValidationError{Problems: v.problems}
+	}
+
+	return nil
+}
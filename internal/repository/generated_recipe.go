@@ -2,15 +2,21 @@ package repository
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
-	"sync"
 
+	redigo "github.com/gomodule/redigo/redis"
 	"github.com/jmoiron/sqlx"
 
 	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
 	"github.com/Olegsandrik/Exponenta/internal/adapters/postgres"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/redis"
 	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 	"github.com/Olegsandrik/Exponenta/internal/repository/dao"
 	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
@@ -38,7 +44,7 @@ const (
 	{
 	  "name": "Название блюда (строка)",
 	  "description": "Краткое описание (2-3 предложения)",
-	  "servingsNum": "Количество порций (целое число)",
+	  "servingsNum": 2, // количество порций, целое число
 	  "dishTypes": ["Тип блюда 1", "Тип блюда 2"],
 	  "diets": ["Тип диеты 1", "Тип диеты 2"],
 	  "ingredients": [
@@ -55,8 +61,8 @@ const (
 		  "unit": "шт/г/мл/ч.л/ст.л"
 		}
 	  ],
-	  "totalSteps": "Общее количество шагов",
-	  "readyInMinutes": "Общее время готовки (целое число минут)",
+	  "totalSteps": 2, // количество шагов, равно длине steps
+	  "readyInMinutes": 10, // общее время готовки в минутах, целое число
 	  "steps": [
 		{
 		  "number": 1,
@@ -120,7 +126,7 @@ const (
 	{
 	  "name": "Название блюда (строка)",
 	  "description": "Краткое описание (2-3 предложения)",
-	  "servingsNum": "Количество порций (целое число)",
+	  "servingsNum": 2, // количество порций, целое число
 	  "dishTypes": ["Тип блюда 1", "Тип блюда 2"],
 	  "diets": ["Тип диеты 1", "Тип диеты 2"],
 	  "ingredients": [
@@ -137,8 +143,8 @@ const (
 		  "unit": "шт/г/мл/ч.л/ст.л"
 		}
 	  ],
-	  "totalSteps": "Общее количество шагов",
-	  "readyInMinutes": "Общее время готовки (целое число минут)",
+	  "totalSteps": 2, // количество шагов, равно длине steps
+	  "readyInMinutes": 10, // общее время готовки в минутах, целое число
 	  "steps": [
 		{
 		  "number": 1,
@@ -192,70 +198,42 @@ const (
 	не съедобны или являются алкоголем, то просто верни изначальный рецепт.
 	
 	Важно: сохрани все поля исходного JSON, даже если не вносил изменения!`
-)
-
-type Key struct {
-	Value  string
-	IsUsed bool
-}
-
-type KeysPool struct {
-	Keys []Key
-	Mu   sync.Mutex
-}
-
-type GeneratedRecipeRepo struct {
-	storage  *postgres.Adapter
-	config   *config.Config
-	keysPool *KeysPool
-}
 
-func NewGeneratedRecipeRepo(storage *postgres.Adapter, config *config.Config) *GeneratedRecipeRepo {
-	keysPool := NewKeysPool([]string{
-		config.DeepSeekAPIKey2,
-		config.DeepSeekAPIKey3,
-		config.DeepSeekAPIKey4,
-		config.DeepSeekAPIKey5,
-	})
+	generationCacheEntity = "generation"
+	versionInsertAttempts = 3
 
-	return &GeneratedRecipeRepo{
-		storage:  storage,
-		config:   config,
-		keysPool: keysPool,
-	}
-}
+	promptRepair = `%s
 
-func NewKeysPool(Keys []string) *KeysPool {
-	keys := make([]Key, 0, len(Keys))
+	Твой предыдущий ответ:
+	%s
 
-	for _, key := range Keys {
-		keys = append(keys, Key{Value: key, IsUsed: false})
-	}
+	Он не прошел проверку схемы рецепта. Ошибки:
+	- %s
 
-	return &KeysPool{
-		Keys: keys,
-		Mu:   sync.Mutex{},
-	}
-}
+	Исправь ошибки и пришли рецепт целиком в том же JSON-формате, без пояснений.`
+)
 
-func (repo *GeneratedRecipeRepo) RefreshKeyByID(ID int) {
-	repo.keysPool.Mu.Lock()
-	defer repo.keysPool.Mu.Unlock()
-	repo.keysPool.Keys[ID].IsUsed = false
+type GeneratedRecipeRepo struct {
+	storage *postgres.Adapter
+	cache   *redis.Adapter
+	config  *config.Config
+	llm     LLMProvider
+	keys    *KeyPool
 }
 
-func (repo *GeneratedRecipeRepo) GetKey() (string, int, error) {
-	repo.keysPool.Mu.Lock()
-	defer repo.keysPool.Mu.Unlock()
-
-	for idx := range repo.keysPool.Keys {
-		if !repo.keysPool.Keys[idx].IsUsed {
-			repo.keysPool.Keys[idx].IsUsed = true
-			return repo.keysPool.Keys[idx].Value, idx, nil
-		}
+func NewGeneratedRecipeRepo(storage *postgres.Adapter, cache *redis.Adapter, config *config.Config,
+	llmProvider LLMProvider, keyPool *KeyPool) *GeneratedRecipeRepo {
+	return &GeneratedRecipeRepo{
+		storage: storage,
+		cache:   cache,
+		config:  config,
+		llm:     llmProvider,
+		keys:    keyPool,
 	}
+}
 
-	return "", 0, internalErrors.ErrAllKeysAreUsing
+func (repo *GeneratedRecipeRepo) GetKeysStats() models.LLMKeysStatsModel {
+	return repo.keys.Stats()
 }
 
 func (repo *GeneratedRecipeRepo) GetAllRecipes(ctx context.Context, num int,
@@ -330,8 +308,8 @@ func (repo *GeneratedRecipeRepo) GetRecipeByID(ctx context.Context, recipeID int
 func (repo *GeneratedRecipeRepo) GetHistoryByID(ctx context.Context, recipeID int,
 	userID uint) ([]models.RecipeModel, error) {
 	q := `SELECT r.id, r.ingredients, r.ready_in_minutes, r.version, r.name, r.description, r.steps, r.dish_types, 
-       r.diets, r.servings, r.total_steps, 
-       r.query FROM public.generated_recipes_versions as r WHERE user_id = $1 AND id = $2`
+       r.diets, r.servings, r.total_steps, r.query, r.parent_version
+       FROM public.generated_recipes_versions as r WHERE user_id = $1 AND id = $2 ORDER BY r.version`
 
 	var recipeRows []dao.RecipeTable
 
@@ -350,98 +328,242 @@ func (repo *GeneratedRecipeRepo) GetHistoryByID(ctx context.Context, recipeID in
 	return recipeModels, nil
 }
 
-func (repo *GeneratedRecipeRepo) CreateRecipe(ctx context.Context, products []string, query string,
-	userID uint) ([]models.RecipeModel, error) {
-	APIURL := repo.config.DeepSeekAPIURL
-	APIKey, APIKeyID, err := repo.GetKey()
+// CreateRecipe сначала ищет готовый рецепт для тех же продуктов и пожеланий в кеше и копирует его
+// пользователю. Если fresh, кеш пропускается и рецепт генерируется заново. jobID и jobAttempt - задача
+// генерации и ее попытка, они попадают в журнал использования модели.
+func (repo *GeneratedRecipeRepo) CreateRecipe(ctx context.Context, products []string, query string, fresh bool,
+	userID uint, jobID int, jobAttempt int) ([]models.RecipeModel, error) {
+	return repo.createRecipe(ctx, products, query, fresh, llm.Request{
+		UserID:     userID,
+		JobID:      jobID,
+		JobAttempt: jobAttempt,
+	}, nil)
+}
 
-	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("no free keys now: %v", err))
-		return nil, err
+// CreateRecipeStream генерирует рецепт так же, как CreateRecipe, но отдает ответ модели в onChunk по мере генерации.
+// jobID - строка задачи, которая резервирует квоту на время стрима.
+func (repo *GeneratedRecipeRepo) CreateRecipeStream(ctx context.Context, products []string, query string, fresh bool,
+	userID uint, jobID int, onChunk func(chunk models.GenerationChunkModel) error) ([]models.RecipeModel, error) {
+	return repo.createRecipe(ctx, products, query, fresh, llm.Request{UserID: userID, JobID: jobID, JobAttempt: 1},
+		onChunk)
+}
+
+// createRecipe генерирует рецепт запросом req, в котором заполнены только поля для учета использования.
+func (repo *GeneratedRecipeRepo) createRecipe(ctx context.Context, products []string, query string, fresh bool,
+	req llm.Request, onChunk func(chunk models.GenerationChunkModel) error) ([]models.RecipeModel, error) {
+	userID := req.UserID
+	cacheKey := repo.generationCacheKey(products, query)
+
+	var generatedRecipe dao.GeneratedRecipe
+	var found bool
+
+	if !fresh {
+		generatedRecipe, found = repo.getCachedRecipe(ctx, cacheKey)
 	}
 
-	defer repo.RefreshKeyByID(APIKeyID)
+	if !found {
+		q := fmt.Sprintf("my promise: %s, products: %s", query, strings.Join(products, ", "))
 
-	q := fmt.Sprintf("my promise: %s, products: %s", query, strings.Join(products, ", "))
+		var err error
 
-	respData, err := utils.GetResponseData(ctx, q, APIURL, APIKey, promptChoiceGeneration)
+		req.UseCase = llm.UseCaseGeneration
+		req.Prompt = promptChoiceGeneration
+		req.Input = q
+		req.Params = repo.config.LLMGeneration
 
-	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf("failed to get resp data: %+v for userId: %d, query: %s, promptChoice: %s",
-				err, userID, query, promptChoiceGeneration),
-		)
-		return nil, internalErrors.ErrWithGenerating
-	}
+		generatedRecipe, err = repo.completeRecipe(ctx, req, onChunk)
 
-	generatedRecipe, err := dao.ParseGeneratedRecipe(json.RawMessage(respData))
+		if errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
+			return nil, err
+		}
+
+		if err != nil {
+			logger.Error(ctx,
+				fmt.Sprintf("failed to generate recipe: %+v for userId: %d, query: %s, products: %s",
+					err, userID, query, products),
+			)
+			return nil, internalErrors.ErrWithGenerating
+		}
+
+		repo.cacheRecipe(ctx, cacheKey, generatedRecipe)
+	}
 
 	generatedRecipe.Query = query
 	jsonProducts, _ := json.Marshal(products)
 	generatedRecipe.UserIngredients = jsonProducts
 
+	generateRecipeID, recipeVersion, err := repo.insertNewRecipe(ctx, generatedRecipe, userID)
+
 	if err != nil {
 		logger.Error(ctx,
-			fmt.Sprintf(`RESP from DeepSeek API: %s, 
-				failed to parse recipe: %+v for userId: %d, query: %s, products: %s`,
-				respData, err, userID, query, products),
+			fmt.Sprintf("fail to insert generated recipe: %+v for userId: %d, genRecipe: %+v",
+				err, userID, generatedRecipe),
 		)
 		return nil, internalErrors.ErrWithGenerating
 	}
 
+	generatedRecipe.ID = generateRecipeID
+	generatedRecipe.Version = recipeVersion
+
+	recipeModel := dao.ConvertGeneratedRecipeToRecipeModels([]dao.GeneratedRecipe{generatedRecipe})
+
+	return recipeModel, nil
+}
+
+// generationCacheKey строит ключ по нормализованным продуктам и пожеланиям. Модель тоже входит в ключ,
+// чтобы после ее смены не отдавать рецепты от старой.
+func (repo *GeneratedRecipeRepo) generationCacheKey(products []string, query string) string {
+	hash := sha256.Sum256([]byte(repo.config.LLMGeneration.Model + "|" +
+		utils.NormalizeGenerationRequest(products, query)))
+
+	return generationCacheEntity + ":" + hex.EncodeToString(hash[:])
+}
+
+func (repo *GeneratedRecipeRepo) getCachedRecipe(ctx context.Context, cacheKey string) (dao.GeneratedRecipe, bool) {
+	if repo.config.GenerationCacheTTL <= 0 {
+		return dao.GeneratedRecipe{}, false
+	}
+
+	cached, err := repo.cache.GetBytes(cacheKey)
+	if err != nil {
+		if !errors.Is(err, redigo.ErrNil) {
+			logger.Error(ctx, fmt.Sprintf("error getting cached recipe: %v for key: %s", err, cacheKey))
+		}
+		return dao.GeneratedRecipe{}, false
+	}
+
+	var generatedRecipe dao.GeneratedRecipe
+
+	if err = json.Unmarshal(cached, &generatedRecipe); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error unmarshaling cached recipe: %v for key: %s", err, cacheKey))
+		return dao.GeneratedRecipe{}, false
+	}
+
+	logger.Info(ctx, fmt.Sprintf("generated recipe found in cache: %s", cacheKey))
+
+	return generatedRecipe, true
+}
+
+func (repo *GeneratedRecipeRepo) cacheRecipe(ctx context.Context, cacheKey string,
+	generatedRecipe dao.GeneratedRecipe) {
+	if repo.config.GenerationCacheTTL <= 0 {
+		return
+	}
+
+	recipeBytes, err := json.Marshal(generatedRecipe)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error marshaling recipe for cache: %v for key: %s", err, cacheKey))
+		return
+	}
+
+	if err = repo.cache.SetBytes(cacheKey, recipeBytes, repo.config.GenerationCacheTTL); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error caching generated recipe: %v for key: %s", err, cacheKey))
+	}
+}
+
+// completeRecipe запрашивает рецепт у модели и проверяет его по схеме. Если проверка не прошла,
+// модель получает список ошибок и присылает рецепт заново, но не больше LLMRepairAttempts раз.
+// Если передан onChunk, ответ модели запрашивается потоком и отдается в onChunk.
+func (repo *GeneratedRecipeRepo) completeRecipe(ctx context.Context, req llm.Request,
+	onChunk func(chunk models.GenerationChunkModel) error) (dao.GeneratedRecipe, error) {
+	input := req.Input
+
+	for attempt := 0; ; attempt++ {
+		respData, err := repo.requestRecipe(ctx, req, attempt+1, onChunk)
+		if err != nil {
+			return dao.GeneratedRecipe{}, err
+		}
+
+		respData = utils.ExtractLLMJSON(respData)
+
+		generatedRecipe, err := dao.ParseGeneratedRecipe(json.RawMessage(respData))
+		if err == nil {
+			return generatedRecipe, nil
+		}
+
+		if attempt >= repo.config.LLMRepairAttempts {
+			return dao.GeneratedRecipe{}, fmt.Errorf("recipe is invalid after %d attempts: %w, response: %s",
+				attempt+1, err, respData)
+		}
+
+		problems := []string{err.Error()}
+
+		var validationErr *dao.RecipeValidationError
+		if errors.As(err, &validationErr) {
+			problems = validationErr.Problems
+		}
+
+		logger.Info(ctx, fmt.Sprintf("generated recipe is invalid, use case: %s, attempt: %d, problems: %s",
+			req.UseCase, attempt+1, strings.Join(problems, "; ")))
+
+		req.Input = fmt.Sprintf(promptRepair, input, respData, strings.Join(problems, "\n- "))
+	}
+}
+
+func (repo *GeneratedRecipeRepo) requestRecipe(ctx context.Context, req llm.Request, attempt int,
+	onChunk func(chunk models.GenerationChunkModel) error) (string, error) {
+	key, err := repo.keys.Acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	req.APIKey = key.Value
+	req.Attempt = attempt
+
+	var resp llm.Response
+
+	if onChunk == nil {
+		resp, err = repo.llm.Complete(ctx, req)
+	} else {
+		resp, err = repo.llm.Stream(ctx, req, func(delta string) error {
+			return onChunk(models.GenerationChunkModel{Attempt: attempt, Delta: delta})
+		})
+	}
+
+	repo.keys.Release(ctx, key, err)
+
+	return resp.Content, err
+}
+
+// insertNewRecipe сохраняет новый рецепт и его первую версию в одной транзакции.
+func (repo *GeneratedRecipeRepo) insertNewRecipe(ctx context.Context, generatedRecipe dao.GeneratedRecipe,
+	userID uint) (int, int, error) {
 	tx, err := repo.storage.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf("failed to begin transaction on generating recipe: %d, internalerrors: %e", userID, err),
-		)
-		return nil, internalErrors.ErrWithGenerating
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
 	}
 
 	defer func() {
 		if err != nil {
-			if err = tx.Rollback(); err != nil {
-				logger.Error(ctx, fmt.Sprintf("Failed to rollback transaction: %e for userId: %d",
-					err, userID))
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				logger.Error(ctx, fmt.Sprintf("Failed to rollback transaction: %v for userId: %d",
+					rollbackErr, userID))
 			}
 		}
 	}()
 
-	generateRecipeID, err := repo.insertGeneratedRecipe(ctx, repo.storage, generatedRecipe, userID)
-
+	generateRecipeID, err := repo.insertGeneratedRecipe(ctx, tx, generatedRecipe, userID)
 	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf("fail to insert generated recipe: %+v for userId: %d, genRecipe: %+v",
-				err, userID, generatedRecipe),
-		)
-		return nil, internalErrors.ErrWithGenerating
+		return 0, 0, err
 	}
 
-	recipeVersion, err := repo.insertVersionGeneratedRecipe(ctx,
-		repo.storage, generatedRecipe, userID, generateRecipeID)
-
+	recipeVersion, err := repo.insertVersionGeneratedRecipe(ctx, tx, generatedRecipe, userID, generateRecipeID, 0)
 	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf(
-				"fail to insert version generated recipe: %+v for userId: %d, genRecipe: %+v",
-				err, userID, generatedRecipe),
-		)
-		return nil, internalErrors.ErrWithGenerating
+		return 0, 0, fmt.Errorf("failed to insert first version: %w", err)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return nil, internalErrors.ErrWithGenerating
+		return 0, 0, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
-	generatedRecipe.ID = generateRecipeID
-	generatedRecipe.Version = recipeVersion
-
-	recipeModel := dao.ConvertGeneratedRecipeToRecipeModels([]dao.GeneratedRecipe{generatedRecipe})
-
-	return recipeModel, nil
+	return generateRecipeID, recipeVersion, nil
 }
 
+// insertVersionGeneratedRecipe добавляет версию с номером MAX(version)+1 и родителем parentVersion
+// (0 - у версии нет родителя). Если параллельная модернизация заняла тот же номер, уникальный ключ
+// (id, version) не даст записать дубль, и вставка повторяется со следующим номером.
 func (repo *GeneratedRecipeRepo) insertVersionGeneratedRecipe(ctx context.Context, queryer sqlx.QueryerContext,
-	generatedRecipe dao.GeneratedRecipe, userID uint, generateRecipeID int) (int, error) {
+	generatedRecipe dao.GeneratedRecipe, userID uint, generateRecipeID int, parentVersion int) (int, error) {
 	var generateVersion int
 	q := `INSERT INTO public.generated_recipes_versions (
 	        user_id,
@@ -456,34 +578,43 @@ func (repo *GeneratedRecipeRepo) insertVersionGeneratedRecipe(ctx context.Contex
 			total_steps,
             id,
             version,
-            query
+            query,
+            parent_version
 	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, (
 	SELECT COALESCE(MAX(version), 0) + 1 AS next_version
  		FROM public.generated_recipes_versions
  		WHERE id = $12
- 	), $13) RETURNING version;`
+ 	), $13, NULLIF($14, 0)) RETURNING version;`
+
+	for attempt := 1; ; attempt++ {
+		err := queryer.QueryRowxContext(ctx, q,
+			userID,
+			generatedRecipe.Name,
+			generatedRecipe.Desc,
+			generatedRecipe.DishTypes,
+			generatedRecipe.ServingsNum,
+			generatedRecipe.Diets,
+			generatedRecipe.Ingredients,
+			generatedRecipe.ReadyInMinutes,
+			generatedRecipe.Steps,
+			generatedRecipe.TotalSteps,
+			generateRecipeID,
+			generateRecipeID,
+			generatedRecipe.Query,
+			parentVersion,
+		).Scan(&generateVersion)
+
+		if err == nil {
+			return generateVersion, nil
+		}
 
-	err := queryer.QueryRowxContext(ctx, q,
-		userID,
-		generatedRecipe.Name,
-		generatedRecipe.Desc,
-		generatedRecipe.DishTypes,
-		generatedRecipe.ServingsNum,
-		generatedRecipe.Diets,
-		generatedRecipe.Ingredients,
-		generatedRecipe.ReadyInMinutes,
-		generatedRecipe.Steps,
-		generatedRecipe.TotalSteps,
-		generateRecipeID,
-		generateRecipeID,
-		generatedRecipe.Query,
-	).Scan(&generateVersion)
+		if !repo.storage.IsDuplicateKeyError(err) || attempt >= versionInsertAttempts {
+			return 0, err
+		}
 
-	if err != nil {
-		return 0, err
+		logger.Info(ctx, fmt.Sprintf("version number is taken for recipe: %d, retry: %d",
+			generateRecipeID, attempt))
 	}
-
-	return generateVersion, err
 }
 
 func (repo *GeneratedRecipeRepo) insertGeneratedRecipe(ctx context.Context, queryer sqlx.QueryerContext,
@@ -516,17 +647,7 @@ func (repo *GeneratedRecipeRepo) insertGeneratedRecipe(ctx context.Context, quer
 }
 
 func (repo *GeneratedRecipeRepo) UpdateRecipe(ctx context.Context, query string, recipeID int, versionID int,
-	userID uint) ([]models.RecipeModel, error) {
-	APIURL := repo.config.DeepSeekAPIURL
-	APIKey, APIKeyID, err := repo.GetKey()
-
-	if err != nil {
-		logger.Error(ctx, "no free keys now")
-		return nil, err
-	}
-
-	defer repo.RefreshKeyByID(APIKeyID)
-
+	userID uint, jobID int, jobAttempt int) ([]models.RecipeModel, error) {
 	recipeDao, err := repo.getRecipeByIDAndVersion(ctx, recipeID, userID, versionID)
 
 	if err != nil {
@@ -551,30 +672,32 @@ func (repo *GeneratedRecipeRepo) UpdateRecipe(ctx context.Context, query string,
 		and add totalSteps - int as count of steps` + string(jsonRecipe) + "reformat: " + query
 	*/
 
-	respData, err := utils.GetResponseData(ctx, q, APIURL, APIKey, promptChoiceModernization)
+	generatedRecipe, err := repo.completeRecipe(ctx, llm.Request{
+		UseCase:    llm.UseCaseModernization,
+		Prompt:     promptChoiceModernization,
+		Input:      q,
+		Params:     repo.config.LLMModernization,
+		UserID:     userID,
+		JobID:      jobID,
+		JobAttempt: jobAttempt,
+	}, nil)
+
+	if errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
+		return nil, err
+	}
 
 	if err != nil {
 		logger.Error(ctx,
-			fmt.Sprintf(`failed get resp data: %+v for userId: %d, query: %s, recipeID: %d, respData: %+v,
-				prompt: %s`,
-				err, userID, query, recipeID, respData, promptChoiceModernization),
+			fmt.Sprintf(`failed to modernize recipe: %+v for userId: %d, query: %s, recipeID: %d`,
+				err, userID, query, recipeID),
 		)
 		return nil, internalErrors.ErrWithModernization
 	}
 
-	generatedRecipe, err := dao.ParseGeneratedRecipe(json.RawMessage(respData))
-
 	generatedRecipe.Query = query
 
-	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf(`failed to parse recipe: %+v for respData: %+v,`,
-				err, respData),
-		)
-		return nil, internalErrors.ErrWithModernization
-	}
-
-	generateVersion, err := repo.insertVersionGeneratedRecipe(ctx, repo.storage, generatedRecipe, userID, recipeID)
+	generateVersion, err := repo.insertVersionGeneratedRecipe(ctx, repo.storage, generatedRecipe, userID, recipeID,
+		versionID)
 
 	if err != nil {
 		logger.Error(ctx,
@@ -584,13 +707,54 @@ func (repo *GeneratedRecipeRepo) UpdateRecipe(ctx context.Context, query string,
 		return nil, internalErrors.ErrWithModernization
 	}
 
+	generatedRecipe.ID = recipeID
 	generatedRecipe.Version = generateVersion
 
 	recipeModel := dao.ConvertGeneratedRecipeToRecipeModels([]dao.GeneratedRecipe{generatedRecipe})
+	recipeModel[0].ParentVersion = versionID
 
 	return recipeModel, nil
 }
 
+// ForkRecipe копирует версию рецепта в новый сгенерированный рецепт, у которого она становится первой версией.
+func (repo *GeneratedRecipeRepo) ForkRecipe(ctx context.Context, recipeID int, versionID int,
+	userID uint) ([]models.RecipeModel, error) {
+	q := `SELECT v.name, v.description, v.servings, v.total_steps, v.ready_in_minutes, v.ingredients, v.steps,
+			v.dish_types, v.diets, v.query, g.user_ingredients
+		FROM public.generated_recipes_versions AS v
+		JOIN public.generated_recipes AS g ON g.id = v.id
+		WHERE v.user_id = $1 AND v.id = $2 AND v.version = $3`
+
+	var generatedRecipe dao.GeneratedRecipe
+
+	err := repo.storage.QueryRowxContext(ctx, q, userID, recipeID, versionID).StructScan(&generatedRecipe)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Info(ctx, fmt.Sprintf("recipe version to fork not found with rid: %d, version: %d, uid: %d",
+				recipeID, versionID, userID))
+			return nil, internalErrors.ErrVersionNotFound
+		}
+		logger.Error(ctx, fmt.Sprintf("error getting recipe version to fork: %v with rid: %d, version: %d, uid: %d",
+			err, recipeID, versionID, userID))
+		return nil, internalErrors.ErrWithForkRecipe
+	}
+
+	forkedRecipeID, forkedVersion, err := repo.insertNewRecipe(ctx, generatedRecipe, userID)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("fail to insert forked recipe: %v with rid: %d, version: %d, uid: %d",
+			err, recipeID, versionID, userID))
+		return nil, internalErrors.ErrWithForkRecipe
+	}
+
+	logger.Info(ctx, fmt.Sprintf("forked recipe: %d version: %d into recipe: %d, uid: %d",
+		recipeID, versionID, forkedRecipeID, userID))
+
+	generatedRecipe.ID = forkedRecipeID
+	generatedRecipe.Version = forkedVersion
+
+	return dao.ConvertGeneratedRecipeToRecipeModels([]dao.GeneratedRecipe{generatedRecipe}), nil
+}
+
 func (repo *GeneratedRecipeRepo) GetVersionByID(ctx context.Context, userID uint,
 	recipeID int, versionID int) ([]dao.RecipeTable, error) {
 	q := `SELECT r.name, r.query, r.description, r.steps, r.ingredients, 
@@ -607,6 +771,29 @@ func (repo *GeneratedRecipeRepo) GetVersionByID(ctx context.Context, userID uint
 	return recipeRows, nil
 }
 
+func (repo *GeneratedRecipeRepo) GetRecipeVersion(ctx context.Context, recipeID int, versionID int,
+	userID uint) ([]models.RecipeModel, error) {
+	recipeRows, err := repo.GetVersionByID(ctx, userID, recipeID, versionID)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting recipe version: %v with rid: %d, version: %d, uid: %d",
+			err, recipeID, versionID, userID))
+		return nil, internalErrors.ErrFailToGetRecipeByIDAndVersion
+	}
+
+	if len(recipeRows) == 0 {
+		logger.Info(ctx, fmt.Sprintf("recipe version not found with rid: %d, version: %d, uid: %d",
+			recipeID, versionID, userID))
+		return nil, internalErrors.ErrVersionNotFound
+	}
+
+	recipeModels := dao.ConvertDaoToRecipe(recipeRows)
+	recipeModels[0].ID = recipeID
+	recipeModels[0].Version = versionID
+
+	return recipeModels, nil
+}
+
 func (repo *GeneratedRecipeRepo) SetNewMainVersion(ctx context.Context, recipeID int,
 	versionID int, userID uint) error {
 	recipeRows, err := repo.GetVersionByID(ctx, userID, recipeID, versionID)
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+const invalidFakeRecipe = `{"name": "Омлет", "steps": []}`
+
+// scriptedProvider отвечает по очереди заданными фейковыми провайдерами, последний повторяется.
+type scriptedProvider struct {
+	providers []*llm.FakeProvider
+	requests  []llm.Request
+}
+
+func newScriptedProvider(responses ...string) *scriptedProvider {
+	providers := make([]*llm.FakeProvider, 0, len(responses))
+	for _, response := range responses {
+		providers = append(providers, llm.NewFakeProvider(map[string]string{
+			llm.UseCaseGeneration:    response,
+			llm.UseCaseModernization: response,
+		}))
+	}
+
+	return &scriptedProvider{providers: providers}
+}
+
+func (p *scriptedProvider) next(req llm.Request) *llm.FakeProvider {
+	p.requests = append(p.requests, req)
+	return p.providers[min(len(p.requests), len(p.providers))-1]
+}
+
+func (p *scriptedProvider) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	return p.next(req).Complete(ctx, req)
+}
+
+func (p *scriptedProvider) Stream(ctx context.Context, req llm.Request, onDelta llm.OnDelta) (llm.Response, error) {
+	return p.next(req).Stream(ctx, req, onDelta)
+}
+
+func newTestGeneratedRecipeRepo(provider LLMProvider, repairAttempts int) *GeneratedRecipeRepo {
+	cfg := &config.Config{LLMRepairAttempts: repairAttempts}
+
+	return &GeneratedRecipeRepo{config: cfg, llm: provider, keys: NewKeyPool(cfg)}
+}
+
+func TestCompleteRecipeRepairLoop(t *testing.T) {
+	validRecipe := llm.DefaultFakeResponses()[llm.UseCaseGeneration]
+
+	tests := []struct {
+		name           string
+		responses      []string
+		repairAttempts int
+		wantCalls      int
+		wantErr        bool
+	}{
+		{
+			name:           "valid recipe on first attempt",
+			responses:      []string{validRecipe},
+			repairAttempts: 2,
+			wantCalls:      1,
+		},
+		{
+			name:           "invalid recipe is repaired",
+			responses:      []string{invalidFakeRecipe, validRecipe},
+			repairAttempts: 2,
+			wantCalls:      2,
+		},
+		{
+			name:           "recipe wrapped in markdown is accepted",
+			responses:      []string{"```json\n" + validRecipe + "\n```"},
+			repairAttempts: 2,
+			wantCalls:      1,
+		},
+		{
+			name:           "gives up after repair attempts",
+			responses:      []string{invalidFakeRecipe},
+			repairAttempts: 2,
+			wantCalls:      3,
+			wantErr:        true,
+		},
+		{
+			name:           "no repair attempts",
+			responses:      []string{invalidFakeRecipe, validRecipe},
+			repairAttempts: 0,
+			wantCalls:      1,
+			wantErr:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider := newScriptedProvider(tt.responses...)
+			repo := newTestGeneratedRecipeRepo(provider, tt.repairAttempts)
+
+			recipe, err := repo.completeRecipe(context.Background(), llm.Request{
+				UseCase: llm.UseCaseGeneration,
+				Input:   "омлет",
+			}, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("completeRecipe() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && recipe.Name != "Омлет" {
+				t.Errorf("completeRecipe() name = %q, want %q", recipe.Name, "Омлет")
+			}
+
+			if len(provider.requests) != tt.wantCalls {
+				t.Fatalf("completeRecipe() called model %d times, want %d", len(provider.requests), tt.wantCalls)
+			}
+
+			for i, req := range provider.requests {
+				if req.Attempt != i+1 {
+					t.Errorf("request %d attempt = %d, want %d", i, req.Attempt, i+1)
+				}
+
+				// Повторный запрос содержит исходный запрос и прошлый ответ модели.
+				if i > 0 && (!strings.Contains(req.Input, "омлет") || !strings.Contains(req.Input, invalidFakeRecipe)) {
+					t.Errorf("repair request %d input = %q, want original input and previous response", i, req.Input)
+				}
+			}
+		})
+	}
+}
+
+func TestCompleteRecipeRepairLoopStream(t *testing.T) {
+	validRecipe := llm.DefaultFakeResponses()[llm.UseCaseGeneration]
+	provider := newScriptedProvider(invalidFakeRecipe, validRecipe)
+	repo := newTestGeneratedRecipeRepo(provider, 2)
+
+	streamed := make(map[int]string)
+
+	_, err := repo.completeRecipe(context.Background(), llm.Request{UseCase: llm.UseCaseGeneration},
+		func(chunk models.GenerationChunkModel) error {
+			streamed[chunk.Attempt] += chunk.Delta
+			return nil
+		})
+	if err != nil {
+		t.Fatalf("completeRecipe() error = %v", err)
+	}
+
+	want := map[int]string{1: invalidFakeRecipe, 2: validRecipe}
+
+	for attempt, text := range want {
+		if streamed[attempt] != text {
+			t.Errorf("attempt %d streamed %q, want %q", attempt, streamed[attempt], text)
+		}
+	}
+
+	if len(streamed) != len(want) {
+		t.Errorf("streamed %d attempts, want %d", len(streamed), len(want))
+	}
+}
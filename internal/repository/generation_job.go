@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/Olegsandrik/Exponenta/internal/adapters/postgres"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/repository/dao"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const generationJobColumns = `id, user_id, kind, status, query, products, fresh, recipe_id, version_id, result,
+	error, attempts, created_at, started_at, finished_at`
+
+type GenerationJobRepo struct {
+	storage *postgres.Adapter
+}
+
+func NewGenerationJobRepo(storage *postgres.Adapter) *GenerationJobRepo {
+	return &GenerationJobRepo{storage: storage}
+}
+
+// generationQuotaLock - пространство advisory-блокировок для проверки квоты генераций: первый ключ
+// блокировки, второй - пользователь.
+const generationQuotaLock = 1
+
+// CreateJob ставит задачу в очередь. Если передан checkQuota, он получает использование квоты вместе
+// с активными задачами, посчитанное в той же транзакции под advisory-блокировкой пользователя: иначе
+// параллельные запросы увидят одно и то же использование и все пройдут проверку.
+// Задача со статусом streaming создается уже выполняемой с первой попыткой.
+func (repo *GenerationJobRepo) CreateJob(ctx context.Context, job models.GenerationJobModel,
+	checkQuota func(usage models.GenerationUsageModel) error) (models.GenerationJobModel, error) {
+	q := `INSERT INTO public.generation_jobs (user_id, kind, query, products, fresh, recipe_id, version_id,
+			status, attempts, started_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), NULLIF($7, 0), $8, $9, CASE WHEN $10 THEN NOW() END)
+		RETURNING ` + generationJobColumns
+
+	products, err := json.Marshal(job.Products)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error marshaling generation job products: %v for userId: %d", err, job.UserID))
+		return models.GenerationJobModel{}, internalErrors.ErrFailedToCreateGenerationJob
+	}
+
+	status, attempts, started := models.GenerationJobStatusPending, 0, false
+	if job.Status == models.GenerationJobStatusStreaming {
+		status, attempts, started = models.GenerationJobStatusStreaming, 1, true
+	}
+
+	tx, err := repo.storage.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to begin transaction on creating generation job: %v for userId: %d",
+			err, job.UserID))
+		return models.GenerationJobModel{}, internalErrors.ErrFailedToCreateGenerationJob
+	}
+
+	defer func() {
+		if err != nil {
+			if rollbackErr := tx.Rollback(); rollbackErr != nil {
+				logger.Error(ctx, fmt.Sprintf("failed to rollback transaction on creating generation job: %v "+
+					"for userId: %d", rollbackErr, job.UserID))
+			}
+		}
+	}()
+
+	if checkQuota != nil {
+		if err = repo.checkQuota(ctx, tx, job.UserID, checkQuota); err != nil {
+			return models.GenerationJobModel{}, err
+		}
+	}
+
+	var jobRow dao.GenerationJobTable
+
+	err = tx.QueryRowxContext(ctx, q, job.UserID, job.Kind, job.Query, products, job.Fresh,
+		job.RecipeID, job.VersionID, status, attempts, started).StructScan(&jobRow)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error creating generation job: %v for userId: %d, kind: %s",
+			err, job.UserID, job.Kind))
+		return models.GenerationJobModel{}, internalErrors.ErrFailedToCreateGenerationJob
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to commit generation job: %v for userId: %d", err, job.UserID))
+		return models.GenerationJobModel{}, internalErrors.ErrFailedToCreateGenerationJob
+	}
+
+	logger.Info(ctx, fmt.Sprintf("created generation job: %d for userId: %d, kind: %s, status: %s",
+		jobRow.ID, job.UserID, job.Kind, status))
+
+	return repo.convertJob(ctx, jobRow, internalErrors.ErrFailedToCreateGenerationJob)
+}
+
+// checkQuota блокирует квоту пользователя до конца транзакции tx и проверяет ее использование.
+func (repo *GenerationJobRepo) checkQuota(ctx context.Context, tx *sqlx.Tx, userID uint,
+	checkQuota func(usage models.GenerationUsageModel) error) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1, $2)`,
+		int32(generationQuotaLock), int32(userID)); err != nil {
+		logger.Error(ctx, fmt.Sprintf("error locking generation quota: %v for userId: %d", err, userID))
+		return internalErrors.ErrFailedToCreateGenerationJob
+	}
+
+	usage, err := getGenerationUsage(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	activeJobs, err := countActiveJobs(ctx, tx, userID)
+	if err != nil {
+		return err
+	}
+
+	usage.DailyUsed += activeJobs
+	usage.MonthlyUsed += activeJobs
+
+	return checkQuota(usage)
+}
+
+func (repo *GenerationJobRepo) GetJob(ctx context.Context, jobID int,
+	userID uint) (models.GenerationJobModel, error) {
+	q := `SELECT ` + generationJobColumns + ` FROM public.generation_jobs WHERE id = $1 AND user_id = $2`
+
+	var jobRow dao.GenerationJobTable
+
+	err := repo.storage.QueryRowxContext(ctx, q, jobID, userID).StructScan(&jobRow)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Info(ctx, fmt.Sprintf("generation job not found: %d for userId: %d", jobID, userID))
+			return models.GenerationJobModel{}, internalErrors.ErrGenerationJobNotFound
+		}
+		logger.Error(ctx, fmt.Sprintf("error getting generation job: %v with id: %d, userId: %d",
+			err, jobID, userID))
+		return models.GenerationJobModel{}, internalErrors.ErrFailedToGetGenerationJob
+	}
+
+	return repo.convertJob(ctx, jobRow, internalErrors.ErrFailedToGetGenerationJob)
+}
+
+// CountActiveJobs считает ожидающие, выполняемые и стримящиеся задачи пользователя, которые еще не списали
+// квоту в журнале использования модели.
+func (repo *GenerationJobRepo) CountActiveJobs(ctx context.Context, userID uint) (int, error) {
+	return countActiveJobs(ctx, repo.storage, userID)
+}
+
+func countActiveJobs(ctx context.Context, queryer sqlx.QueryerContext, userID uint) (int, error) {
+	q := `SELECT COUNT(*) FROM public.generation_jobs j
+		WHERE j.user_id = $1 AND j.status IN ($2, $3, $4)
+		  AND NOT EXISTS (SELECT 1 FROM public.llm_usage u WHERE u.job_id = j.id AND u.produced_content)`
+
+	var count int
+
+	err := queryer.QueryRowxContext(ctx, q, userID, models.GenerationJobStatusPending,
+		models.GenerationJobStatusRunning, models.GenerationJobStatusStreaming).Scan(&count)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error counting active generation jobs: %v for userId: %d", err, userID))
+		return 0, internalErrors.ErrFailedToGetGenerationJob
+	}
+
+	return count, nil
+}
+
+// ClaimJob берет в работу самую старую ожидающую задачу. Задачи, которые числятся выполняемыми дольше lease,
+// считаются брошенными (приложение упало посреди генерации) и тоже забираются заново.
+func (repo *GenerationJobRepo) ClaimJob(ctx context.Context, lease time.Duration,
+	maxAttempts int) (models.GenerationJobModel, error) {
+	q := `UPDATE public.generation_jobs SET status = $1, attempts = attempts + 1, started_at = NOW()
+		WHERE id = (
+			SELECT id FROM public.generation_jobs
+			WHERE (status = $2 OR (status = $1 AND started_at < NOW() - make_interval(secs => $3)))
+			  AND attempts < $4
+			ORDER BY id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		) RETURNING ` + generationJobColumns
+
+	var jobRow dao.GenerationJobTable
+
+	err := repo.storage.QueryRowxContext(ctx, q, models.GenerationJobStatusRunning,
+		models.GenerationJobStatusPending, lease.Seconds(), maxAttempts).StructScan(&jobRow)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return models.GenerationJobModel{}, internalErrors.ErrNoGenerationJobs
+		}
+		logger.Error(ctx, fmt.Sprintf("error claiming generation job: %v", err))
+		return models.GenerationJobModel{}, internalErrors.ErrFailedToGetGenerationJob
+	}
+
+	logger.Info(ctx, fmt.Sprintf("claimed generation job: %d, attempt: %d", jobRow.ID, jobRow.Attempts))
+
+	return repo.convertJob(ctx, jobRow, internalErrors.ErrFailedToGetGenerationJob)
+}
+
+func (repo *GenerationJobRepo) FinishJob(ctx context.Context, jobID int, recipeID int, versionID int,
+	result json.RawMessage) error {
+	q := `UPDATE public.generation_jobs SET status = $1, recipe_id = $2, version_id = $3, result = $4,
+		error = NULL, finished_at = NOW() WHERE id = $5`
+
+	return repo.updateJob(ctx, jobID, q, models.GenerationJobStatusDone, recipeID, versionID, []byte(result), jobID)
+}
+
+func (repo *GenerationJobRepo) FailJob(ctx context.Context, jobID int, errMsg string) error {
+	q := `UPDATE public.generation_jobs SET status = $1, error = $2, finished_at = NOW() WHERE id = $3`
+
+	return repo.updateJob(ctx, jobID, q, models.GenerationJobStatusFailed, errMsg, jobID)
+}
+
+// ReleaseJob возвращает задачу в очередь. Если refundAttempt, попытка не засчитывается:
+// задачу прервала остановка приложения или занятость ключей, а не ошибка генерации.
+func (repo *GenerationJobRepo) ReleaseJob(ctx context.Context, jobID int, errMsg string, refundAttempt bool) error {
+	q := `UPDATE public.generation_jobs SET status = $1, started_at = NULL, error = NULLIF($2, ''),
+		attempts = CASE WHEN $3 THEN GREATEST(attempts - 1, 0) ELSE attempts END WHERE id = $4`
+
+	return repo.updateJob(ctx, jobID, q, models.GenerationJobStatusPending, errMsg, refundAttempt, jobID)
+}
+
+// FailStaleJobs помечает упавшими брошенные задачи, у которых не осталось попыток, и стримы дольше lease:
+// их запрос прервался вместе с приложением, и повторять их некому.
+func (repo *GenerationJobRepo) FailStaleJobs(ctx context.Context, lease time.Duration, maxAttempts int) error {
+	q := `UPDATE public.generation_jobs SET status = $1, finished_at = NOW(),
+		error = COALESCE(error, CASE WHEN status = $5 THEN 'generation stream was interrupted'
+			ELSE 'generation was interrupted too many times' END)
+		WHERE started_at < NOW() - make_interval(secs => $3)
+		  AND ((status = $2 AND attempts >= $4) OR status = $5)`
+
+	result, err := repo.storage.Exec(ctx, q, models.GenerationJobStatusFailed, models.GenerationJobStatusRunning,
+		lease.Seconds(), maxAttempts, models.GenerationJobStatusStreaming)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error failing stale generation jobs: %v", err))
+		return internalErrors.ErrFailedToUpdateGenerationJob
+	}
+
+	if rows, err := result.RowsAffected(); err == nil && rows > 0 {
+		logger.Info(ctx, fmt.Sprintf("failed %d stale generation jobs", rows))
+	}
+
+	return nil
+}
+
+func (repo *GenerationJobRepo) updateJob(ctx context.Context, jobID int, q string, args ...interface{}) error {
+	result, err := repo.storage.Exec(ctx, q, args...)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error updating generation job: %v with id: %d", err, jobID))
+		return internalErrors.ErrFailedToUpdateGenerationJob
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil || rows == 0 {
+		logger.Error(ctx, fmt.Sprintf("zero rows updated for generation job: %d", jobID))
+		return internalErrors.ErrGenerationJobNotFound
+	}
+
+	return nil
+}
+
+func (repo *GenerationJobRepo) convertJob(ctx context.Context, jobRow dao.GenerationJobTable,
+	convertErr error) (models.GenerationJobModel, error) {
+	job, err := dao.ConvertGenerationJobToModel(jobRow)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error converting generation job: %v with id: %d", err, jobRow.ID))
+		return models.GenerationJobModel{}, convertErr
+	}
+
+	return job, nil
+}
@@ -46,3 +46,34 @@ func (ir *ImageRepository) GetImageByID(ctx context.Context,
 		ContentType: contentType,
 	}, nil
 }
+
+func (ir *ImageRepository) UploadImage(ctx context.Context,
+	filename string, entity string, image models.ImageModel) error {
+	_, err := ir.adapter.Client.PutObject(
+		ctx,
+		ir.adapter.BucketName,
+		fmt.Sprintf("%s/%s", entity, filename),
+		image.Image,
+		image.ImageSize,
+		minio.NewPutObjectOptions(image.ContentType))
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Error uploading image: %v for %s/%s", err, entity, filename))
+		return internalErrors.ErrFailedToUploadImage
+	}
+
+	return nil
+}
+
+func (ir *ImageRepository) DeleteImage(ctx context.Context, filename string, entity string) error {
+	err := ir.adapter.Client.RemoveObject(
+		ctx,
+		ir.adapter.BucketName,
+		fmt.Sprintf("%s/%s", entity, filename),
+		minio.NewEmptyRemoveObjectOptions())
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Error deleting image: %v for %s/%s", err, entity, filename))
+		return internalErrors.ErrFailedToDeleteImage
+	}
+
+	return nil
+}
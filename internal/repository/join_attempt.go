@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/redis"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const joinAttemptEntity = "join_attempts"
+
+// JoinAttemptRepo считает попытки пользователя войти в сессию по коду, чтобы коды нельзя было перебрать.
+type JoinAttemptRepo struct {
+	cache  *redis.Adapter
+	limit  int
+	window time.Duration
+}
+
+func NewJoinAttemptRepo(cache *redis.Adapter, cfg *config.Config) *JoinAttemptRepo {
+	return &JoinAttemptRepo{
+		cache:  cache,
+		limit:  cfg.JoinAttemptsLimit,
+		window: cfg.JoinAttemptsWindow,
+	}
+}
+
+// RegisterJoinAttempt учитывает попытку и возвращает ErrTooManyJoinAttempts, если за JoinAttemptsWindow
+// их больше JoinAttemptsLimit. Неположительный лимит или окно отключают проверку.
+func (repo *JoinAttemptRepo) RegisterJoinAttempt(ctx context.Context, uID uint) error {
+	if repo.limit <= 0 || repo.window <= 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf("%s:%d", joinAttemptEntity, uID)
+
+	attempts, err := repo.cache.Incr(key, repo.window)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error counting join attempts: %v for userId: %d", err, uID))
+		return internalErrors.ErrFailedToCheckJoinAttempts
+	}
+
+	if attempts > repo.limit {
+		logger.Info(ctx, fmt.Sprintf("too many join attempts: %d for userId: %d", attempts, uID))
+		return internalErrors.ErrTooManyJoinAttempts
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const (
+	keyRateWindow  = time.Minute
+	keyVisibleTail = 4
+)
+
+type LLMProvider interface {
+	Complete(ctx context.Context, req llm.Request) (llm.Response, error)
+	Stream(ctx context.Context, req llm.Request, onDelta llm.OnDelta) (llm.Response, error)
+}
+
+// KeyLease - выданный из пула ключ, его нужно вернуть через KeyPool.Release.
+type KeyLease struct {
+	Value string
+	index int
+}
+
+type apiKey struct {
+	value         string
+	inFlight      int
+	requests      []time.Time
+	cooldownUntil time.Time
+	totalRequests int64
+	totalErrors   int64
+	rateLimited   int64
+	lastError     string
+	lastErrorAt   time.Time
+}
+
+// KeyPool раздает API-ключи с учетом одновременных запросов и запросов в минуту на ключ. Если свободных
+// ключей нет, вызывающий ждет в очереди до LLMKeyWaitTimeout. После 429 и 5xx ключ уходит на cooldown.
+// Без ключей пул выдает пустой ключ без ограничений - для локальных моделей без авторизации.
+type KeyPool struct {
+	mu                sync.Mutex
+	keys              []*apiKey
+	next              int
+	maxConcurrency    int
+	requestsPerMinute int
+	cooldown          time.Duration
+	waitTimeout       time.Duration
+	waiting           int
+	released          chan struct{}
+}
+
+func NewKeyPool(cfg *config.Config) *KeyPool {
+	keys := make([]*apiKey, 0, len(cfg.DeepSeekAPIKeys))
+
+	for _, value := range cfg.DeepSeekAPIKeys {
+		keys = append(keys, &apiKey{value: value})
+	}
+
+	maxConcurrency := cfg.LLMKeyMaxConcurrency
+	if maxConcurrency < 1 {
+		maxConcurrency = 1
+	}
+
+	return &KeyPool{
+		keys:              keys,
+		maxConcurrency:    maxConcurrency,
+		requestsPerMinute: cfg.LLMKeyRequestsPerMinute,
+		cooldown:          cfg.LLMKeyCooldown,
+		waitTimeout:       cfg.LLMKeyWaitTimeout,
+		released:          make(chan struct{}),
+	}
+}
+
+func (p *KeyPool) Acquire(ctx context.Context) (KeyLease, error) {
+	waitCtx := ctx
+	if p.waitTimeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, p.waitTimeout)
+		defer cancel()
+	}
+
+	return p.acquire(ctx, waitCtx, p.waitTimeout)
+}
+
+// AcquireWithin берет ключ так же, как Acquire, но ждет в очереди не дольше waitTimeout, а при
+// waitTimeout <= 0 не ждет вовсе. Нужен быстрым сценариям, у которых есть запасной путь без модели.
+func (p *KeyPool) AcquireWithin(ctx context.Context, waitTimeout time.Duration) (KeyLease, error) {
+	waitCtx, cancel := context.WithTimeout(ctx, max(waitTimeout, 0))
+	defer cancel()
+
+	return p.acquire(ctx, waitCtx, waitTimeout)
+}
+
+func (p *KeyPool) acquire(ctx context.Context, waitCtx context.Context, waitTimeout time.Duration) (KeyLease, error) {
+	if len(p.keys) == 0 {
+		return KeyLease{index: -1}, nil
+	}
+
+	for {
+		p.mu.Lock()
+
+		now := time.Now()
+		idx, retryAt := p.pick(now)
+
+		if idx >= 0 {
+			key := p.keys[idx]
+			key.inFlight++
+			key.totalRequests++
+			key.requests = append(key.requests, now)
+			p.next = (idx + 1) % len(p.keys)
+			p.mu.Unlock()
+
+			return KeyLease{Value: key.value, index: idx}, nil
+		}
+
+		released := p.released
+		p.waiting++
+		p.mu.Unlock()
+
+		err := p.wait(ctx, waitCtx, waitTimeout, released, retryAt)
+
+		p.mu.Lock()
+		p.waiting--
+		p.mu.Unlock()
+
+		if err != nil {
+			return KeyLease{}, err
+		}
+	}
+}
+
+func (p *KeyPool) wait(ctx context.Context, waitCtx context.Context, waitTimeout time.Duration,
+	released <-chan struct{}, retryAt time.Time) error {
+	var retry <-chan time.Time
+
+	if !retryAt.IsZero() {
+		timer := time.NewTimer(time.Until(retryAt))
+		defer timer.Stop()
+		retry = timer.C
+	}
+
+	select {
+	case <-waitCtx.Done():
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		logger.Error(ctx, fmt.Sprintf("no free llm keys after waiting %s", waitTimeout))
+		return internalErrors.ErrAllKeysAreUsing
+	case <-released:
+	case <-retry:
+	}
+
+	return nil
+}
+
+// pick выбирает следующий по кругу свободный ключ. Если свободных нет, возвращает -1 и ближайшее время,
+// когда ключ освободится по cooldown или лимиту запросов в минуту (освобождение по конкурентности
+// будит ожидающих через released).
+func (p *KeyPool) pick(now time.Time) (int, time.Time) {
+	var retryAt time.Time
+
+	for i := range p.keys {
+		idx := (p.next + i) % len(p.keys)
+		key := p.keys[idx]
+
+		key.requests = pruneRequests(key.requests, now)
+
+		if key.inFlight >= p.maxConcurrency {
+			continue
+		}
+
+		availableAt := now
+
+		if now.Before(key.cooldownUntil) {
+			availableAt = key.cooldownUntil
+		}
+
+		if p.requestsPerMinute > 0 && len(key.requests) >= p.requestsPerMinute {
+			rateAt := key.requests[len(key.requests)-p.requestsPerMinute].Add(keyRateWindow)
+			if rateAt.After(availableAt) {
+				availableAt = rateAt
+			}
+		}
+
+		if !availableAt.After(now) {
+			return idx, time.Time{}
+		}
+
+		if retryAt.IsZero() || availableAt.Before(retryAt) {
+			retryAt = availableAt
+		}
+	}
+
+	return -1, retryAt
+}
+
+func pruneRequests(requests []time.Time, now time.Time) []time.Time {
+	border := now.Add(-keyRateWindow)
+
+	idx := 0
+	for idx < len(requests) && !requests[idx].After(border) {
+		idx++
+	}
+
+	return requests[idx:]
+}
+
+// Release возвращает ключ в пул. Ошибки отмененных запросов ключу не засчитываются.
+func (p *KeyPool) Release(ctx context.Context, lease KeyLease, err error) {
+	if lease.index < 0 {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := p.keys[lease.index]
+	key.inFlight--
+
+	if err != nil && ctx.Err() == nil {
+		now := time.Now()
+		key.totalErrors++
+		key.lastError = err.Error()
+		key.lastErrorAt = now
+
+		var statusErr *llm.StatusError
+		if errors.As(err, &statusErr) &&
+			(statusErr.Code == http.StatusTooManyRequests || statusErr.Code >= http.StatusInternalServerError) {
+			if statusErr.Code == http.StatusTooManyRequests {
+				key.rateLimited++
+			}
+
+			cooldown := max(p.cooldown, statusErr.RetryAfter)
+			key.cooldownUntil = now.Add(cooldown)
+
+			logger.Info(ctx, fmt.Sprintf("llm key %s is on cooldown for %s after status %d",
+				maskKey(key.value), cooldown, statusErr.Code))
+		}
+	}
+
+	close(p.released)
+	p.released = make(chan struct{})
+}
+
+func (p *KeyPool) Stats() models.LLMKeysStatsModel {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]models.LLMKeyStatsModel, 0, len(p.keys))
+
+	for _, key := range p.keys {
+		key.requests = pruneRequests(key.requests, now)
+
+		keyStats := models.LLMKeyStatsModel{
+			Key:                maskKey(key.value),
+			Healthy:            !now.Before(key.cooldownUntil),
+			InFlight:           key.inFlight,
+			RequestsLastMinute: len(key.requests),
+			TotalRequests:      key.totalRequests,
+			TotalErrors:        key.totalErrors,
+			RateLimited:        key.rateLimited,
+			LastError:          key.lastError,
+			LastErrorAt:        key.lastErrorAt,
+		}
+
+		if !keyStats.Healthy {
+			keyStats.CooldownUntil = key.cooldownUntil
+		}
+
+		keys = append(keys, keyStats)
+	}
+
+	return models.LLMKeysStatsModel{
+		Waiting: p.waiting,
+		Keys:    keys,
+	}
+}
+
+func maskKey(value string) string {
+	if len(value) <= keyVisibleTail {
+		return "***"
+	}
+
+	return "***" + value[len(value)-keyVisibleTail:]
+}
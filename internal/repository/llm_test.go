@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+)
+
+func newTestKeyPool(keys ...string) *KeyPool {
+	return NewKeyPool(&config.Config{
+		DeepSeekAPIKeys:      keys,
+		LLMKeyMaxConcurrency: 1,
+		LLMKeyCooldown:       time.Minute,
+		LLMKeyWaitTimeout:    time.Second,
+	})
+}
+
+// waitForWaiters ждет, пока в очереди пула окажется count ожидающих.
+func waitForWaiters(t *testing.T, pool *KeyPool, count int) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for pool.Stats().Waiting != count {
+		if time.Now().After(deadline) {
+			t.Fatalf("waiting = %d, want %d", pool.Stats().Waiting, count)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestKeyPool("key-a", "key-b")
+
+	first, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	second, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	if first.Value != "key-a" || second.Value != "key-b" {
+		t.Errorf("Acquire() keys = %s, %s, want key-a, key-b", first.Value, second.Value)
+	}
+
+	if _, err = pool.AcquireWithin(ctx, 0); !errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
+		t.Errorf("AcquireWithin() error = %v, want %v", err, internalErrors.ErrAllKeysAreUsing)
+	}
+
+	pool.Release(ctx, second, nil)
+
+	third, err := pool.AcquireWithin(ctx, 0)
+	if err != nil || third.Value != "key-b" {
+		t.Errorf("AcquireWithin() = %s, %v, want key-b", third.Value, err)
+	}
+}
+
+func TestKeyPoolQueue(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestKeyPool("key-a")
+
+	lease, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	type result struct {
+		lease KeyLease
+		err   error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		queued, queuedErr := pool.Acquire(ctx)
+		done <- result{lease: queued, err: queuedErr}
+	}()
+
+	waitForWaiters(t, pool, 1)
+	pool.Release(ctx, lease, nil)
+
+	select {
+	case got := <-done:
+		if got.err != nil || got.lease.Value != "key-a" {
+			t.Errorf("queued Acquire() = %s, %v, want key-a", got.lease.Value, got.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued Acquire() did not get released key")
+	}
+
+	if waiting := pool.Stats().Waiting; waiting != 0 {
+		t.Errorf("waiting = %d, want 0", waiting)
+	}
+}
+
+func TestKeyPoolAcquireCanceled(t *testing.T) {
+	pool := newTestKeyPool("key-a")
+
+	if _, err := pool.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		_, err := pool.Acquire(ctx)
+		done <- err
+	}()
+
+	waitForWaiters(t, pool, 1)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Acquire() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() was not interrupted by canceled context")
+	}
+
+	waitForWaiters(t, pool, 0)
+}
+
+func TestKeyPoolWaitTimeout(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestKeyPool("key-a")
+
+	if _, err := pool.Acquire(ctx); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	start := time.Now()
+
+	if _, err := pool.AcquireWithin(ctx, 20*time.Millisecond); !errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
+		t.Errorf("AcquireWithin() error = %v, want %v", err, internalErrors.ErrAllKeysAreUsing)
+	}
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("AcquireWithin() returned after %s, want to wait", elapsed)
+	}
+}
+
+func TestKeyPoolReleaseCooldown(t *testing.T) {
+	tests := []struct {
+		name            string
+		err             error
+		canceled        bool
+		wantCooldown    time.Duration
+		wantErrors      int64
+		wantRateLimited int64
+	}{
+		{
+			name: "success",
+		},
+		{
+			name:            "rate limited",
+			err:             &llm.StatusError{Code: http.StatusTooManyRequests},
+			wantCooldown:    time.Minute,
+			wantErrors:      1,
+			wantRateLimited: 1,
+		},
+		{
+			name:            "retry after is longer than cooldown",
+			err:             &llm.StatusError{Code: http.StatusTooManyRequests, RetryAfter: time.Hour},
+			wantCooldown:    time.Hour,
+			wantErrors:      1,
+			wantRateLimited: 1,
+		},
+		{
+			name:         "retry after is shorter than cooldown",
+			err:          &llm.StatusError{Code: http.StatusServiceUnavailable, RetryAfter: time.Second},
+			wantCooldown: time.Minute,
+			wantErrors:   1,
+		},
+		{
+			name:       "client error",
+			err:        &llm.StatusError{Code: http.StatusBadRequest},
+			wantErrors: 1,
+		},
+		{
+			name:       "network error",
+			err:        errors.New("connection reset"),
+			wantErrors: 1,
+		},
+		{
+			name:     "canceled request",
+			err:      &llm.StatusError{Code: http.StatusTooManyRequests},
+			canceled: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := newTestKeyPool("key-a")
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			lease, err := pool.Acquire(ctx)
+			if err != nil {
+				t.Fatalf("Acquire() error = %v", err)
+			}
+
+			if tt.canceled {
+				cancel()
+			}
+
+			released := time.Now()
+			pool.Release(ctx, lease, tt.err)
+
+			stats := pool.Stats().Keys[0]
+
+			if stats.InFlight != 0 || stats.TotalErrors != tt.wantErrors || stats.RateLimited != tt.wantRateLimited {
+				t.Errorf("stats = %+v, want no requests in flight, %d errors, %d rate limited",
+					stats, tt.wantErrors, tt.wantRateLimited)
+			}
+
+			if stats.Healthy != (tt.wantCooldown == 0) {
+				t.Fatalf("healthy = %t, want %t", stats.Healthy, tt.wantCooldown == 0)
+			}
+
+			if tt.wantCooldown == 0 {
+				return
+			}
+
+			if cooldown := stats.CooldownUntil.Sub(released); cooldown < tt.wantCooldown ||
+				cooldown > tt.wantCooldown+time.Second {
+				t.Errorf("cooldown = %s, want %s", cooldown, tt.wantCooldown)
+			}
+
+			_, err = pool.AcquireWithin(context.Background(), 0)
+			if !errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
+				t.Errorf("AcquireWithin() on cooldown error = %v, want %v", err, internalErrors.ErrAllKeysAreUsing)
+			}
+		})
+	}
+}
+
+func TestKeyPoolWaitsForCooldown(t *testing.T) {
+	ctx := context.Background()
+	pool := NewKeyPool(&config.Config{
+		DeepSeekAPIKeys: []string{"key-a"},
+		LLMKeyCooldown:  20 * time.Millisecond,
+	})
+
+	lease, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	pool.Release(ctx, lease, &llm.StatusError{Code: http.StatusBadGateway})
+
+	// Ожидающего будит истечение cooldown, а не Release.
+	if lease, err = pool.AcquireWithin(ctx, time.Second); err != nil || lease.Value != "key-a" {
+		t.Errorf("AcquireWithin() = %s, %v, want key-a after cooldown", lease.Value, err)
+	}
+}
+
+func TestKeyPoolRequestsPerMinute(t *testing.T) {
+	ctx := context.Background()
+	pool := NewKeyPool(&config.Config{
+		DeepSeekAPIKeys:         []string{"key-a"},
+		LLMKeyMaxConcurrency:    2,
+		LLMKeyRequestsPerMinute: 1,
+	})
+
+	lease, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	pool.Release(ctx, lease, nil)
+
+	if _, err = pool.AcquireWithin(ctx, 0); !errors.Is(err, internalErrors.ErrAllKeysAreUsing) {
+		t.Errorf("AcquireWithin() over rate limit error = %v, want %v", err, internalErrors.ErrAllKeysAreUsing)
+	}
+}
+
+func TestKeyPoolWithoutKeys(t *testing.T) {
+	ctx := context.Background()
+	pool := newTestKeyPool()
+
+	for i := 0; i < 3; i++ {
+		lease, err := pool.AcquireWithin(ctx, 0)
+		if err != nil || lease.Value != "" {
+			t.Fatalf("AcquireWithin() = %q, %v, want empty key", lease.Value, err)
+		}
+
+		pool.Release(ctx, lease, &llm.StatusError{Code: http.StatusTooManyRequests})
+	}
+
+	if stats := pool.Stats(); len(stats.Keys) != 0 || stats.Waiting != 0 {
+		t.Errorf("Stats() = %+v, want empty", stats)
+	}
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/postgres"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/repository/dao"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const llmUsageRecordTimeout = 5 * time.Second
+
+type LLMUsageRepo struct {
+	storage *postgres.Adapter
+}
+
+func NewLLMUsageRepo(storage *postgres.Adapter) *LLMUsageRepo {
+	return &LLMUsageRepo{storage: storage}
+}
+
+func (repo *LLMUsageRepo) RecordUsage(ctx context.Context, usage models.LLMUsageModel) error {
+	q := `INSERT INTO public.llm_usage (user_id, use_case, model, attempt, job_id, job_attempt, prompt_tokens,
+		completion_tokens, latency_ms, outcome, error, produced_content)
+		VALUES (NULLIF($1, 0), $2, $3, $4, NULLIF($5, 0), NULLIF($6, 0), $7, $8, $9, $10, NULLIF($11, ''), $12)`
+
+	_, err := repo.storage.Exec(ctx, q, usage.UserID, usage.UseCase, usage.Model, usage.Attempt, usage.JobID,
+		usage.JobAttempt, usage.PromptTokens, usage.CompletionTokens, usage.Latency.Milliseconds(), usage.Outcome,
+		usage.Error, usage.ProducedContent)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error recording llm usage: %v for userId: %d, use case: %s",
+			err, usage.UserID, usage.UseCase))
+		return internalErrors.ErrFailedToRecordLLMUsage
+	}
+
+	return nil
+}
+
+// GetGenerationUsage считает генерации и модернизации пользователя за текущие сутки и месяц. Генерация
+// тратит квоту, если модель прислала хотя бы часть ответа, даже если клиент оборвал стрим. Задача из
+// очереди тратит квоту один раз на все свои попытки, а вне задачи считается только первый вызов:
+// повторные запросы на исправление рецепта квоту не тратят.
+func (repo *LLMUsageRepo) GetGenerationUsage(ctx context.Context, userID uint) (models.GenerationUsageModel, error) {
+	return getGenerationUsage(ctx, repo.storage, userID)
+}
+
+func getGenerationUsage(ctx context.Context, queryer sqlx.QueryerContext,
+	userID uint) (models.GenerationUsageModel, error) {
+	q := `WITH charged AS (
+			SELECT MIN(created_at) AS charged_at
+			FROM public.llm_usage
+			WHERE user_id = $1 AND use_case IN ($2, $3) AND produced_content
+			  AND (job_id IS NOT NULL OR attempt = 1)
+			  AND created_at >= date_trunc('month', NOW())
+			GROUP BY job_id, CASE WHEN job_id IS NULL THEN id END
+		)
+		SELECT
+			COUNT(*) FILTER (WHERE charged_at >= date_trunc('day', NOW())) AS daily_used,
+			COUNT(*) AS monthly_used,
+			date_trunc('day', NOW()) + INTERVAL '1 day' AS daily_reset_at,
+			date_trunc('month', NOW()) + INTERVAL '1 month' AS monthly_reset_at
+		FROM charged`
+
+	var usage dao.GenerationUsageTable
+
+	err := queryer.QueryRowxContext(ctx, q, userID, llm.UseCaseGeneration,
+		llm.UseCaseModernization).StructScan(&usage)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting generation usage: %v for userId: %d", err, userID))
+		return models.GenerationUsageModel{}, internalErrors.ErrFailedToGetLLMUsage
+	}
+
+	return dao.ConvertGenerationUsageToModel(usage), nil
+}
+
+type LLMUsageRecorder interface {
+	RecordUsage(ctx context.Context, usage models.LLMUsageModel) error
+}
+
+// MeteredProvider записывает каждый вызов модели в журнал использования: пользователя, сценарий, модель,
+// токены из ответа провайдера, время ответа и исход. Ошибка записи в журнал на ответ модели не влияет.
+type MeteredProvider struct {
+	provider LLMProvider
+	usage    LLMUsageRecorder
+}
+
+func NewMeteredProvider(provider LLMProvider, usageRepo LLMUsageRecorder) *MeteredProvider {
+	return &MeteredProvider{provider: provider, usage: usageRepo}
+}
+
+func (p *MeteredProvider) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	start := time.Now()
+
+	resp, err := p.provider.Complete(ctx, req)
+	p.record(ctx, req, resp, time.Since(start), err)
+
+	return resp, err
+}
+
+func (p *MeteredProvider) Stream(ctx context.Context, req llm.Request, onDelta llm.OnDelta) (llm.Response, error) {
+	start := time.Now()
+
+	resp, err := p.provider.Stream(ctx, req, onDelta)
+	p.record(ctx, req, resp, time.Since(start), err)
+
+	return resp, err
+}
+
+func (p *MeteredProvider) record(ctx context.Context, req llm.Request, resp llm.Response, latency time.Duration,
+	err error) {
+	usage := models.LLMUsageModel{
+		UserID:           req.UserID,
+		UseCase:          req.UseCase,
+		Model:            req.Params.Model,
+		Attempt:          max(req.Attempt, 1),
+		JobID:            req.JobID,
+		JobAttempt:       req.JobAttempt,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		Latency:          latency,
+		Outcome:          models.LLMUsageOutcomeSuccess,
+		ProducedContent:  resp.Content != "",
+	}
+
+	switch {
+	case err == nil:
+	case ctx.Err() != nil:
+		usage.Outcome = models.LLMUsageOutcomeCanceled
+		usage.Error = err.Error()
+	default:
+		usage.Outcome = models.LLMUsageOutcomeError
+		usage.Error = err.Error()
+	}
+
+	// Отмененные вызовы тоже пишем в журнал, поэтому не наследуем отмену ctx.
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), llmUsageRecordTimeout)
+	defer cancel()
+
+	_ = p.usage.RecordUsage(recordCtx, usage)
+}
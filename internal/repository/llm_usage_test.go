@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+type usageRecorderStub struct {
+	records []models.LLMUsageModel
+}
+
+func (r *usageRecorderStub) RecordUsage(_ context.Context, usage models.LLMUsageModel) error {
+	r.records = append(r.records, usage)
+	return nil
+}
+
+func TestMeteredProviderRecordsUsage(t *testing.T) {
+	errStop := errors.New("stop")
+
+	tests := []struct {
+		name string
+		req  llm.Request
+		// call вызывает провайдера; cancel отменяет контекст вызова, как при обрыве соединения клиентом.
+		call            func(ctx context.Context, cancel context.CancelFunc, p *MeteredProvider, req llm.Request) error
+		wantOutcome     string
+		wantAttempt     int
+		wantContent     bool
+		wantTokens      bool
+		wantErrorRecord bool
+	}{
+		{
+			name: "complete success",
+			req:  llm.Request{UseCase: llm.UseCaseVoice, UserID: 7, Input: "дальше"},
+			call: func(ctx context.Context, _ context.CancelFunc, p *MeteredProvider, req llm.Request) error {
+				_, err := p.Complete(ctx, req)
+				return err
+			},
+			wantOutcome: models.LLMUsageOutcomeSuccess,
+			wantAttempt: 1,
+			wantContent: true,
+			wantTokens:  true,
+		},
+		{
+			name: "complete error",
+			req:  llm.Request{UseCase: "unknown", UserID: 7, Attempt: 2},
+			call: func(ctx context.Context, _ context.CancelFunc, p *MeteredProvider, req llm.Request) error {
+				_, err := p.Complete(ctx, req)
+				return err
+			},
+			wantOutcome:     models.LLMUsageOutcomeError,
+			wantAttempt:     2,
+			wantErrorRecord: true,
+		},
+		{
+			name: "stream success with job",
+			req:  llm.Request{UseCase: llm.UseCaseGeneration, UserID: 7, JobID: 42, JobAttempt: 3},
+			call: func(ctx context.Context, _ context.CancelFunc, p *MeteredProvider, req llm.Request) error {
+				_, err := p.Stream(ctx, req, func(string) error { return nil })
+				return err
+			},
+			wantOutcome: models.LLMUsageOutcomeSuccess,
+			wantAttempt: 1,
+			wantContent: true,
+			wantTokens:  true,
+		},
+		{
+			name: "stream interrupted by client",
+			req:  llm.Request{UseCase: llm.UseCaseGeneration, UserID: 7},
+			call: func(ctx context.Context, cancel context.CancelFunc, p *MeteredProvider, req llm.Request) error {
+				_, err := p.Stream(ctx, req, func(string) error {
+					cancel()
+					return ctx.Err()
+				})
+				return err
+			},
+			wantOutcome:     models.LLMUsageOutcomeCanceled,
+			wantAttempt:     1,
+			wantContent:     true,
+			wantTokens:      true,
+			wantErrorRecord: true,
+		},
+		{
+			name: "stream interrupted by handler error",
+			req:  llm.Request{UseCase: llm.UseCaseGeneration, UserID: 7},
+			call: func(ctx context.Context, _ context.CancelFunc, p *MeteredProvider, req llm.Request) error {
+				_, err := p.Stream(ctx, req, func(string) error { return errStop })
+				return err
+			},
+			wantOutcome:     models.LLMUsageOutcomeError,
+			wantAttempt:     1,
+			wantContent:     true,
+			wantTokens:      true,
+			wantErrorRecord: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &usageRecorderStub{}
+			provider := NewMeteredProvider(llm.NewFakeProvider(llm.DefaultFakeResponses()), recorder)
+
+			tt.req.Params = config.LLMParams{Model: "fake-model"}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			_ = tt.call(ctx, cancel, provider, tt.req)
+
+			if len(recorder.records) != 1 {
+				t.Fatalf("recorded %d usage rows, want 1", len(recorder.records))
+			}
+
+			got := recorder.records[0]
+
+			if got.Outcome != tt.wantOutcome || got.Attempt != tt.wantAttempt || got.ProducedContent != tt.wantContent {
+				t.Errorf("usage outcome = %s, attempt = %d, produced content = %t, want %s, %d, %t",
+					got.Outcome, got.Attempt, got.ProducedContent, tt.wantOutcome, tt.wantAttempt, tt.wantContent)
+			}
+
+			if got.UserID != tt.req.UserID || got.UseCase != tt.req.UseCase || got.Model != "fake-model" ||
+				got.JobID != tt.req.JobID || got.JobAttempt != tt.req.JobAttempt {
+				t.Errorf("usage = %+v, does not match request %+v", got, tt.req)
+			}
+
+			if (got.CompletionTokens > 0) != tt.wantTokens {
+				t.Errorf("usage completion tokens = %d, want tokens: %t", got.CompletionTokens, tt.wantTokens)
+			}
+
+			if (got.Error != "") != tt.wantErrorRecord {
+				t.Errorf("usage error = %q, want error recorded: %t", got.Error, tt.wantErrorRecord)
+			}
+		})
+	}
+}
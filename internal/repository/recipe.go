@@ -14,9 +14,16 @@ import (
 	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 	"github.com/Olegsandrik/Exponenta/internal/repository/dao"
 	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/internal/utils"
 	"github.com/Olegsandrik/Exponenta/logger"
 )
 
+const (
+	timerColumns = "timer_id, session_id, step_num, description, label, end_time, is_paused, remaining_sec, is_auto"
+
+	historyStatusAbandoned = "abandoned"
+)
+
 type CookingRecipeRepo struct {
 	storage *postgres.Adapter
 }
@@ -136,36 +143,296 @@ func (repo *CookingRecipeRepo) getRecipeByID(ctx context.Context, tx *sqlx.Tx, i
 	return recipeItem, nil
 }
 
-func (repo *CookingRecipeRepo) EndCooking(ctx context.Context, uID uint) (int, bool, error) {
+func (repo *CookingRecipeRepo) GetDefaultSessionID(ctx context.Context, uID uint) (int, error) {
+	var sessionID int
+	q := `SELECT id FROM public.current_recipe WHERE user_id = $1 AND is_default`
+
+	err := repo.storage.QueryRow(ctx, q, uID).Scan(&sessionID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Error(ctx, fmt.Sprintf("no default cooking session for userId: %d", uID))
+			return 0, internalErrors.ErrNoCurrentRecipe
+		}
+		logger.Error(ctx, fmt.Sprintf("error getting default session: %e for userId: %d", err, uID))
+		return 0, internalErrors.ErrFailedToGetCurrentRecipe
+	}
+
+	return sessionID, nil
+}
+
+func (repo *CookingRecipeRepo) GetCookingSessions(ctx context.Context, uID uint) ([]models.CurrentRecipeModel, error) {
+	q := `SELECT cr.id AS session_id, cr.is_default, cr.recipe_id, cr.name, cr.total_steps, cr.is_generated,
+       		cr.servings, cr.ingredients AS recipe_ingredients,
+       		CASE WHEN cr.join_code_expires_at > NOW() THEN cr.join_code ELSE '' END AS join_code,
+       		cr.auto_timers,
+       		cr.user_id <> $1 AS is_guest,
+       		cs.step_num, cs.step, cs.ingredients, cs.equipment, cs.length
+		  FROM public.current_recipe as cr
+		  LEFT JOIN public.current_recipe_step as cs ON cs.session_id = cr.id AND cr.current_step_num=cs.step_num
+		  WHERE cr.user_id = $1 
+		     OR cr.id IN (SELECT m.session_id FROM public.current_recipe_member AS m WHERE m.user_id = $1)
+		  ORDER BY cr.id`
+
+	var recipeRows []dao.CurrentRecipeTable
+
+	err := repo.storage.Select(ctx, &recipeRows, q, uID)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting cooking sessions: %s with userId: %d", err.Error(), uID))
+		return nil, internalErrors.ErrFailedToGetCurrentRecipe
+	}
+
+	if len(recipeRows) == 0 {
+		logger.Error(ctx, fmt.Sprintf("cooking sessions not found with userId: %d", uID))
+		return nil, internalErrors.ErrNoCurrentRecipe
+	}
+
+	sessions := make([]models.CurrentRecipeModel, 0, len(recipeRows))
+	for _, row := range recipeRows {
+		sessions = append(sessions, dao.ConvertDaoToCurrentRecipe(row))
+	}
+
+	logger.Info(ctx, fmt.Sprintf("got %d cooking sessions for userId: %d", len(sessions), uID))
+
+	return sessions, nil
+}
+
+func (repo *CookingRecipeRepo) GetSessionOwnerID(ctx context.Context, uID uint, sessionID int) (uint, error) {
+	var ownerID uint
+	q := `SELECT cr.user_id FROM public.current_recipe AS cr 
+		WHERE cr.id = $1 AND (cr.user_id = $2 OR EXISTS (
+		    SELECT 1 FROM public.current_recipe_member AS m WHERE m.session_id = cr.id AND m.user_id = $2))`
+
+	err := repo.storage.QueryRow(ctx, q, sessionID, uID).Scan(&ownerID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Error(ctx, fmt.Sprintf("cooking session %d not found for userId: %d", sessionID, uID))
+			return 0, internalErrors.ErrNoCurrentRecipe
+		}
+		logger.Error(ctx, fmt.Sprintf("error getting session owner: %v for userId: %d, sessionId: %d",
+			err, uID, sessionID))
+		return 0, internalErrors.ErrFailedToGetCurrentRecipe
+	}
+
+	return ownerID, nil
+}
+
+// SetJoinCode выдает сессии новый код приглашения, действующий ttl. Прежний код при этом перестает действовать.
+func (repo *CookingRecipeRepo) SetJoinCode(ctx context.Context, uID uint, sessionID int, code string,
+	ttl time.Duration) error {
+	q := `UPDATE public.current_recipe
+		SET join_code = $3, join_code_expires_at = NOW() + make_interval(secs => $4)
+		WHERE id = $1 AND user_id = $2`
+
+	result, err := repo.storage.Exec(ctx, q, sessionID, uID, code, ttl.Seconds())
+
+	if err != nil {
+		if repo.storage.IsDuplicateKeyError(err) {
+			logger.Error(ctx, fmt.Sprintf("join code collision for userId: %d, sessionId: %d", uID, sessionID))
+			return internalErrors.ErrJoinCodeAlreadyExists
+		}
+		logger.Error(ctx, fmt.Sprintf("failed to set join code: %v for userId: %d, sessionId: %d",
+			err, uID, sessionID))
+		return internalErrors.ErrFailedToCreateJoinCode
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by update: %v for userId: %d", err, uID))
+		return internalErrors.ErrFailedToCreateJoinCode
+	}
+
+	if rowsAffected == 0 {
+		logger.Error(ctx, fmt.Sprintf("not found session to set join code for userId: %d, sessionId: %d",
+			uID, sessionID))
+		return internalErrors.ErrNoCurrentRecipe
+	}
+
+	logger.Info(ctx, fmt.Sprintf("set join code for userId: %d, sessionId: %d", uID, sessionID))
+
+	return nil
+}
+
+// JoinSession добавляет пользователя в сессию по действующему коду. Код одноразовый и гасится при входе.
+func (repo *CookingRecipeRepo) JoinSession(ctx context.Context, uID uint, code string) (int, error) {
+	var sessionID int
+	q := `WITH used AS (
+			UPDATE public.current_recipe SET join_code = NULL, join_code_expires_at = NULL
+			WHERE join_code = $1 AND join_code_expires_at > NOW() AND user_id <> $2
+			RETURNING id
+		)
+		INSERT INTO public.current_recipe_member (session_id, user_id)
+		SELECT id, $2 FROM used
+		ON CONFLICT (session_id, user_id) DO UPDATE SET joined_at = current_recipe_member.joined_at
+		RETURNING session_id`
+
+	err := repo.storage.QueryRow(ctx, q, code, uID).Scan(&sessionID)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Error(ctx, fmt.Sprintf("no session to join by code for userId: %d", uID))
+			return 0, internalErrors.ErrInvalidJoinCode
+		}
+		logger.Error(ctx, fmt.Sprintf("failed to join session: %v for userId: %d", err, uID))
+		return 0, internalErrors.ErrFailedToJoinSession
+	}
+
+	logger.Info(ctx, fmt.Sprintf("userId: %d joined sessionId: %d", uID, sessionID))
+
+	return sessionID, nil
+}
+
+func (repo *CookingRecipeRepo) LeaveSession(ctx context.Context, uID uint, sessionID int) error {
+	q := `DELETE FROM public.current_recipe_member WHERE session_id = $1 AND user_id = $2`
+
+	result, err := repo.storage.Exec(ctx, q, sessionID, uID)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to leave session: %v for userId: %d, sessionId: %d",
+			err, uID, sessionID))
+		return internalErrors.ErrFailToEndCooking
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by delete: %v for userId: %d", err, uID))
+		return internalErrors.ErrFailToEndCooking
+	}
+
+	if rowsAffected == 0 {
+		logger.Error(ctx, fmt.Sprintf("userId: %d is not a member of sessionId: %d", uID, sessionID))
+		return internalErrors.ErrNoCurrentRecipe
+	}
+
+	logger.Info(ctx, fmt.Sprintf("userId: %d left sessionId: %d", uID, sessionID))
+
+	return nil
+}
+
+func (repo *CookingRecipeRepo) GetSessionMembers(ctx context.Context, sessionID int) ([]uint, error) {
+	q := `SELECT user_id FROM public.current_recipe_member WHERE session_id = $1 ORDER BY joined_at`
+
+	members := make([]uint, 0)
+
+	err := repo.storage.Select(ctx, &members, q, sessionID)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting session members: %v with sessionId: %d", err, sessionID))
+		return nil, internalErrors.ErrFailedToGetCurrentRecipe
+	}
+
+	return members, nil
+}
+
+func (repo *CookingRecipeRepo) TouchSession(ctx context.Context, sessionID int) error {
+	q := `UPDATE public.current_recipe SET last_activity_at = NOW() WHERE id = $1`
+
+	_, err := repo.storage.Exec(ctx, q, sessionID)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to update session activity: %v with sessionId: %d", err, sessionID))
+		return internalErrors.ErrFailedToGetCurrentRecipe
+	}
+
+	return nil
+}
+
+// ClearExpiredJoinCodes стирает коды приглашения с истекшим сроком, чтобы они освободились для новых сессий.
+func (repo *CookingRecipeRepo) ClearExpiredJoinCodes(ctx context.Context) error {
+	q := `UPDATE public.current_recipe SET join_code = NULL, join_code_expires_at = NULL
+		WHERE join_code IS NOT NULL AND (join_code_expires_at IS NULL OR join_code_expires_at <= NOW())`
+
+	result, err := repo.storage.Exec(ctx, q)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error clearing expired join codes: %v", err))
+		return internalErrors.ErrFailedToClearJoinCodes
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected > 0 {
+		logger.Info(ctx, fmt.Sprintf("cleared %d expired join codes", rowsAffected))
+	}
+
+	return nil
+}
+
+// ExpireSessions переносит в историю со статусом abandoned сессии без активности дольше ttl
+// и без запущенных таймеров, строки сессий удаляются каскадно.
+func (repo *CookingRecipeRepo) ExpireSessions(ctx context.Context, ttl time.Duration) ([]int, error) {
+	q := `WITH expired AS (
+			DELETE FROM public.current_recipe AS cr
+			WHERE cr.last_activity_at < NOW() - make_interval(secs => $1)
+			  AND NOT EXISTS (SELECT 1 FROM public.timers AS t 
+			                  WHERE t.session_id = cr.id AND NOT t.is_expired AND NOT t.is_paused)
+			RETURNING cr.id, cr.user_id, cr.recipe_id, cr.is_generated
+		), participants AS (
+			SELECT e.id, e.user_id, e.recipe_id, e.is_generated FROM expired AS e
+			UNION ALL
+			SELECT e.id, m.user_id, e.recipe_id, e.is_generated FROM expired AS e
+			JOIN public.current_recipe_member AS m ON m.session_id = e.id
+		), inserted AS (
+			INSERT INTO public.user_cooking_history (user_id, recipe_id, is_generated, session_id, status)
+			SELECT user_id, recipe_id, is_generated, id, $2 FROM participants
+			RETURNING session_id
+		)
+		SELECT DISTINCT session_id FROM inserted`
+
+	sessionIDs := make([]int, 0)
+
+	err := repo.storage.Select(ctx, &sessionIDs, q, ttl.Seconds(), historyStatusAbandoned)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error expiring cooking sessions: %v with ttl: %s", err, ttl))
+		return nil, internalErrors.ErrFailedToExpireSessions
+	}
+
+	if len(sessionIDs) > 0 {
+		logger.Info(ctx, fmt.Sprintf("expired %d abandoned cooking sessions", len(sessionIDs)))
+	}
+
+	return sessionIDs, nil
+}
+
+func (repo *CookingRecipeRepo) EndCooking(ctx context.Context, uID uint, sessionID int) (int, bool, error) {
 	var recipeID int
 	var isGenerated bool
-	q := `DELETE FROM public.current_recipe WHERE user_id = $1 RETURNING recipe_id, is_generated`
+	q := `DELETE FROM public.current_recipe WHERE id = $1 AND user_id = $2 RETURNING recipe_id, is_generated`
 
-	err := repo.storage.QueryRow(ctx, q, uID).Scan(&recipeID, &isGenerated)
+	err := repo.storage.QueryRow(ctx, q, sessionID, uID).Scan(&recipeID, &isGenerated)
 
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			logger.Error(ctx, fmt.Sprintf("zero values scan from deleting for userId: %d", uID))
+			logger.Error(ctx, fmt.Sprintf("zero values scan from deleting for userId: %d, sessionId: %d",
+				uID, sessionID))
 			return 0, false, internalErrors.ErrNoCurrentRecipe
 		}
-		logger.Error(ctx, fmt.Sprintf("error deleting recipe row: %e with id: %d", err, uID))
+		logger.Error(ctx, fmt.Sprintf("error deleting recipe row: %e with id: %d, sessionId: %d",
+			err, uID, sessionID))
 		return 0, false, internalErrors.ErrFailToEndCooking
 	}
 
-	logger.Info(ctx, fmt.Sprintf("delete row into current_recipe with userId %d", uID))
+	logger.Info(ctx, fmt.Sprintf("delete row into current_recipe with userId %d, sessionId: %d", uID, sessionID))
 
 	return recipeID, isGenerated, nil
 }
 
 func (repo *CookingRecipeRepo) AddRecipeToHistory(ctx context.Context,
-	uID uint, recipeID int, isGenerated bool) error {
-	q := `INSERT INTO public.user_cooking_history (user_id, recipe_id, is_generated) VALUES ($1, $2, $3)`
+	uID uint, recipeID int, isGenerated bool, sessionID int, feedback models.CookingFeedbackModel) error {
+	q := `INSERT INTO public.user_cooking_history
+	(user_id, recipe_id, is_generated, session_id, rating, notes, photo)
+	VALUES ($1, $2, $3, $4, NULLIF($5, 0), NULLIF($6, ''), NULLIF($7, ''))`
 
-	result, err := repo.storage.Exec(ctx, q, uID, recipeID, isGenerated)
+	result, err := repo.storage.Exec(ctx, q, uID, recipeID, isGenerated, sessionID,
+		feedback.Rating, feedback.Notes, feedback.Photo)
 
 	if err != nil {
 		logger.Info(ctx, fmt.Sprintf(
-			"error adding recipe to history row: %e with id: %d, isGen: %t", err, uID, isGenerated))
+			"error adding recipe to history row: %e with id: %d, isGen: %t, sessionId: %d",
+			err, uID, isGenerated, sessionID))
 		return internalErrors.ErrAddRecipeToUserCookingHistory
 	}
 
@@ -185,13 +452,14 @@ func (repo *CookingRecipeRepo) AddRecipeToHistory(ctx context.Context,
 	return nil
 }
 
-func (repo *CookingRecipeRepo) StartCooking(ctx context.Context, uID uint, recipeID int, isGenerated bool) error {
+func (repo *CookingRecipeRepo) StartCooking(ctx context.Context, uID uint, recipeID int, isGenerated bool,
+	isDefault bool, servings int) (int, error) {
 	tx, err := repo.storage.BeginTx(ctx, nil)
 	if err != nil {
 		logger.Error(ctx,
 			fmt.Sprintf("failed to begin transaction on start cooking for userId: %d, internalerrors: %e", uID, err),
 		)
-		return internalErrors.ErrFailToStartCooking
+		return 0, internalErrors.ErrFailToStartCooking
 	}
 
 	defer func() {
@@ -205,35 +473,46 @@ func (repo *CookingRecipeRepo) StartCooking(ctx context.Context, uID uint, recip
 	recipe, err := repo.getRecipe(ctx, tx, recipeID, isGenerated)
 
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if err = repo.insertCurrentRecipe(ctx, tx,
-		uID, recipeID, recipe.Name, recipe.TotalSteps, recipe.IsGenerated); err != nil {
-		return err
+	if !isGenerated {
+		recipe.Ingredients, err = repo.getRecipeIngredients(ctx, tx, recipeID)
+		if err != nil {
+			return 0, err
+		}
 	}
 
-	if err = repo.insertRecipeSteps(ctx, tx, uID, recipeID, recipe.Steps); err != nil {
-		return err
+	ingredients, servings := scaleRecipeIngredients(ctx, recipe, servings)
+
+	sessionID, err := repo.insertCurrentRecipe(ctx, tx,
+		uID, recipeID, recipe.Name, recipe.TotalSteps, recipe.IsGenerated, isDefault, servings, ingredients)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = repo.insertRecipeSteps(ctx, tx, uID, sessionID, recipeID, recipe.Steps, ingredients); err != nil {
+		return 0, err
 	}
 
 	if err = tx.Commit(); err != nil {
-		return err
+		return 0, err
 	}
 
 	logger.Info(ctx,
-		fmt.Sprintf("successfully started cooking recipe for userId: %d, recipeId: %d, isGenerated: %t",
-			uID, recipeID, recipe.IsGenerated),
+		fmt.Sprintf("successfully started cooking recipe for userId: %d, recipeId: %d, isGenerated: %t, sessionId: %d",
+			uID, recipeID, recipe.IsGenerated, sessionID),
 	)
 
-	return nil
+	return sessionID, nil
 }
 
 func (repo *CookingRecipeRepo) getRecipe(ctx context.Context, tx *sqlx.Tx, recipeID int,
 	isGenerated bool) (*dao.RecipeTable, error) {
-	q := `SELECT r.name, r.steps, r.total_steps FROM public.recipes as r WHERE id = $1`
+	q := `SELECT r.name, r.steps, r.total_steps, r.servings FROM public.recipes as r WHERE id = $1`
 	if isGenerated {
-		q = `SELECT r.name, r.steps, r.total_steps FROM public.generated_recipes as r WHERE id = $1`
+		q = `SELECT r.name, r.steps, r.total_steps, r.servings, r.ingredients 
+			FROM public.generated_recipes as r WHERE id = $1`
 	}
 
 	recipeRows := make([]dao.RecipeTable, 0, 1)
@@ -256,42 +535,96 @@ func (repo *CookingRecipeRepo) getRecipe(ctx context.Context, tx *sqlx.Tx, recip
 	return &recipeRows[0], nil
 }
 
-func (repo *CookingRecipeRepo) insertCurrentRecipe(ctx context.Context, tx *sqlx.Tx, uID uint, recipeID int,
-	name string, totalSteps int, isGenerated bool) error {
-	q := `INSERT INTO public.current_recipe (user_id, recipe_id, name, total_steps, is_generated) 
-	VALUES ($1, $2, $3, $4, $5)`
+func (repo *CookingRecipeRepo) getRecipeIngredients(ctx context.Context, tx *sqlx.Tx,
+	recipeID int) (json.RawMessage, error) {
+	q := `SELECT ri.ingredient_id, i.name, i.image, ri.amount, ri.unit FROM public.recipe_ingredients AS ri
+		  LEFT JOIN public.ingredients as i ON ri.ingredient_id = i.id
+		  WHERE ri.recipe_id = $1`
 
-	result, err := tx.ExecContext(ctx, q, uID, recipeID, name, totalSteps, isGenerated)
+	ingredientRows := make([]dao.IngredientTable, 0)
 
+	err := tx.SelectContext(ctx, &ingredientRows, q, recipeID)
 	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf("failed insert recipe row: %d for userId: %d, recipeId: %d",
-				err,
-				uID,
-				recipeID),
-		)
-		return internalErrors.ErrUserAlreadyCooking
+		logger.Error(ctx, fmt.Sprintf("error getting ingredients rows: %s with id: %d", err.Error(), recipeID))
+		return nil, internalErrors.ErrFailToGetIngredientsRecipeByID
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	return json.Marshal(ingredientRows)
+}
+
+// scaleRecipeIngredients пересчитывает ингредиенты рецепта на servings порций.
+// Возвращает ингредиенты и итоговое число порций сессии.
+func scaleRecipeIngredients(ctx context.Context, recipe *dao.RecipeTable,
+	servings int) (json.RawMessage, int) {
+	if servings <= 0 || recipe.ServingsNum <= 0 {
+		return recipe.Ingredients, recipe.ServingsNum
+	}
 
+	if servings == recipe.ServingsNum || len(recipe.Ingredients) == 0 {
+		return recipe.Ingredients, servings
+	}
+
+	var ingredients []dao.IngredientTable
+
+	if err := json.Unmarshal(recipe.Ingredients, &ingredients); err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to unmarshal ingredients of recipe %s: %v", recipe.Name, err))
+		return recipe.Ingredients, recipe.ServingsNum
+	}
+
+	factor := float64(servings) / float64(recipe.ServingsNum)
+
+	for i := range ingredients {
+		ingredients[i].Amount = utils.ScaleIngredientAmount(ingredients[i].Amount, ingredients[i].Unit, factor)
+	}
+
+	scaledIngredients, err := json.Marshal(ingredients)
 	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to marshal ingredients of recipe %s: %v", recipe.Name, err))
+		return recipe.Ingredients, recipe.ServingsNum
+	}
+
+	logger.Info(ctx, fmt.Sprintf("scaled recipe %s from %d to %d servings", recipe.Name, recipe.ServingsNum, servings))
+
+	return scaledIngredients, servings
+}
+
+func (repo *CookingRecipeRepo) insertCurrentRecipe(ctx context.Context, tx *sqlx.Tx, uID uint, recipeID int,
+	name string, totalSteps int, isGenerated bool, isDefault bool, servings int,
+	ingredients json.RawMessage) (int, error) {
+	var sessionID int
+	q := `INSERT INTO public.current_recipe 
+    	(user_id, recipe_id, name, total_steps, is_generated, is_default, servings, ingredients) 
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	err := tx.QueryRowxContext(ctx, q, uID, recipeID, name, totalSteps, isGenerated, isDefault, servings,
+		ingredients).Scan(&sessionID)
+
+	if err != nil {
+		if repo.storage.IsDuplicateKeyError(err) {
+			logger.Error(ctx,
+				fmt.Sprintf("default session already exists: %v for userId: %d, recipeId: %d",
+					err,
+					uID,
+					recipeID),
+			)
+			return 0, internalErrors.ErrUserAlreadyCooking
+		}
 		logger.Error(ctx,
-			fmt.Sprintf("internalerrors with get rows affected by insert: %d for userId: %d, recipeId: %d",
+			fmt.Sprintf("failed insert recipe row: %v for userId: %d, recipeId: %d",
 				err,
 				uID,
 				recipeID),
 		)
-		return internalErrors.ErrFailToStartCooking
+		return 0, internalErrors.ErrFailToStartCooking
 	}
 
-	logger.Info(ctx, fmt.Sprintf("inserted %d rows into current_recipe", rowsAffected))
+	logger.Info(ctx, fmt.Sprintf("inserted session %d into current_recipe", sessionID))
 
-	return nil
+	return sessionID, nil
 }
 
-func (repo *CookingRecipeRepo) insertRecipeSteps(
-	ctx context.Context, tx *sqlx.Tx, uID uint, recipeID int, stepsJSON string) error {
+func (repo *CookingRecipeRepo) insertRecipeSteps(ctx context.Context, tx *sqlx.Tx, uID uint, sessionID int,
+	recipeID int, stepsJSON string, ingredients json.RawMessage) error {
 	var steps []dao.CurrentRecipeStepTable
 
 	if err := json.Unmarshal([]byte(stepsJSON), &steps); err != nil {
@@ -299,10 +632,14 @@ func (repo *CookingRecipeRepo) insertRecipeSteps(
 		return internalErrors.ErrFailToStartCooking
 	}
 
+	if err := dao.FillStepsIngredientsAmount(steps, ingredients); err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to fill steps ingredients amount: %v with recipe %d", err, recipeID))
+	}
+
 	q := `INSERT INTO public.current_recipe_step
-		(user_id, recipe_id, step_num, step, ingredients, equipment, length) VALUES `
+		(user_id, session_id, recipe_id, step_num, step, ingredients, equipment, length) VALUES `
 
-	args := make([]interface{}, 0, 7*len(steps))
+	args := make([]interface{}, 0, 8*len(steps))
 
 	for i, step := range steps {
 		if string(step.Length) == "" {
@@ -319,10 +656,11 @@ func (repo *CookingRecipeRepo) insertRecipeSteps(
 			q += ", "
 		}
 
-		q += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d)",
-			7*i+1, 7*i+2, 7*i+3, 7*i+4, 7*i+5, 7*i+6, 7*i+7)
+		q += fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			8*i+1, 8*i+2, 8*i+3, 8*i+4, 8*i+5, 8*i+6, 8*i+7, 8*i+8)
 
-		args = append(args, uID, recipeID, step.NumStep, step.Step, step.Ingredients, step.Equipment, step.Length)
+		args = append(args, uID, sessionID, recipeID, step.NumStep, step.Step, step.Ingredients, step.Equipment,
+			step.Length)
 	}
 
 	result, err := tx.ExecContext(ctx, q, args...)
@@ -354,107 +692,172 @@ func (repo *CookingRecipeRepo) insertRecipeSteps(
 	return nil
 }
 
-func (repo *CookingRecipeRepo) GetCurrentRecipe(ctx context.Context, uID uint) (models.CurrentRecipeModel, error) {
-	q := `SELECT cr.recipe_id, cr.name, cr.total_steps, cr.is_generated, cs.step_num, cs.step, 
-       		cs.ingredients, cs.equipment, cs.length
+func (repo *CookingRecipeRepo) GetCurrentRecipe(ctx context.Context, uID uint,
+	sessionID int) (models.CurrentRecipeModel, error) {
+	q := `SELECT cr.id AS session_id, cr.is_default, cr.recipe_id, cr.name, cr.total_steps, cr.is_generated,
+       		cr.servings, cr.ingredients AS recipe_ingredients,
+       		CASE WHEN cr.join_code_expires_at > NOW() THEN cr.join_code ELSE '' END AS join_code,
+       		cr.auto_timers,
+       		cs.step_num, cs.step, cs.ingredients, cs.equipment, cs.length
 		  FROM public.current_recipe as cr
-		  LEFT JOIN public.current_recipe_step as cs ON cs.user_id = cr.user_id AND cr.current_step_num=cs.step_num
-		  WHERE cr.user_id = $1`
+		  LEFT JOIN public.current_recipe_step as cs ON cs.session_id = cr.id AND cr.current_step_num=cs.step_num
+		  WHERE cr.id = $1 AND cr.user_id = $2`
 
 	recipeRows := make([]dao.CurrentRecipeTable, 0, 1)
 
-	err := repo.storage.Select(ctx, &recipeRows, q, uID)
+	err := repo.storage.Select(ctx, &recipeRows, q, sessionID, uID)
 
 	if err != nil {
 		logger.Error(ctx,
-			fmt.Sprintf("error getting current recipe row: %s with recipeId: %d",
+			fmt.Sprintf("error getting current recipe row: %s with userId: %d, sessionId: %d",
 				err.Error(),
-				uID),
+				uID,
+				sessionID),
 		)
 		return models.CurrentRecipeModel{}, internalErrors.ErrFailedToGetCurrentRecipe
 	}
 
 	if len(recipeRows) == 0 {
-		logger.Error(ctx, fmt.Sprintf("recipe not found with userId: %d", uID))
+		logger.Error(ctx, fmt.Sprintf("recipe not found with userId: %d, sessionId: %d", uID, sessionID))
 		return models.CurrentRecipeModel{}, internalErrors.ErrFailedToGetCurrentRecipe
 	}
 
 	currentRecipeItem := dao.ConvertDaoToCurrentRecipe(recipeRows[0])
 
-	logger.Info(ctx, fmt.Sprintf("got current recipe row for userId: %d", uID))
+	logger.Info(ctx, fmt.Sprintf("got current recipe row for userId: %d, sessionId: %d", uID, sessionID))
 
 	return currentRecipeItem, nil
 }
 
-func (repo *CookingRecipeRepo) updateCurrentStepTxPlus(ctx context.Context, tx *sqlx.Tx, uID uint) error {
-	q := `UPDATE public.current_recipe SET current_step_num = current_step_num %s 1
-		WHERE user_id = $1`
+func (repo *CookingRecipeRepo) lockCurrentStepTx(ctx context.Context, tx *sqlx.Tx, uID uint,
+	sessionID int) (int, int, error) {
+	var currentStep, totalSteps int
+	q := `SELECT current_step_num, total_steps FROM public.current_recipe
+		WHERE id = $1 AND user_id = $2 FOR UPDATE`
+
+	err := tx.QueryRowxContext(ctx, q, sessionID, uID).Scan(&currentStep, &totalSteps)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			logger.Error(ctx, fmt.Sprintf("not found row to update current_step for userId: %d, sessionId: %d",
+				uID, sessionID))
+			return 0, 0, internalErrors.ErrNoCurrentRecipe
+		}
+		logger.Error(ctx, fmt.Sprintf("failed to get current_step: %v for userId: %d, sessionId: %d",
+			err, uID, sessionID))
+		return 0, 0, internalErrors.ErrFailedToUpdateRecipeStep
+	}
+
+	return currentStep, totalSteps, nil
+}
+
+func (repo *CookingRecipeRepo) updateCurrentStepTx(ctx context.Context, tx *sqlx.Tx, uID uint,
+	sessionID int, stepNum int) error {
+	q := `UPDATE public.current_recipe SET current_step_num = $3
+		WHERE id = $1 AND user_id = $2`
 
-	result, err := tx.ExecContext(ctx, q, uID)
+	result, err := tx.ExecContext(ctx, q, sessionID, uID, stepNum)
 
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("failed to update current_step: %e for userId: %d", err, uID))
+		logger.Error(ctx, fmt.Sprintf("failed to update current_step: %v for userId: %d, sessionId: %d",
+			err, uID, sessionID))
 		return internalErrors.ErrFailedToUpdateRecipeStep
 	}
 
 	rowsAffected, err := result.RowsAffected()
 
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by update: %d for userId: %d", err, uID))
+		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by update: %v for userId: %d", err, uID))
 		return internalErrors.ErrFailedToUpdateRecipeStep
 	}
 
 	if rowsAffected == 0 {
-		logger.Error(ctx, fmt.Sprintf("now found row to update current_step for userId: %d", uID))
+		logger.Error(ctx, fmt.Sprintf("not found row to update current_step for userId: %d, sessionId: %d",
+			uID, sessionID))
 		return internalErrors.ErrFailedToUpdateRecipeStep
 	}
 
 	return nil
 }
 
-func (repo *CookingRecipeRepo) updateCurrentStepTxMinus(ctx context.Context, tx *sqlx.Tx, uID uint) error {
-	q := `UPDATE public.current_recipe SET current_step_num = current_step_num - 1
-		WHERE user_id = $1`
+// moveCurrentStep переводит сессию на шаг, вычисленный из текущего, с проверкой границ рецепта.
+func (repo *CookingRecipeRepo) moveCurrentStep(ctx context.Context, uID uint, sessionID int,
+	nextStep func(currentStep int) int) (models.CurrentStepRecipeModel, error) {
+	tx, err := repo.storage.BeginTx(ctx, nil)
 
-	result, err := tx.ExecContext(ctx, q, uID)
+	if err != nil {
+		logger.Error(ctx,
+			fmt.Sprintf("failed to begin transaction on move step userId: %d, internalerrors: %v", uID, err),
+		)
+		return models.CurrentStepRecipeModel{}, internalErrors.ErrFailedToUpdateRecipeStep
+	}
+
+	defer func() {
+		if err != nil {
+			if err = tx.Rollback(); err != nil {
+				logger.Error(ctx,
+					fmt.Sprintf("failed to rollback transaction on move step: %v for userId: %d",
+						err,
+						uID),
+				)
+			}
+		}
+	}()
+
+	currentStep, totalSteps, err := repo.lockCurrentStepTx(ctx, tx, uID, sessionID)
 
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("failed to update current_step: %e for userId: %d", err, uID))
-		return internalErrors.ErrFailedToUpdateRecipeStep
+		return models.CurrentStepRecipeModel{}, err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	stepNum := nextStep(currentStep)
+
+	if stepNum < 1 || stepNum > totalSteps {
+		logger.Error(ctx, fmt.Sprintf("step %d out of range [1, %d] for userId: %d, sessionId: %d",
+			stepNum, totalSteps, uID, sessionID))
+		err = internalErrors.ErrStepOutOfRange
+		return models.CurrentStepRecipeModel{}, err
+	}
+
+	err = repo.updateCurrentStepTx(ctx, tx, uID, sessionID, stepNum)
 
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by update: %d for userId: %d", err, uID))
-		return internalErrors.ErrFailedToUpdateRecipeStep
+		return models.CurrentStepRecipeModel{}, err
 	}
 
-	if rowsAffected == 0 {
-		logger.Error(ctx, fmt.Sprintf("now found row to update current_step for userId: %d", uID))
-		return internalErrors.ErrFailedToUpdateRecipeStep
+	currentStepModel, err := repo.getCurrentStep(ctx, tx, uID, sessionID)
+
+	if err != nil {
+		return models.CurrentStepRecipeModel{}, err
 	}
 
-	return nil
+	if err = tx.Commit(); err != nil {
+		return models.CurrentStepRecipeModel{}, err
+	}
+
+	logger.Info(ctx, fmt.Sprintf("moved to step %d for userId: %d, sessionId: %d", stepNum, uID, sessionID))
+
+	return currentStepModel, nil
 }
 
 func (repo *CookingRecipeRepo) getCurrentStep(
-	ctx context.Context, queryer sqlx.QueryerContext, uID uint) (models.CurrentStepRecipeModel, error) {
+	ctx context.Context, queryer sqlx.QueryerContext, uID uint, sessionID int) (models.CurrentStepRecipeModel, error) {
 	currentStep := make([]dao.CurrentRecipeStepTable, 0, 1)
 
 	q := `SELECT cs.step_num, cs.step, cs.ingredients, cs.equipment, cs.length 
 		FROM public.current_recipe as cr LEFT JOIN public.current_recipe_step as cs
-		ON cr.current_step_num = cs.step_num AND cr.user_id=cs.user_id 
-		WHERE cr.user_id = $1;`
+		ON cr.current_step_num = cs.step_num AND cr.id=cs.session_id 
+		WHERE cr.id = $1 AND cr.user_id = $2;`
 
-	err := sqlx.SelectContext(ctx, queryer, &currentStep, q, uID)
+	err := sqlx.SelectContext(ctx, queryer, &currentStep, q, sessionID, uID)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("error getting recipe step row: %s with recipeId: %d", err.Error(), uID))
+		logger.Error(ctx, fmt.Sprintf("error getting recipe step row: %s with userId: %d, sessionId: %d",
+			err.Error(), uID, sessionID))
 		return models.CurrentStepRecipeModel{}, internalErrors.ErrFailedToGetCurrentStepCooking
 	}
 
 	if len(currentStep) == 0 {
-		logger.Error(ctx, fmt.Sprintf("recipe step not found with userId: %d", uID))
+		logger.Error(ctx, fmt.Sprintf("recipe step not found with userId: %d, sessionId: %d", uID, sessionID))
 		return models.CurrentStepRecipeModel{}, internalErrors.ErrFailedToGetCurrentStepCooking
 	}
 
@@ -463,155 +866,270 @@ func (repo *CookingRecipeRepo) getCurrentStep(
 	return currentStepModel, nil
 }
 
-func (repo *CookingRecipeRepo) GetPrevRecipeStep(ctx context.Context, uID uint) (models.CurrentStepRecipeModel, error) {
-	tx, err := repo.storage.BeginTx(ctx, nil)
+func (repo *CookingRecipeRepo) GetPrevRecipeStep(ctx context.Context, uID uint,
+	sessionID int) (models.CurrentStepRecipeModel, error) {
+	return repo.moveCurrentStep(ctx, uID, sessionID, func(currentStep int) int {
+		return currentStep - 1
+	})
+}
 
-	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf("failed to begin transaction on get prev step userId: %d, internalerrors: %d", uID, err),
-		)
-		return models.CurrentStepRecipeModel{}, internalErrors.ErrFailedToGetPrevStep
-	}
+func (repo *CookingRecipeRepo) GetNextRecipeStep(ctx context.Context, uID uint,
+	sessionID int) (models.CurrentStepRecipeModel, error) {
+	return repo.moveCurrentStep(ctx, uID, sessionID, func(currentStep int) int {
+		return currentStep + 1
+	})
+}
 
-	defer func() {
-		if err != nil {
-			if err = tx.Rollback(); err != nil {
-				logger.Error(ctx,
-					fmt.Sprintf("Failed to rollback transaction on get prev step: %d for userId: %d",
-						err,
-						uID),
-				)
-			}
-		}
-	}()
+func (repo *CookingRecipeRepo) GoToRecipeStep(ctx context.Context, uID uint, sessionID int,
+	stepNum int) (models.CurrentStepRecipeModel, error) {
+	return repo.moveCurrentStep(ctx, uID, sessionID, func(int) int {
+		return stepNum
+	})
+}
+
+func (repo *CookingRecipeRepo) SetStepDone(ctx context.Context, uID uint, sessionID int, stepNum int,
+	isDone bool) error {
+	q := `UPDATE public.current_recipe_step SET is_done = $4
+		WHERE user_id = $1 AND session_id = $2 AND step_num = $3`
 
-	err = repo.updateCurrentStepTxMinus(ctx, tx, uID)
+	result, err := repo.storage.Exec(ctx, q, uID, sessionID, stepNum, isDone)
 
 	if err != nil {
-		return models.CurrentStepRecipeModel{}, err
+		logger.Error(ctx, fmt.Sprintf("failed to mark step: %v for userId: %d, sessionId: %d, stepNum: %d",
+			err, uID, sessionID, stepNum))
+		return internalErrors.ErrFailedToMarkStep
 	}
 
-	currentStepModel, err := repo.getCurrentStep(ctx, tx, uID)
+	rowsAffected, err := result.RowsAffected()
 
 	if err != nil {
-		return models.CurrentStepRecipeModel{}, err
+		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by update: %v for userId: %d", err, uID))
+		return internalErrors.ErrFailedToMarkStep
 	}
 
-	if err = tx.Commit(); err != nil {
-		return models.CurrentStepRecipeModel{}, err
+	if rowsAffected == 0 {
+		logger.Error(ctx, fmt.Sprintf("not found step to mark for userId: %d, sessionId: %d, stepNum: %d",
+			uID, sessionID, stepNum))
+		return internalErrors.ErrStepOutOfRange
 	}
 
-	logger.Info(ctx, fmt.Sprintf("get prev current step for userId: %d", uID))
+	logger.Info(ctx, fmt.Sprintf("marked step %d as done=%t for userId: %d, sessionId: %d",
+		stepNum, isDone, uID, sessionID))
 
-	return currentStepModel, nil
+	return nil
 }
 
-func (repo *CookingRecipeRepo) GetNextRecipeStep(ctx context.Context, uID uint) (models.CurrentStepRecipeModel, error) {
-	tx, err := repo.storage.BeginTx(ctx, nil)
+func (repo *CookingRecipeRepo) GetDoneSteps(ctx context.Context, uID uint, sessionID int) ([]int, error) {
+	q := `SELECT step_num FROM public.current_recipe_step
+		WHERE user_id = $1 AND session_id = $2 AND is_done ORDER BY step_num`
+
+	doneSteps := make([]int, 0)
+
+	err := repo.storage.Select(ctx, &doneSteps, q, uID, sessionID)
 
 	if err != nil {
-		logger.Error(ctx,
-			fmt.Sprintf("failed to begin transaction on get next step userId: %d, internalerrors: %d", uID, err),
-		)
-		return models.CurrentStepRecipeModel{}, internalErrors.ErrFailedToGetNextStep
+		logger.Error(ctx, fmt.Sprintf("error getting done steps: %v with userId: %d, sessionId: %d",
+			err, uID, sessionID))
+		return nil, internalErrors.ErrFailedToGetCurrentRecipe
 	}
 
-	defer func() {
-		if err != nil {
-			if err = tx.Rollback(); err != nil {
-				logger.Error(ctx,
-					fmt.Sprintf("failed to rollback transaction on get next step: %d for userId: %d",
-						err,
-						uID),
-				)
-			}
-		}
-	}()
+	return doneSteps, nil
+}
+
+func (repo *CookingRecipeRepo) GetRecipeSteps(ctx context.Context, uID uint,
+	sessionID int) ([]models.CurrentStepRecipeModel, error) {
+	q := `SELECT step, step_num, ingredients, equipment, length FROM public.current_recipe_step
+		WHERE user_id = $1 AND session_id = $2 ORDER BY step_num`
 
-	err = repo.updateCurrentStepTxPlus(ctx, tx, uID)
+	recipeStepRows := make([]dao.CurrentRecipeStepTable, 0)
+
+	err := repo.storage.Select(ctx, &recipeStepRows, q, uID, sessionID)
 
 	if err != nil {
-		return models.CurrentStepRecipeModel{}, err
+		logger.Error(ctx, fmt.Sprintf("error getting recipe steps: %v with userId: %d, sessionId: %d",
+			err, uID, sessionID))
+		return nil, internalErrors.ErrFailedToGetPrepChecklist
+	}
+
+	recipeSteps := make([]models.CurrentStepRecipeModel, len(recipeStepRows))
+	for i, step := range recipeStepRows {
+		recipeSteps[i] = dao.ConvertDaoToCurrentStepRecipe(step)
 	}
 
-	currentStepModel, err := repo.getCurrentStep(ctx, tx, uID)
+	return recipeSteps, nil
+}
+
+func (repo *CookingRecipeRepo) GetCheckedPrepItems(ctx context.Context, sessionID int) ([]string, error) {
+	q := `SELECT item_id FROM public.current_recipe_prep WHERE session_id = $1`
+
+	itemIDs := make([]string, 0)
+
+	err := repo.storage.Select(ctx, &itemIDs, q, sessionID)
 
 	if err != nil {
-		return models.CurrentStepRecipeModel{}, err
+		logger.Error(ctx, fmt.Sprintf("error getting checked prep items: %v with sessionId: %d", err, sessionID))
+		return nil, internalErrors.ErrFailedToGetPrepChecklist
 	}
 
-	if err = tx.Commit(); err != nil {
-		return models.CurrentStepRecipeModel{}, err
+	return itemIDs, nil
+}
+
+func (repo *CookingRecipeRepo) SetPrepItemChecked(ctx context.Context, sessionID int, itemID string,
+	isChecked bool) error {
+	q := `INSERT INTO public.current_recipe_prep (session_id, item_id) VALUES ($1, $2)
+		ON CONFLICT (session_id, item_id) DO NOTHING`
+	if !isChecked {
+		q = `DELETE FROM public.current_recipe_prep WHERE session_id = $1 AND item_id = $2`
 	}
 
-	logger.Info(ctx, fmt.Sprintf("get next current step for userId: %d", uID))
+	_, err := repo.storage.Exec(ctx, q, sessionID, itemID)
 
-	return currentStepModel, nil
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to check prep item: %v for sessionId: %d, itemId: %s",
+			err, sessionID, itemID))
+		return internalErrors.ErrFailedToCheckPrepItem
+	}
+
+	logger.Info(ctx, fmt.Sprintf("marked prep item %s as checked=%t for sessionId: %d", itemID, isChecked, sessionID))
+
+	return nil
 }
 
-func (repo *CookingRecipeRepo) GetCurrentStep(ctx context.Context, uID uint) (models.CurrentStepRecipeModel, error) {
-	return repo.getCurrentStep(ctx, repo.storage, uID)
+func (repo *CookingRecipeRepo) GetCurrentStep(ctx context.Context, uID uint,
+	sessionID int) (models.CurrentStepRecipeModel, error) {
+	return repo.getCurrentStep(ctx, repo.storage, uID, sessionID)
 }
 
-func (repo *CookingRecipeRepo) AddTimerToRecipe(
-	ctx context.Context, uID uint, StepNum int, timeSec int, description string) error {
-	q := `INSERT INTO public.timers (user_id,step_num,description,end_time)
-		VALUES($1, $2, $3, $4);`
+func (repo *CookingRecipeRepo) AddTimerToRecipe(ctx context.Context, uID uint, sessionID int, StepNum int,
+	timeSec int, description string, label string) (models.TimerRecipeModel, error) {
+	q := `INSERT INTO public.timers (user_id, session_id, step_num, description, label, end_time)
+		SELECT cr.user_id, cr.id, $3, $4, $5, $6 FROM public.current_recipe AS cr WHERE cr.id = $1 AND cr.user_id = $2
+		RETURNING ` + timerColumns
 
 	endTime := time.Now().Add(time.Duration(timeSec) * time.Second)
 
-	result, err := repo.storage.Exec(ctx, q, uID, StepNum, description, endTime)
+	var timers []dao.TimerTable
+
+	err := repo.storage.Select(ctx, &timers, q, sessionID, uID, StepNum, description, label, endTime)
 
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf(
-			"failed to insert timer for userId: %d, step: %d, description: %s, endTime: %s, internalerrors: %s",
+			"failed to insert timer for userId: %d, sessionId: %d, step: %d, description: %s, endTime: %s, "+
+				"internalerrors: %s",
 			uID,
+			sessionID,
 			StepNum,
 			description,
 			endTime,
 			err))
-		return internalErrors.ErrFailedToAddTimer
+		return models.TimerRecipeModel{}, internalErrors.ErrFailedToAddTimer
 	}
 
-	rowsAffected, err := result.RowsAffected()
-
-	if err != nil {
+	if len(timers) == 0 {
 		logger.Error(ctx, fmt.Sprintf(
-			"internalerrors with get rows affected by insert: for userId: %d, "+
-				"step: %d, description: %s, endTime: %s, internalerrors: %s",
+			"no row affected by insert timer for userId: %d, sessionId: %d, step: %d, description: %s, endTime: %s",
 			uID,
+			sessionID,
 			StepNum,
 			description,
-			endTime,
-			err),
+			endTime),
 		)
-		return internalErrors.ErrFailedToAddTimer
+		return models.TimerRecipeModel{}, internalErrors.ErrFailedToAddTimer
 	}
 
-	if rowsAffected == 0 {
+	timersModel, err := dao.ConvertTimerToDAO(timers)
+
+	if err != nil {
 		logger.Error(ctx, fmt.Sprintf(
-			"no row affected by insert timer for userId: %d, step: %d, description: %s, endTime: %s, internalerrors: %s",
-			uID,
-			StepNum,
-			description,
-			endTime,
-			err),
-		)
-		return internalErrors.ErrFailedToAddTimer
+			"error converting timer table to model: %s with userId: %d", err.Error(), uID))
+		return models.TimerRecipeModel{}, internalErrors.ErrFailedToAddTimer
+	}
+
+	logger.Info(ctx, fmt.Sprintf("added timer %d for userId: %d, sessionId: %d", timersModel[0].ID, uID, sessionID))
+
+	return timersModel[0], nil
+}
+
+func (repo *CookingRecipeRepo) SetAutoTimers(ctx context.Context, uID uint, sessionID int, enabled bool) error {
+	q := `UPDATE public.current_recipe SET auto_timers = $3 WHERE id = $1 AND user_id = $2`
+
+	result, err := repo.storage.Exec(ctx, q, sessionID, uID, enabled)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to set auto timers: %v for userId: %d, sessionId: %d",
+			err, uID, sessionID))
+		return internalErrors.ErrFailedToSetAutoTimers
+	}
+
+	rowsAffected, err := result.RowsAffected()
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by update: %v for userId: %d", err, uID))
+		return internalErrors.ErrFailedToSetAutoTimers
+	}
+
+	if rowsAffected == 0 {
+		logger.Error(ctx, fmt.Sprintf("not found session to set auto timers for userId: %d, sessionId: %d",
+			uID, sessionID))
+		return internalErrors.ErrNoCurrentRecipe
 	}
 
+	logger.Info(ctx, fmt.Sprintf("set auto timers %t for userId: %d, sessionId: %d", enabled, uID, sessionID))
+
 	return nil
 }
 
-func (repo *CookingRecipeRepo) DeleteTimerFromRecipe(ctx context.Context, uID uint, StepNum int) error {
-	q := "DELETE FROM public.timers WHERE user_id=$1 AND step_num=$2"
+// AddAutoTimer запускает таймер шага, только если в сессии включены автотаймеры
+// и для шага ещё не было автотаймера. Второе значение - был ли таймер создан.
+func (repo *CookingRecipeRepo) AddAutoTimer(ctx context.Context, uID uint, sessionID int, stepNum int,
+	timeSec int, description string) (models.TimerRecipeModel, bool, error) {
+	q := `INSERT INTO public.timers (user_id, session_id, step_num, description, end_time, is_auto)
+		SELECT cr.user_id, cr.id, $3, $4, $5, true FROM public.current_recipe AS cr 
+		WHERE cr.id = $1 AND cr.user_id = $2 AND cr.auto_timers AND NOT EXISTS (
+		    SELECT 1 FROM public.timers AS t WHERE t.session_id = cr.id AND t.step_num = $3 AND t.is_auto)
+		RETURNING ` + timerColumns
+
+	endTime := time.Now().Add(time.Duration(timeSec) * time.Second)
+
+	var timers []dao.TimerTable
+
+	err := repo.storage.Select(ctx, &timers, q, sessionID, uID, stepNum, description, endTime)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf(
+			"failed to insert auto timer for userId: %d, sessionId: %d, step: %d, internalerrors: %v",
+			uID, sessionID, stepNum, err))
+		return models.TimerRecipeModel{}, false, internalErrors.ErrFailedToAddTimer
+	}
+
+	if len(timers) == 0 {
+		return models.TimerRecipeModel{}, false, nil
+	}
+
+	timersModel, err := dao.ConvertTimerToDAO(timers)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf(
+			"error converting timer table to model: %s with userId: %d", err.Error(), uID))
+		return models.TimerRecipeModel{}, false, internalErrors.ErrFailedToAddTimer
+	}
+
+	logger.Info(ctx, fmt.Sprintf("added auto timer %d for userId: %d, sessionId: %d, step: %d",
+		timersModel[0].ID, uID, sessionID, stepNum))
+
+	return timersModel[0], true, nil
+}
+
+func (repo *CookingRecipeRepo) DeleteTimerFromRecipe(ctx context.Context, uID uint, sessionID int, StepNum int) error {
+	q := "DELETE FROM public.timers WHERE user_id=$1 AND session_id=$2 AND step_num=$3"
 
-	result, err := repo.storage.Exec(ctx, q, uID, StepNum)
+	result, err := repo.storage.Exec(ctx, q, uID, sessionID, StepNum)
 
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf(
-			"failed to delete timer for userId: %d, step: %d, internalerrors: %s",
+			"failed to delete timer for userId: %d, sessionId: %d, step: %d, internalerrors: %s",
 			uID,
+			sessionID,
 			StepNum,
 			err),
 		)
@@ -628,8 +1146,9 @@ func (repo *CookingRecipeRepo) DeleteTimerFromRecipe(ctx context.Context, uID ui
 	}
 
 	if rowsAffected == 0 {
-		logger.Error(ctx, fmt.Sprintf("no row affected by delete: for userId: %d, step: %d",
+		logger.Error(ctx, fmt.Sprintf("no row affected by delete: for userId: %d, sessionId: %d, step: %d",
 			uID,
+			sessionID,
 			StepNum),
 		)
 		return internalErrors.ErrFailedToDeleteTimer
@@ -638,14 +1157,118 @@ func (repo *CookingRecipeRepo) DeleteTimerFromRecipe(ctx context.Context, uID ui
 	return nil
 }
 
-func (repo *CookingRecipeRepo) GetTimersRecipe(ctx context.Context, uID uint) ([]models.TimerRecipeModel, error) {
-	q := "SELECT description, end_time, step_num FROM public.timers WHERE user_id=$1"
+func (repo *CookingRecipeRepo) DeleteTimerByID(ctx context.Context, uID uint, sessionID int, timerID int) error {
+	q := "DELETE FROM public.timers WHERE user_id=$1 AND session_id=$2 AND timer_id=$3"
+
+	result, err := repo.storage.Exec(ctx, q, uID, sessionID, timerID)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf(
+			"failed to delete timer for userId: %d, sessionId: %d, timerId: %d, internalerrors: %s",
+			uID,
+			sessionID,
+			timerID,
+			err),
+		)
+		return internalErrors.ErrFailedToDeleteTimer
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("internalerrors with get rows affected by delete: for userId: %d, timerId: %d",
+			uID,
+			timerID),
+		)
+		return internalErrors.ErrFailedToDeleteTimer
+	}
+
+	if rowsAffected == 0 {
+		logger.Error(ctx, fmt.Sprintf("no row affected by delete: for userId: %d, sessionId: %d, timerId: %d",
+			uID,
+			sessionID,
+			timerID),
+		)
+		return internalErrors.ErrTimerNotFound
+	}
+
+	return nil
+}
+
+func (repo *CookingRecipeRepo) PauseTimer(ctx context.Context, uID uint, sessionID int,
+	timerID int) (models.TimerRecipeModel, error) {
+	q := `UPDATE public.timers SET is_paused = true,
+			remaining_sec = GREATEST(CEIL(EXTRACT(EPOCH FROM (end_time - $4::timestamp))), 0)
+		WHERE timer_id = $1 AND session_id = $2 AND user_id = $3 AND NOT is_paused AND NOT is_expired
+		RETURNING ` + timerColumns
+
+	return repo.updateTimer(ctx, "pause", q, timerID, sessionID, uID, time.Now())
+}
+
+func (repo *CookingRecipeRepo) ResumeTimer(ctx context.Context, uID uint, sessionID int,
+	timerID int) (models.TimerRecipeModel, error) {
+	q := `UPDATE public.timers SET is_paused = false,
+			end_time = $4::timestamp + make_interval(secs => remaining_sec),
+			remaining_sec = 0
+		WHERE timer_id = $1 AND session_id = $2 AND user_id = $3 AND is_paused
+		RETURNING ` + timerColumns
+
+	return repo.updateTimer(ctx, "resume", q, timerID, sessionID, uID, time.Now())
+}
+
+func (repo *CookingRecipeRepo) ExtendTimer(ctx context.Context, uID uint, sessionID int,
+	timerID int, timeSec int) (models.TimerRecipeModel, error) {
+	q := `UPDATE public.timers SET
+			remaining_sec = CASE WHEN is_paused THEN remaining_sec + $5::int ELSE remaining_sec END,
+			end_time = CASE WHEN is_paused THEN end_time
+				ELSE GREATEST(end_time, $4::timestamp) + make_interval(secs => $5::int) END,
+			is_expired = false
+		WHERE timer_id = $1 AND session_id = $2 AND user_id = $3
+		RETURNING ` + timerColumns
+
+	return repo.updateTimer(ctx, "extend", q, timerID, sessionID, uID, time.Now(), timeSec)
+}
+
+func (repo *CookingRecipeRepo) updateTimer(ctx context.Context, action string, q string, timerID int,
+	sessionID int, uID uint, args ...interface{}) (models.TimerRecipeModel, error) {
+	var timers []dao.TimerTable
+
+	err := repo.storage.Select(ctx, &timers, q, append([]interface{}{timerID, sessionID, uID}, args...)...)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to %s timer: %s for userId: %d, sessionId: %d, timerId: %d",
+			action, err.Error(), uID, sessionID, timerID))
+		return models.TimerRecipeModel{}, internalErrors.ErrFailedToUpdateTimer
+	}
+
+	if len(timers) == 0 {
+		logger.Error(ctx, fmt.Sprintf("timer to %s not found for userId: %d, sessionId: %d, timerId: %d",
+			action, uID, sessionID, timerID))
+		return models.TimerRecipeModel{}, internalErrors.ErrTimerNotFound
+	}
+
+	timersModel, err := dao.ConvertTimerToDAO(timers)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf(
+			"error converting timer table to model: %s with userId: %d", err.Error(), uID))
+		return models.TimerRecipeModel{}, internalErrors.ErrFailedToUpdateTimer
+	}
+
+	logger.Info(ctx, fmt.Sprintf("%s timer %d for userId: %d, sessionId: %d", action, timerID, uID, sessionID))
+
+	return timersModel[0], nil
+}
+
+func (repo *CookingRecipeRepo) GetTimersRecipe(ctx context.Context, uID uint,
+	sessionID int) ([]models.TimerRecipeModel, error) {
+	q := "SELECT " + timerColumns + " FROM public.timers WHERE user_id=$1 AND session_id=$2 ORDER BY timer_id"
 
 	var timers []dao.TimerTable
 
-	err := repo.storage.Select(ctx, &timers, q, uID)
+	err := repo.storage.Select(ctx, &timers, q, uID, sessionID)
 	if err != nil {
-		logger.Error(ctx, fmt.Sprintf("error getting timers: %s with userId: %d", err.Error(), uID))
+		logger.Error(ctx, fmt.Sprintf("error getting timers: %s with userId: %d, sessionId: %d",
+			err.Error(), uID, sessionID))
 		return []models.TimerRecipeModel{}, internalErrors.ErrFailedToGetTimers
 	}
 
@@ -657,36 +1280,90 @@ func (repo *CookingRecipeRepo) GetTimersRecipe(ctx context.Context, uID uint) ([
 		return []models.TimerRecipeModel{}, internalErrors.ErrFailedToGetTimers
 	}
 
-	logger.Info(ctx, fmt.Sprintf("get timers for userId: %d", uID))
+	logger.Info(ctx, fmt.Sprintf("get timers for userId: %d, sessionId: %d", uID, sessionID))
+
+	return timersModel, nil
+}
+
+func (repo *CookingRecipeRepo) GetTimersBySessionIDs(ctx context.Context,
+	sessionIDs []int) ([]models.TimerRecipeModel, error) {
+	q := "SELECT " + timerColumns + ` FROM public.timers 
+		WHERE session_id = ANY($1) AND NOT is_expired ORDER BY session_id, timer_id`
+
+	var timers []dao.TimerTable
+
+	err := repo.storage.Select(ctx, &timers, q, sessionIDs)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error getting timers: %s with sessionIds: %v", err.Error(), sessionIDs))
+		return nil, internalErrors.ErrFailedToGetTimers
+	}
+
+	timersModel, err := dao.ConvertTimerToDAO(timers)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf(
+			"error converting timers table to models: %s with sessionIds: %v", err.Error(), sessionIDs))
+		return nil, internalErrors.ErrFailedToGetTimers
+	}
+
+	return timersModel, nil
+}
+
+func (repo *CookingRecipeRepo) ExpireTimers(ctx context.Context, now time.Time) ([]models.TimerRecipeModel, error) {
+	q := `UPDATE public.timers SET is_expired = true 
+		WHERE end_time <= $1 AND NOT is_expired AND NOT is_paused
+		RETURNING ` + timerColumns
+
+	var timers []dao.TimerTable
+
+	err := repo.storage.Select(ctx, &timers, q, now)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error expiring timers: %s with now: %s", err.Error(), now))
+		return nil, internalErrors.ErrFailedToExpireTimers
+	}
+
+	timersModel, err := dao.ConvertTimerToDAO(timers)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("error converting expired timers to models: %s", err.Error()))
+		return nil, internalErrors.ErrFailedToExpireTimers
+	}
+
+	if len(timersModel) > 0 {
+		logger.Info(ctx, fmt.Sprintf("expired %d timers", len(timersModel)))
+	}
 
 	return timersModel, nil
 }
 
 func (repo *CookingRecipeRepo) GetCurrentRecipeStepByNum(
-	ctx context.Context, uID uint, stepNum int) (models.CurrentStepRecipeModel, error) {
+	ctx context.Context, uID uint, sessionID int, stepNum int) (models.CurrentStepRecipeModel, error) {
 	q := `SELECT step, step_num, ingredients, equipment, length FROM public.current_recipe_step
-		WHERE user_id=$1 AND step_num=$2`
+		WHERE user_id=$1 AND session_id=$2 AND step_num=$3`
 
 	recipeStepRow := make([]dao.CurrentRecipeStepTable, 0, 1)
 
-	err := repo.storage.Select(ctx, &recipeStepRow, q, uID, stepNum)
+	err := repo.storage.Select(ctx, &recipeStepRow, q, uID, sessionID, stepNum)
 
 	if err != nil {
 		logger.Error(ctx, fmt.Sprintf(
-			"error getting recipe step: %s with userId: %d, stepNum: %d",
+			"error getting recipe step: %s with userId: %d, sessionId: %d, stepNum: %d",
 			err.Error(),
 			uID,
+			sessionID,
 			stepNum),
 		)
 		return models.CurrentStepRecipeModel{}, internalErrors.ErrFailedToGetRecipeStep
 	}
 
 	if len(recipeStepRow) == 0 {
-		logger.Error(ctx, fmt.Sprintf("not found recipe step with userId: %d, stepNum: %d", uID, stepNum))
+		logger.Error(ctx, fmt.Sprintf("not found recipe step with userId: %d, sessionId: %d, stepNum: %d",
+			uID, sessionID, stepNum))
 		return models.CurrentStepRecipeModel{}, internalErrors.ErrFailedToGetRecipeStep
 	}
 
-	logger.Info(ctx, fmt.Sprintf("get recipe step for userId: %d, stepNum: %d", uID, stepNum))
+	logger.Info(ctx, fmt.Sprintf("get recipe step for userId: %d, sessionId: %d, stepNum: %d",
+		uID, sessionID, stepNum))
 
 	recipeStep := dao.ConvertDaoToCurrentStepRecipe(recipeStepRow[0])
 	return recipeStep, nil
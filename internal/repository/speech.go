@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/minio"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/speech"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const speechEntity = "speech"
+
+type SpeechRepo struct {
+	adapter     *minio.Adapter
+	synthesizer speech.Synthesizer
+	config      *config.Config
+}
+
+func NewSpeechRepo(adapter *minio.Adapter, synthesizer speech.Synthesizer, config *config.Config) *SpeechRepo {
+	return &SpeechRepo{adapter: adapter, synthesizer: synthesizer, config: config}
+}
+
+// speechObjectName строит имя объекта по хешу текста. Модель, голос и формат тоже входят в хеш,
+// чтобы после смены настроек TTS не отдавать старую озвучку.
+func (repo *SpeechRepo) speechObjectName(text string) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%s", repo.config.TTSProvider, repo.config.TTSModel,
+		repo.config.TTSVoice, repo.config.TTSFormat, text)))
+
+	return fmt.Sprintf("%s/%s.%s", speechEntity, hex.EncodeToString(hash[:]), repo.config.TTSFormat)
+}
+
+func (repo *SpeechRepo) GetCachedSpeech(ctx context.Context, text string) (models.SpeechModel, error) {
+	objectName := repo.speechObjectName(text)
+
+	reader, err := repo.adapter.Client.GetObject(ctx, repo.adapter.BucketName, objectName,
+		minio.NewEmptyObjectOptions())
+	if err != nil {
+		logger.Info(ctx, fmt.Sprintf("Error getting cached speech: %v for %s", err, objectName))
+		return models.SpeechModel{}, internalErrors.ErrSpeechNotCached
+	}
+
+	info, err := reader.Stat()
+	if err != nil {
+		reader.Close()
+		logger.Info(ctx, fmt.Sprintf("Speech is not cached: %v for %s", err, objectName))
+		return models.SpeechModel{}, internalErrors.ErrSpeechNotCached
+	}
+
+	return models.SpeechModel{
+		Audio:       reader,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		Name:        objectName,
+	}, nil
+}
+
+func (repo *SpeechRepo) CacheSpeech(ctx context.Context, text string, speechModel models.SpeechModel) error {
+	objectName := repo.speechObjectName(text)
+
+	_, err := repo.adapter.Client.PutObject(ctx, repo.adapter.BucketName, objectName, speechModel.Audio,
+		speechModel.Size, minio.NewPutObjectOptions(speechModel.ContentType))
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("Error caching speech: %v for %s", err, objectName))
+		return internalErrors.ErrFailedToCacheSpeech
+	}
+
+	return nil
+}
+
+func (repo *SpeechRepo) SynthesizeSpeech(ctx context.Context, text string) (models.SpeechModel, error) {
+	audio, err := repo.synthesizer.Synthesize(ctx, text)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to synthesize speech: %v with text: %s", err, text))
+		return models.SpeechModel{}, internalErrors.ErrFailedToSynthesizeSpeech
+	}
+
+	logger.Info(ctx, fmt.Sprintf("Speech synthesized, size: %d, text: %s", len(audio.Data), text))
+
+	return models.SpeechModel{
+		Audio:       bytes.NewReader(audio.Data),
+		Size:        int64(len(audio.Data)),
+		ContentType: audio.ContentType,
+		Name:        repo.speechObjectName(text),
+	}, nil
+}
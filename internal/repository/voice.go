@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/speech"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/internal/utils"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const promptVoice = `You are a helpful assistant, you need to recognize main idea of russian 
+	text and send me only a number.
+	You should send me 1 if main idea of text is next step or switch step.
+	You should send me 2 if main idea of text is previous step or switch step to previous.
+	You should send me 3 if main idea of text is end cooking.
+	You should send me 4 if main idea of text is end timer.
+	You should send me 5 if main idea of text is start timer.
+	You should send me 6 if main idea of text is get all timers.
+	You should send me 7 if main idea of text is go to the step with a specific number.
+	You should send me 8 if main idea of text is repeat or read the current step again.
+	You should send me 9 if text is a question about the current step: amount of ingredient,
+	temperature, cooking time or equipment.
+	You should send me 0 on other ideas.`
+
+type VoiceRepo struct {
+	config     *config.Config
+	llm        LLMProvider
+	keys       *KeyPool
+	recognizer speech.Recognizer
+}
+
+func NewVoiceRepo(config *config.Config, llmProvider LLMProvider, keyPool *KeyPool,
+	recognizer speech.Recognizer) *VoiceRepo {
+	return &VoiceRepo{config: config, llm: llmProvider, keys: keyPool, recognizer: recognizer}
+}
+
+func (repo *VoiceRepo) RecognizeIntent(ctx context.Context, text string) (int, error) {
+	// Голосовой команде есть куда отступить - на правила, поэтому долго ждать ключ в очереди нельзя.
+	key, err := repo.keys.AcquireWithin(ctx, repo.config.LLMVoiceKeyWaitTimeout)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to get llm key for voice intent: %v with text: %s", err, text))
+		return 0, internalErrors.ErrFailedToRecognizeVoice
+	}
+
+	// Без авторизации вызов пишется в журнал использования без пользователя.
+	userID, _ := utils.GetUserIDFromContext(ctx)
+
+	resp, err := repo.llm.Complete(ctx, llm.Request{
+		UseCase: llm.UseCaseVoice,
+		Prompt:  promptVoice,
+		Input:   text,
+		APIKey:  key.Value,
+		Params:  repo.config.LLMVoice,
+		UserID:  userID,
+	})
+
+	repo.keys.Release(ctx, key, err)
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to recognize voice intent: %v with text: %s", err, text))
+		return 0, internalErrors.ErrFailedToRecognizeVoice
+	}
+
+	intent, err := strconv.Atoi(strings.TrimSpace(utils.TrimLLMResponse(resp.Content)))
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("unexpected voice intent: %s with text: %s", resp.Content, text))
+		return 0, internalErrors.ErrFailedToRecognizeVoice
+	}
+
+	logger.Info(ctx, fmt.Sprintf("Text: %s, recognize like: %v", text, intent))
+
+	return intent, nil
+}
+
+func (repo *VoiceRepo) TranscribeAudio(ctx context.Context, audio models.VoiceAudioModel) (string, error) {
+	text, err := repo.recognizer.Recognize(ctx, speech.Audio{
+		Data:        audio.Audio,
+		ContentType: audio.ContentType,
+		Filename:    audio.Filename,
+	})
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to transcribe audio: %v, content type: %s, size: %d",
+			err, audio.ContentType, audio.Size))
+		return "", internalErrors.ErrFailedToTranscribeAudio
+	}
+
+	logger.Info(ctx, fmt.Sprintf("Audio %s of size %d transcribed like: %s", audio.ContentType, audio.Size, text))
+
+	return text, nil
+}
@@ -2,29 +2,63 @@ package usecase
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
+	"github.com/Olegsandrik/Exponenta/config"
 	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
 	"github.com/Olegsandrik/Exponenta/internal/utils"
+	"github.com/Olegsandrik/Exponenta/logger"
 )
 
 type GenerateRepository interface {
 	GetAllRecipes(ctx context.Context, num int, userID uint) ([]models.RecipeModel, error)
 	GetRecipeByID(ctx context.Context, recipeID int, userID uint) ([]models.RecipeModel, error)
-	CreateRecipe(ctx context.Context, products []string, query string, userID uint) ([]models.RecipeModel, error)
-	UpdateRecipe(ctx context.Context, query string, recipeID int, versionID int,
-		userID uint) ([]models.RecipeModel, error)
+	CreateRecipe(ctx context.Context, products []string, query string, fresh bool, userID uint,
+		jobID int, jobAttempt int) ([]models.RecipeModel, error)
+	CreateRecipeStream(ctx context.Context, products []string, query string, fresh bool, userID uint,
+		jobID int, onChunk func(chunk models.GenerationChunkModel) error) ([]models.RecipeModel, error)
+	UpdateRecipe(ctx context.Context, query string, recipeID int, versionID int, userID uint,
+		jobID int, jobAttempt int) ([]models.RecipeModel, error)
 	GetHistoryByID(ctx context.Context, recipeID int, userID uint) ([]models.RecipeModel, error)
+	GetRecipeVersion(ctx context.Context, recipeID int, versionID int, userID uint) ([]models.RecipeModel, error)
+	ForkRecipe(ctx context.Context, recipeID int, versionID int, userID uint) ([]models.RecipeModel, error)
 	SetNewMainVersion(ctx context.Context, recipeID int, versionID int, userID uint) error
+	GetKeysStats() models.LLMKeysStatsModel
+}
+
+type LLMUsageRepo interface {
+	GetGenerationUsage(ctx context.Context, userID uint) (models.GenerationUsageModel, error)
+}
+
+type GenerationJobNotifier interface {
+	Notify()
 }
 
 type GenerateUsecase struct {
 	GenRepository    GenerateRepository
 	RecipeRepository CookingRecipeRepo
+	JobRepository    GenerationJobRepo
+	UsageRepository  LLMUsageRepo
+	jobNotifier      GenerationJobNotifier
+	dailyQuota       int
+	monthlyQuota     int
 }
 
-func NewGenerateUsecase(generateRepository GenerateRepository, recipeRepo CookingRecipeRepo) *GenerateUsecase {
-	return &GenerateUsecase{GenRepository: generateRepository, RecipeRepository: recipeRepo}
+func NewGenerateUsecase(generateRepository GenerateRepository, recipeRepo CookingRecipeRepo,
+	jobRepo GenerationJobRepo, usageRepo LLMUsageRepo, jobNotifier GenerationJobNotifier,
+	cfg *config.Config) *GenerateUsecase {
+	return &GenerateUsecase{
+		GenRepository:    generateRepository,
+		RecipeRepository: recipeRepo,
+		JobRepository:    jobRepo,
+		UsageRepository:  usageRepo,
+		jobNotifier:      jobNotifier,
+		dailyQuota:       cfg.GenerationDailyQuota,
+		monthlyQuota:     cfg.GenerationMonthlyQuota,
+	}
 }
 
 func (a *GenerateUsecase) GetAllRecipes(ctx context.Context, num int) ([]dto.RecipeDto, error) {
@@ -62,43 +96,201 @@ func (a *GenerateUsecase) GetRecipeByID(ctx context.Context, recipeID int) (dto.
 	return recipeDTO[0], nil
 }
 
-func (a *GenerateUsecase) CreateRecipe(ctx context.Context, products []string, query string) (dto.RecipeDto, error) {
+func (a *GenerateUsecase) GetPrepByRecipeID(ctx context.Context, recipeID int) (dto.PrepChecklistDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 
 	if err != nil {
-		return dto.RecipeDto{}, err
+		return dto.PrepChecklistDto{}, err
 	}
 
-	recipeModel, err := a.GenRepository.CreateRecipe(ctx, products, query, uID)
+	recipeModel, err := a.GenRepository.GetRecipeByID(ctx, recipeID, uID)
+
 	if err != nil {
-		return dto.RecipeDto{}, err
+		return dto.PrepChecklistDto{}, err
 	}
 
-	recipeDTO := models.ConvertRecipeToDto(recipeModel)
+	return buildRecipePrep(recipeModel[0], recipeID, true)
+}
 
-	return recipeDTO[0], nil
+func (a *GenerateUsecase) CreateRecipeJob(ctx context.Context, products []string, query string,
+	fresh bool) (dto.GenerationJobDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+
+	if err != nil {
+		return dto.GenerationJobDto{}, err
+	}
+
+	return a.createJob(ctx, models.GenerationJobModel{
+		UserID:   uID,
+		Kind:     models.GenerationJobKindGeneration,
+		Query:    query,
+		Products: products,
+		Fresh:    fresh,
+	})
 }
 
-func (a *GenerateUsecase) UpdateRecipe(ctx context.Context, query string, recipeID int,
-	versionID int) (dto.RecipeDto, error) {
+// CreateRecipeStream генерирует рецепт в рамках запроса, отдавая ответ модели в onChunk по мере генерации.
+// Возвращает тот же проверенный и сохраненный рецепт, что и задача генерации. На время стрима квота
+// резервируется строкой задачи со статусом streaming, как и задачами в очереди.
+func (a *GenerateUsecase) CreateRecipeStream(ctx context.Context, products []string, query string, fresh bool,
+	onChunk func(chunk dto.GenerationChunkDto) error) (dto.RecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 
 	if err != nil {
 		return dto.RecipeDto{}, err
 	}
 
-	recipeModel, err := a.GenRepository.UpdateRecipe(ctx, query, recipeID, versionID, uID)
+	job, err := a.JobRepository.CreateJob(ctx, models.GenerationJobModel{
+		UserID:   uID,
+		Kind:     models.GenerationJobKindGeneration,
+		Status:   models.GenerationJobStatusStreaming,
+		Query:    query,
+		Products: products,
+		Fresh:    fresh,
+	}, a.quotaCheck(ctx, uID))
 
 	if err != nil {
 		return dto.RecipeDto{}, err
 	}
 
-	recipeDTO := models.ConvertRecipeToDto(recipeModel)
+	recipeModel, err := a.GenRepository.CreateRecipeStream(ctx, products, query, fresh, uID, job.ID,
+		func(chunk models.GenerationChunkModel) error {
+			return onChunk(models.ConvertGenerationChunkToDTO(chunk))
+		})
 
-	return recipeDTO[0], nil
+	// Статус пишем и после обрыва стрима клиентом, иначе резерв квоты провисит до истечения lease.
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), generationJobUpdateTimeout)
+	defer cancel()
+
+	if err != nil {
+		if failErr := a.JobRepository.FailJob(updateCtx, job.ID, err.Error()); failErr != nil {
+			logger.Error(ctx, fmt.Sprintf("failed to fail generation stream job: %d, err: %v", job.ID, failErr))
+		}
+		return dto.RecipeDto{}, err
+	}
+
+	recipeDTO := models.ConvertRecipeToDto(recipeModel)[0]
+
+	result, err := json.Marshal(recipeDTO)
+	if err == nil {
+		err = a.JobRepository.FinishJob(updateCtx, job.ID, recipeDTO.ID, recipeDTO.Version, result)
+	}
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to finish generation stream job: %d, err: %v", job.ID, err))
+	}
+
+	return recipeDTO, nil
+}
+
+func (a *GenerateUsecase) UpdateRecipeJob(ctx context.Context, query string, recipeID int,
+	versionID int) (dto.GenerationJobDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+
+	if err != nil {
+		return dto.GenerationJobDto{}, err
+	}
+
+	return a.createJob(ctx, models.GenerationJobModel{
+		UserID:    uID,
+		Kind:      models.GenerationJobKindModernization,
+		Query:     query,
+		RecipeID:  recipeID,
+		VersionID: versionID,
+	})
+}
+
+func (a *GenerateUsecase) createJob(ctx context.Context, job models.GenerationJobModel) (dto.GenerationJobDto, error) {
+	jobModel, err := a.JobRepository.CreateJob(ctx, job, a.quotaCheck(ctx, job.UserID))
+
+	if err != nil {
+		return dto.GenerationJobDto{}, err
+	}
+
+	a.jobNotifier.Notify()
+
+	return models.ConvertGenerationJobToDTO(jobModel), nil
+}
+
+func (a *GenerateUsecase) GetJob(ctx context.Context, jobID int) (dto.GenerationJobDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+
+	if err != nil {
+		return dto.GenerationJobDto{}, err
+	}
+
+	jobModel, err := a.JobRepository.GetJob(ctx, jobID, uID)
+
+	if err != nil {
+		return dto.GenerationJobDto{}, err
+	}
+
+	return models.ConvertGenerationJobToDTO(jobModel), nil
+}
+
+// GetKeysStats отдает состояние пула ключей. Вызывается только с внутреннего сервера, поэтому
+// пользователя не проверяет.
+func (a *GenerateUsecase) GetKeysStats(_ context.Context) (dto.LLMKeysStatsDto, error) {
+	return models.ConvertLLMKeysStatsToDTO(a.GenRepository.GetKeysStats()), nil
+}
+
+func (a *GenerateUsecase) GetQuota(ctx context.Context) (dto.GenerationQuotaDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+
+	if err != nil {
+		return dto.GenerationQuotaDto{}, err
+	}
+
+	usage, err := a.getUsage(ctx, uID)
+
+	if err != nil {
+		return dto.GenerationQuotaDto{}, err
+	}
+
+	return models.ConvertGenerationUsageToQuotaDTO(usage, a.dailyQuota, a.monthlyQuota), nil
+}
+
+// getUsage считает потраченные генерации вместе с задачами в очереди, иначе лимит можно обойти,
+// поставив много задач разом, пока ни одна из них еще не дошла до модели.
+func (a *GenerateUsecase) getUsage(ctx context.Context, uID uint) (models.GenerationUsageModel, error) {
+	usage, err := a.UsageRepository.GetGenerationUsage(ctx, uID)
+
+	if err != nil {
+		return models.GenerationUsageModel{}, err
+	}
+
+	activeJobs, err := a.JobRepository.CountActiveJobs(ctx, uID)
+
+	if err != nil {
+		return models.GenerationUsageModel{}, err
+	}
+
+	usage.DailyUsed += activeJobs
+	usage.MonthlyUsed += activeJobs
+
+	return usage, nil
 }
 
-func (a *GenerateUsecase) GetHistoryByID(ctx context.Context, recipeID int) ([]dto.RecipeDto, error) {
+// quotaCheck возвращает проверку дневного и месячного лимитов генераций для CreateJob или nil, если лимитов
+// нет. Лимит 0 означает отсутствие ограничения.
+func (a *GenerateUsecase) quotaCheck(ctx context.Context, uID uint) func(usage models.GenerationUsageModel) error {
+	if a.dailyQuota <= 0 && a.monthlyQuota <= 0 {
+		return nil
+	}
+
+	return func(usage models.GenerationUsageModel) error {
+		if (a.dailyQuota > 0 && usage.DailyUsed >= a.dailyQuota) ||
+			(a.monthlyQuota > 0 && usage.MonthlyUsed >= a.monthlyQuota) {
+			logger.Info(ctx, fmt.Sprintf("generation quota exceeded for userId: %d, daily: %d, monthly: %d",
+				uID, usage.DailyUsed, usage.MonthlyUsed))
+			return internalErrors.ErrGenerationQuotaExceeded
+		}
+
+		return nil
+	}
+}
+
+func (a *GenerateUsecase) GetHistoryByID(ctx context.Context, recipeID int) ([]dto.RecipeVersionNodeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 
 	if err != nil {
@@ -111,9 +303,56 @@ func (a *GenerateUsecase) GetHistoryByID(ctx context.Context, recipeID int) ([]d
 		return nil, err
 	}
 
+	return models.ConvertRecipeHistoryToTreeDTO(recipeModel), nil
+}
+
+func (a *GenerateUsecase) ForkRecipe(ctx context.Context, recipeID int, versionID int) (dto.RecipeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+
+	if err != nil {
+		return dto.RecipeDto{}, err
+	}
+
+	recipeModel, err := a.GenRepository.ForkRecipe(ctx, recipeID, versionID, uID)
+
+	if err != nil {
+		return dto.RecipeDto{}, err
+	}
+
 	recipeDTO := models.ConvertRecipeToDto(recipeModel)
 
-	return recipeDTO, nil
+	return recipeDTO[0], nil
+}
+
+func (a *GenerateUsecase) GetVersionsDiff(ctx context.Context, recipeID int, fromVersion int,
+	toVersion int) (dto.RecipeDiffDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+
+	if err != nil {
+		return dto.RecipeDiffDto{}, err
+	}
+
+	fromModel, err := a.GenRepository.GetRecipeVersion(ctx, recipeID, fromVersion, uID)
+
+	if err != nil {
+		return dto.RecipeDiffDto{}, err
+	}
+
+	toModel, err := a.GenRepository.GetRecipeVersion(ctx, recipeID, toVersion, uID)
+
+	if err != nil {
+		return dto.RecipeDiffDto{}, err
+	}
+
+	diff, err := utils.BuildRecipeDiff(fromModel[0], toModel[0])
+
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to diff recipe: %d versions: %d and %d, err: %v",
+			recipeID, fromVersion, toVersion, err))
+		return dto.RecipeDiffDto{}, internalErrors.ErrFailedToDiffVersions
+	}
+
+	return models.ConvertRecipeDiffToDTO(diff), nil
 }
 
 func (a *GenerateUsecase) SetNewMainVersion(ctx context.Context, recipeID int, versionID int) error {
@@ -126,19 +365,20 @@ func (a *GenerateUsecase) SetNewMainVersion(ctx context.Context, recipeID int, v
 	return a.GenRepository.SetNewMainVersion(ctx, recipeID, versionID, uID)
 }
 
-func (a *GenerateUsecase) StartCookingByRecipeID(ctx context.Context, recipeID int) (dto.CurrentStepRecipeDto, error) {
+func (a *GenerateUsecase) StartCookingByRecipeID(ctx context.Context, recipeID int,
+	servings int) (dto.CurrentStepRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
-	err = a.RecipeRepository.StartCooking(ctx, uID, recipeID, true)
+	sessionID, err := a.RecipeRepository.StartCooking(ctx, uID, recipeID, true, true, servings)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
-	currentRecipeStepModel, err := a.RecipeRepository.GetCurrentStep(ctx, uID)
+	currentRecipeStepModel, err := a.RecipeRepository.GetCurrentStep(ctx, uID, sessionID)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const generationJobUpdateTimeout = 5 * time.Second
+
+type GenerationJobRepo interface {
+	CreateJob(ctx context.Context, job models.GenerationJobModel,
+		checkQuota func(usage models.GenerationUsageModel) error) (models.GenerationJobModel, error)
+	GetJob(ctx context.Context, jobID int, userID uint) (models.GenerationJobModel, error)
+	CountActiveJobs(ctx context.Context, userID uint) (int, error)
+	ClaimJob(ctx context.Context, lease time.Duration, maxAttempts int) (models.GenerationJobModel, error)
+	FinishJob(ctx context.Context, jobID int, recipeID int, versionID int, result json.RawMessage) error
+	FailJob(ctx context.Context, jobID int, errMsg string) error
+	ReleaseJob(ctx context.Context, jobID int, errMsg string, refundAttempt bool) error
+	FailStaleJobs(ctx context.Context, lease time.Duration, maxAttempts int) error
+}
+
+// GenerationWorkerPool выполняет задачи генерации и модернизации из Postgres. Задачи переживают рестарт:
+// ожидающие подхватываются при старте, а прерванные забираются повторно после истечения lease.
+type GenerationWorkerPool struct {
+	jobRepo     GenerationJobRepo
+	genRepo     GenerateRepository
+	interval    time.Duration
+	lease       time.Duration
+	maxAttempts int
+	slots       chan struct{}
+	wake        chan struct{}
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+}
+
+func NewGenerationWorkerPool(jobRepo GenerationJobRepo, genRepo GenerateRepository,
+	cfg *config.Config) *GenerationWorkerPool {
+	workers := cfg.GenerationWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &GenerationWorkerPool{
+		jobRepo:     jobRepo,
+		genRepo:     genRepo,
+		interval:    cfg.GenerationJobPollInterval,
+		lease:       cfg.GenerationJobLease,
+		maxAttempts: cfg.GenerationJobMaxAttempts,
+		slots:       make(chan struct{}, workers),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+func (p *GenerationWorkerPool) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		p.dispatch(ctx)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-p.wake:
+			}
+			p.dispatch(ctx)
+		}
+	}()
+}
+
+func (p *GenerationWorkerPool) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+	return nil
+}
+
+// Notify будит пул, чтобы новая задача не ждала следующего опроса.
+func (p *GenerationWorkerPool) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (p *GenerationWorkerPool) dispatch(ctx context.Context) {
+	if err := p.jobRepo.FailStaleJobs(ctx, p.lease, p.maxAttempts); err != nil {
+		logger.Error(ctx, fmt.Sprintf("generation workers failed to fail stale jobs: %v", err))
+	}
+
+	for ctx.Err() == nil {
+		select {
+		case p.slots <- struct{}{}:
+		default:
+			return
+		}
+
+		job, err := p.jobRepo.ClaimJob(ctx, p.lease, p.maxAttempts)
+		if err != nil {
+			<-p.slots
+			if !errors.Is(err, internalErrors.ErrNoGenerationJobs) {
+				logger.Error(ctx, fmt.Sprintf("generation workers failed to claim job: %v", err))
+			}
+			return
+		}
+
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			defer func() { <-p.slots }()
+
+			if p.run(ctx, job) {
+				p.Notify()
+			}
+		}()
+	}
+}
+
+// run выполняет задачу и возвращает true, если задача завершена и слот можно сразу отдать следующей.
+func (p *GenerationWorkerPool) run(ctx context.Context, job models.GenerationJobModel) bool {
+	var recipeModels []models.RecipeModel
+	var err error
+
+	switch job.Kind {
+	case models.GenerationJobKindGeneration:
+		recipeModels, err = p.genRepo.CreateRecipe(ctx, job.Products, job.Query, job.Fresh, job.UserID,
+			job.ID, job.Attempts)
+	case models.GenerationJobKindModernization:
+		recipeModels, err = p.genRepo.UpdateRecipe(ctx, job.Query, job.RecipeID, job.VersionID, job.UserID,
+			job.ID, job.Attempts)
+	default:
+		err = fmt.Errorf("unknown generation job kind: %s", job.Kind)
+	}
+
+	// Статус пишем даже после отмены ctx, иначе готовый рецепт сгенерируется повторно после рестарта.
+	updateCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), generationJobUpdateTimeout)
+	defer cancel()
+
+	if err != nil {
+		return p.handleError(ctx, updateCtx, job, err)
+	}
+
+	recipeDTO := models.ConvertRecipeToDto(recipeModels)[0]
+
+	result, err := json.Marshal(recipeDTO)
+	if err != nil {
+		return p.handleError(ctx, updateCtx, job, err)
+	}
+
+	if err = p.jobRepo.FinishJob(updateCtx, job.ID, recipeDTO.ID, recipeDTO.Version, result); err != nil {
+		logger.Error(ctx, fmt.Sprintf("generation workers failed to finish job: %d, err: %v", job.ID, err))
+	}
+
+	return true
+}
+
+func (p *GenerationWorkerPool) handleError(ctx context.Context, updateCtx context.Context,
+	job models.GenerationJobModel, jobErr error) bool {
+	switch {
+	case ctx.Err() != nil, errors.Is(jobErr, internalErrors.ErrAllKeysAreUsing):
+		if err := p.jobRepo.ReleaseJob(updateCtx, job.ID, "", true); err != nil {
+			logger.Error(ctx, fmt.Sprintf("generation workers failed to release job: %d, err: %v", job.ID, err))
+		}
+		return false
+	case job.Attempts < p.maxAttempts:
+		logger.Info(ctx, fmt.Sprintf("generation job: %d failed, attempt: %d, err: %v", job.ID, job.Attempts, jobErr))
+		if err := p.jobRepo.ReleaseJob(updateCtx, job.ID, jobErr.Error(), false); err != nil {
+			logger.Error(ctx, fmt.Sprintf("generation workers failed to release job: %d, err: %v", job.ID, err))
+		}
+		return false
+	default:
+		logger.Error(ctx, fmt.Sprintf("generation job: %d failed, err: %v", job.ID, jobErr))
+		if err := p.jobRepo.FailJob(updateCtx, job.ID, jobErr.Error()); err != nil {
+			logger.Error(ctx, fmt.Sprintf("generation workers failed to fail job: %d, err: %v", job.ID, err))
+		}
+		return true
+	}
+}
@@ -0,0 +1,141 @@
+package models
+
+import "github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+
+const (
+	TextDiffEqual  = "equal"
+	TextDiffInsert = "insert"
+	TextDiffDelete = "delete"
+)
+
+type IntChangeModel struct {
+	From int
+	To   int
+}
+
+type StringChangeModel struct {
+	From string
+	To   string
+}
+
+type ListChangeModel struct {
+	Added   []string
+	Removed []string
+}
+
+type IngredientDiffModel struct {
+	Name       string
+	FromAmount float64
+	FromUnit   string
+	ToAmount   float64
+	ToUnit     string
+}
+
+type TextDiffOpModel struct {
+	Op   string
+	Text string
+}
+
+// StepDiffModel описывает вставленный (только To), удаленный (только From) или измененный шаг.
+type StepDiffModel struct {
+	FromNumber int
+	ToNumber   int
+	FromStep   string
+	ToStep     string
+	TextDiff   []TextDiffOpModel
+}
+
+type RecipeDiffModel struct {
+	RecipeID           int
+	FromVersion        int
+	ToVersion          int
+	Name               *StringChangeModel
+	Servings           *IntChangeModel
+	ReadyInMinutes     *IntChangeModel
+	Diets              ListChangeModel
+	DishTypes          ListChangeModel
+	AddedIngredients   []IngredientDiffModel
+	RemovedIngredients []IngredientDiffModel
+	ChangedIngredients []IngredientDiffModel
+	InsertedSteps      []StepDiffModel
+	RemovedSteps       []StepDiffModel
+	EditedSteps        []StepDiffModel
+}
+
+func convertListChangeToDTO(change ListChangeModel) dto.ListChangeDto {
+	return dto.ListChangeDto{
+		Added:   append(make([]string, 0, len(change.Added)), change.Added...),
+		Removed: append(make([]string, 0, len(change.Removed)), change.Removed...),
+	}
+}
+
+func convertIngredientDiffsToDTO(ingredients []IngredientDiffModel) []dto.IngredientDiffDto {
+	ingredientsDTO := make([]dto.IngredientDiffDto, 0, len(ingredients))
+
+	for _, ingredient := range ingredients {
+		ingredientsDTO = append(ingredientsDTO, dto.IngredientDiffDto{
+			Name:       ingredient.Name,
+			FromAmount: ingredient.FromAmount,
+			FromUnit:   ingredient.FromUnit,
+			ToAmount:   ingredient.ToAmount,
+			ToUnit:     ingredient.ToUnit,
+		})
+	}
+
+	return ingredientsDTO
+}
+
+func convertStepDiffsToDTO(steps []StepDiffModel) []dto.StepDiffDto {
+	stepsDTO := make([]dto.StepDiffDto, 0, len(steps))
+
+	for _, step := range steps {
+		stepDTO := dto.StepDiffDto{
+			FromNumber: step.FromNumber,
+			ToNumber:   step.ToNumber,
+			FromStep:   step.FromStep,
+			ToStep:     step.ToStep,
+		}
+
+		for _, op := range step.TextDiff {
+			stepDTO.TextDiff = append(stepDTO.TextDiff, dto.TextDiffOpDto{Op: op.Op, Text: op.Text})
+		}
+
+		stepsDTO = append(stepsDTO, stepDTO)
+	}
+
+	return stepsDTO
+}
+
+func ConvertRecipeDiffToDTO(diff RecipeDiffModel) dto.RecipeDiffDto {
+	diffDTO := dto.RecipeDiffDto{
+		RecipeID:    diff.RecipeID,
+		FromVersion: diff.FromVersion,
+		ToVersion:   diff.ToVersion,
+		Diets:       convertListChangeToDTO(diff.Diets),
+		DishTypes:   convertListChangeToDTO(diff.DishTypes),
+		Ingredients: dto.IngredientsDiffDto{
+			Added:   convertIngredientDiffsToDTO(diff.AddedIngredients),
+			Removed: convertIngredientDiffsToDTO(diff.RemovedIngredients),
+			Changed: convertIngredientDiffsToDTO(diff.ChangedIngredients),
+		},
+		Steps: dto.StepsDiffDto{
+			Inserted: convertStepDiffsToDTO(diff.InsertedSteps),
+			Removed:  convertStepDiffsToDTO(diff.RemovedSteps),
+			Edited:   convertStepDiffsToDTO(diff.EditedSteps),
+		},
+	}
+
+	if diff.Name != nil {
+		diffDTO.Name = &dto.StringChangeDto{From: diff.Name.From, To: diff.Name.To}
+	}
+
+	if diff.Servings != nil {
+		diffDTO.Servings = &dto.IntChangeDto{From: diff.Servings.From, To: diff.Servings.To}
+	}
+
+	if diff.ReadyInMinutes != nil {
+		diffDTO.ReadyInMinutes = &dto.IntChangeDto{From: diff.ReadyInMinutes.From, To: diff.ReadyInMinutes.To}
+	}
+
+	return diffDTO
+}
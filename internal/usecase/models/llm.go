@@ -0,0 +1,111 @@
+package models
+
+import (
+	"time"
+
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+)
+
+type LLMKeyStatsModel struct {
+	Key                string
+	Healthy            bool
+	InFlight           int
+	RequestsLastMinute int
+	TotalRequests      int64
+	TotalErrors        int64
+	RateLimited        int64
+	CooldownUntil      time.Time
+	LastError          string
+	LastErrorAt        time.Time
+}
+
+type LLMKeysStatsModel struct {
+	Waiting int
+	Keys    []LLMKeyStatsModel
+}
+
+func ConvertLLMKeysStatsToDTO(stats LLMKeysStatsModel) dto.LLMKeysStatsDto {
+	keys := make([]dto.LLMKeyStatsDto, 0, len(stats.Keys))
+
+	for _, key := range stats.Keys {
+		keyDTO := dto.LLMKeyStatsDto{
+			Key:                key.Key,
+			Healthy:            key.Healthy,
+			InFlight:           key.InFlight,
+			RequestsLastMinute: key.RequestsLastMinute,
+			TotalRequests:      key.TotalRequests,
+			TotalErrors:        key.TotalErrors,
+			RateLimited:        key.RateLimited,
+			LastError:          key.LastError,
+		}
+
+		if !key.CooldownUntil.IsZero() {
+			keyDTO.CooldownUntil = &key.CooldownUntil
+		}
+
+		if !key.LastErrorAt.IsZero() {
+			keyDTO.LastErrorAt = &key.LastErrorAt
+		}
+
+		keys = append(keys, keyDTO)
+	}
+
+	return dto.LLMKeysStatsDto{
+		Waiting: stats.Waiting,
+		Keys:    keys,
+	}
+}
+
+const (
+	LLMUsageOutcomeSuccess  = "success"
+	LLMUsageOutcomeError    = "error"
+	LLMUsageOutcomeCanceled = "canceled"
+)
+
+// LLMUsageModel - запись журнала использования модели, одна на каждый вызов. ProducedContent - модель
+// успела прислать хотя бы часть ответа, даже если вызов потом оборвался.
+type LLMUsageModel struct {
+	UserID           uint
+	UseCase          string
+	Model            string
+	Attempt          int
+	JobID            int
+	JobAttempt       int
+	PromptTokens     int
+	CompletionTokens int
+	Latency          time.Duration
+	Outcome          string
+	Error            string
+	ProducedContent  bool
+}
+
+// GenerationUsageModel - сколько генераций пользователь потратил за текущие сутки и месяц.
+type GenerationUsageModel struct {
+	DailyUsed      int
+	MonthlyUsed    int
+	DailyResetAt   time.Time
+	MonthlyResetAt time.Time
+}
+
+func convertQuotaPeriodToDTO(limit int, used int, resetsAt time.Time) dto.GenerationQuotaPeriodDto {
+	period := dto.GenerationQuotaPeriodDto{
+		Limit:     limit,
+		Used:      used,
+		Unlimited: limit <= 0,
+		ResetsAt:  resetsAt,
+	}
+
+	if !period.Unlimited {
+		period.Remaining = max(limit-used, 0)
+	}
+
+	return period
+}
+
+func ConvertGenerationUsageToQuotaDTO(usage GenerationUsageModel, dailyLimit int,
+	monthlyLimit int) dto.GenerationQuotaDto {
+	return dto.GenerationQuotaDto{
+		Daily:   convertQuotaPeriodToDTO(dailyLimit, usage.DailyUsed, usage.DailyResetAt),
+		Monthly: convertQuotaPeriodToDTO(monthlyLimit, usage.MonthlyUsed, usage.MonthlyResetAt),
+	}
+}
@@ -0,0 +1,65 @@
+package models
+
+import "github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+
+const (
+	PrepKindIngredient = "ingredient"
+	PrepKindEquipment  = "equipment"
+)
+
+type PrepItemModel struct {
+	ID      string
+	Kind    string
+	Name    string
+	Image   string
+	Amount  float64
+	Unit    string
+	Steps   []int
+	Checked bool
+}
+
+type PrepChecklistModel struct {
+	SessionID   int
+	RecipeID    int
+	Name        string
+	IsGenerated bool
+	Items       []PrepItemModel
+}
+
+func ConvertPrepItemToDTO(item PrepItemModel) dto.PrepItemDto {
+	return dto.PrepItemDto{
+		ID:      item.ID,
+		Name:    item.Name,
+		Image:   item.Image,
+		Amount:  item.Amount,
+		Unit:    item.Unit,
+		Steps:   item.Steps,
+		Checked: item.Checked,
+	}
+}
+
+func ConvertPrepChecklistToDTO(checklist PrepChecklistModel) dto.PrepChecklistDto {
+	checklistDto := dto.PrepChecklistDto{
+		SessionID:   checklist.SessionID,
+		RecipeID:    checklist.RecipeID,
+		Name:        checklist.Name,
+		IsGenerated: checklist.IsGenerated,
+		Ingredients: make([]dto.PrepItemDto, 0),
+		Equipment:   make([]dto.PrepItemDto, 0),
+		Total:       len(checklist.Items),
+	}
+
+	for _, item := range checklist.Items {
+		if item.Checked {
+			checklistDto.Checked++
+		}
+
+		if item.Kind == PrepKindIngredient {
+			checklistDto.Ingredients = append(checklistDto.Ingredients, ConvertPrepItemToDTO(item))
+		} else {
+			checklistDto.Equipment = append(checklistDto.Equipment, ConvertPrepItemToDTO(item))
+		}
+	}
+
+	return checklistDto
+}
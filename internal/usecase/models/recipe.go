@@ -2,6 +2,7 @@ package models
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
@@ -24,14 +25,71 @@ type RecipeModel struct {
 	UserIngredients string
 	IsGenerated     bool
 	CreatedAt       time.Time
+	SessionID       int
+	Status          string
+	Rating          int
+	Notes           string
+	Photo           string
+	ParentVersion   int
+}
+
+const (
+	GenerationJobKindGeneration    = "generation"
+	GenerationJobKindModernization = "modernization"
+
+	GenerationJobStatusPending = "pending"
+	GenerationJobStatusRunning = "running"
+	GenerationJobStatusDone    = "done"
+	GenerationJobStatusFailed  = "failed"
+	// Задачу со стримом выполняет сам запрос, воркеры ее не берут: строка резервирует квоту на время стрима.
+	GenerationJobStatusStreaming = "streaming"
+)
+
+type GenerationJobModel struct {
+	ID         int
+	UserID     uint
+	Kind       string
+	Status     string
+	Query      string
+	Products   []string
+	Fresh      bool
+	RecipeID   int
+	VersionID  int
+	Result     []byte
+	Error      string
+	Attempts   int
+	CreatedAt  time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// GenerationChunkModel - кусок ответа модели при потоковой генерации. Attempt растет, когда модель
+// переписывает рецепт после проверки схемы, и накопленный текст нужно начинать заново.
+type GenerationChunkModel struct {
+	Attempt int
+	Delta   string
+}
+
+type CookingFeedbackModel struct {
+	Rating int
+	Notes  string
+	Photo  string
 }
 
 type CurrentRecipeModel struct {
+	SessionID   int
+	IsDefault   bool
 	ID          int
 	Name        string
 	TotalSteps  int
 	CurrentStep CurrentStepRecipeModel
 	IsGenerated bool
+	Servings    int
+	Ingredients json.RawMessage
+	JoinCode    string
+	IsGuest     bool
+	AutoTimers  bool
+	DoneSteps   []int
 }
 
 type CurrentStepRecipeModel struct {
@@ -43,9 +101,14 @@ type CurrentStepRecipeModel struct {
 }
 
 type TimerRecipeModel struct {
-	Length  json.RawMessage
-	Step    string
-	StepNum int
+	ID        int
+	SessionID int
+	Label     string
+	IsPaused  bool
+	IsAuto    bool
+	Length    json.RawMessage
+	Step      string
+	StepNum   int
 }
 
 type Collection struct {
@@ -67,22 +130,68 @@ func ConvertCollectionToDTO(collection []Collection) []dto.Collection {
 func ConvertTimersToDTO(steps []TimerRecipeModel) []dto.TimerRecipeDto {
 	StepItems := make([]dto.TimerRecipeDto, len(steps))
 	for i, step := range steps {
-		StepItems[i] = dto.TimerRecipeDto{
-			Length:  step.Length,
-			Step:    step.Step,
-			StepNum: step.StepNum,
-		}
+		StepItems[i] = ConvertTimerToDTO(step)
 	}
 	return StepItems
 }
 
+func ConvertTimerToDTO(timer TimerRecipeModel) dto.TimerRecipeDto {
+	return dto.TimerRecipeDto{
+		ID:       timer.ID,
+		Label:    timer.Label,
+		IsPaused: timer.IsPaused,
+		IsAuto:   timer.IsAuto,
+		Length:   timer.Length,
+		Step:     timer.Step,
+		StepNum:  timer.StepNum,
+	}
+}
+
+func ConvertSecondsToLength(seconds int) json.RawMessage {
+	return json.RawMessage(fmt.Sprintf(`{"number":%d,"unit":"seconds"}`, seconds))
+}
+
+func ConvertCurrentRecipesToDTO(recipes []CurrentRecipeModel) []dto.CurrentRecipeDto {
+	recipeItems := make([]dto.CurrentRecipeDto, len(recipes))
+	for i, recipe := range recipes {
+		recipeItems[i] = ConvertCurrentRecipeToDTO(recipe)
+	}
+	return recipeItems
+}
+
 func ConvertCurrentRecipeToDTO(recipe CurrentRecipeModel) dto.CurrentRecipeDto {
 	return dto.CurrentRecipeDto{
+		SessionID:   recipe.SessionID,
+		IsDefault:   recipe.IsDefault,
 		ID:          recipe.ID,
 		Name:        recipe.Name,
 		TotalSteps:  recipe.TotalSteps,
 		IsGenerated: recipe.IsGenerated,
+		ServingsNum: recipe.Servings,
+		Ingredients: recipe.Ingredients,
+		JoinCode:    recipe.JoinCode,
+		IsGuest:     recipe.IsGuest,
+		AutoTimers:  recipe.AutoTimers,
 		CurrentStep: ConvertCurrentStepToDTO(recipe.CurrentStep),
+		Progress:    ConvertProgressToDTO(recipe.DoneSteps, recipe.TotalSteps),
+	}
+}
+
+func ConvertProgressToDTO(doneSteps []int, totalSteps int) *dto.CookingProgressDto {
+	if doneSteps == nil {
+		return nil
+	}
+
+	percent := 0
+	if totalSteps > 0 {
+		percent = len(doneSteps) * 100 / totalSteps
+	}
+
+	return &dto.CookingProgressDto{
+		Done:      len(doneSteps),
+		Total:     totalSteps,
+		Percent:   percent,
+		DoneSteps: doneSteps,
 	}
 }
 
@@ -149,6 +258,11 @@ func ConvertRecipeToDto(rm []RecipeModel) []dto.RecipeDto {
 			Query:           r.Query,
 			UserIngredients: json.RawMessage(r.UserIngredients),
 			IsGenerated:     r.IsGenerated,
+			SessionID:       r.SessionID,
+			Status:          r.Status,
+			Rating:          r.Rating,
+			Notes:           r.Notes,
+			Photo:           r.Photo,
 		}
 		
 		if !r.CreatedAt.IsZero() {
@@ -159,3 +273,83 @@ func ConvertRecipeToDto(rm []RecipeModel) []dto.RecipeDto {
 	}
 	return RecipeItems
 }
+
+// ConvertRecipeHistoryToTreeDTO собирает версии в дерево по родительским версиям. Версия без родителя
+// или с недостижимым родителем становится корнем, поэтому версии из истории не теряются.
+func ConvertRecipeHistoryToTreeDTO(rm []RecipeModel) []dto.RecipeVersionNodeDto {
+	recipesDTO := ConvertRecipeToDto(rm)
+
+	byVersion := make(map[int]int, len(rm))
+	for i, r := range rm {
+		byVersion[r.Version] = i
+	}
+
+	children := make(map[int][]int, len(rm))
+	roots := make([]int, 0, 1)
+
+	for i, r := range rm {
+		if _, ok := byVersion[r.ParentVersion]; ok && r.ParentVersion < r.Version {
+			children[r.ParentVersion] = append(children[r.ParentVersion], i)
+		} else {
+			roots = append(roots, i)
+		}
+	}
+
+	var buildNode func(i int) dto.RecipeVersionNodeDto
+	buildNode = func(i int) dto.RecipeVersionNodeDto {
+		node := dto.RecipeVersionNodeDto{
+			RecipeDto:     recipesDTO[i],
+			ParentVersion: rm[i].ParentVersion,
+			Children:      make([]dto.RecipeVersionNodeDto, 0, len(children[rm[i].Version])),
+		}
+
+		for _, child := range children[rm[i].Version] {
+			node.Children = append(node.Children, buildNode(child))
+		}
+
+		return node
+	}
+
+	tree := make([]dto.RecipeVersionNodeDto, 0, len(roots))
+	for _, root := range roots {
+		tree = append(tree, buildNode(root))
+	}
+
+	return tree
+}
+
+func ConvertGenerationJobToDTO(job GenerationJobModel) dto.GenerationJobDto {
+	jobDTO := dto.GenerationJobDto{
+		ID:        job.ID,
+		Kind:      job.Kind,
+		Status:    job.Status,
+		Query:     job.Query,
+		Products:  job.Products,
+		Fresh:     job.Fresh,
+		RecipeID:  job.RecipeID,
+		VersionID: job.VersionID,
+		Error:     job.Error,
+		CreatedAt: job.CreatedAt,
+	}
+
+	if len(job.Result) > 0 {
+		jobDTO.Result = json.RawMessage(job.Result)
+	}
+
+	if !job.StartedAt.IsZero() {
+		jobDTO.StartedAt = &job.StartedAt
+	}
+
+	if !job.FinishedAt.IsZero() {
+		jobDTO.FinishedAt = &job.FinishedAt
+	}
+
+	return jobDTO
+}
+
+func ConvertGenerationChunkToDTO(chunk GenerationChunkModel) dto.GenerationChunkDto {
+	return dto.GenerationChunkDto{
+		Attempt: chunk.Attempt,
+		Delta:   chunk.Delta,
+	}
+}
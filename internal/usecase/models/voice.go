@@ -0,0 +1,59 @@
+package models
+
+import (
+	"io"
+
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+)
+
+type VoiceIntentModel struct {
+	Intent     int
+	Confidence float64
+	Source     string
+}
+
+type VoiceSlotsModel struct {
+	DurationSec int
+	StepNum     int
+	Label       string
+}
+
+type VoiceAudioModel struct {
+	Audio       io.Reader
+	Size        int64
+	ContentType string
+	Filename    string
+}
+
+type SpeechModel struct {
+	Audio       io.ReadSeeker
+	Size        int64
+	ContentType string
+	Name        string
+}
+
+func ConvertSpeechToDTO(speech SpeechModel) dto.SpeechDto {
+	return dto.SpeechDto{
+		Audio:       speech.Audio,
+		Size:        speech.Size,
+		ContentType: speech.ContentType,
+		Name:        speech.Name,
+	}
+}
+
+func ConvertDTOToVoiceAudio(audio dto.VoiceAudioDto) VoiceAudioModel {
+	return VoiceAudioModel{
+		Audio:       audio.Audio,
+		Size:        audio.Size,
+		ContentType: audio.ContentType,
+		Filename:    audio.Filename,
+	}
+}
+
+func ConvertVoiceSlotsToDTO(slots VoiceSlotsModel) dto.VoiceSlotsDto {
+	return dto.VoiceSlotsDto{
+		DurationSec: slots.DurationSec,
+		StepNum:     slots.StepNum,
+		Label:       slots.Label,
+	}
+}
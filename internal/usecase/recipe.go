@@ -2,8 +2,16 @@ package usecase
 
 import (
 	"context"
+	"errors"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	"github.com/microcosm-cc/bluemonday"
+
+	"github.com/Olegsandrik/Exponenta/config"
 	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
 	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
 	"github.com/Olegsandrik/Exponenta/internal/utils"
 )
@@ -11,33 +19,99 @@ import (
 type CookingRecipeRepo interface {
 	GetAllRecipe(ctx context.Context, num int) ([]models.RecipeModel, error)
 	GetRecipeByID(ctx context.Context, id int) ([]models.RecipeModel, error)
-	EndCooking(ctx context.Context, uID uint) (int, bool, error)
-	StartCooking(ctx context.Context, uID uint, recipeID int, isGenerated bool) error
-	GetCurrentRecipe(ctx context.Context, uID uint) (models.CurrentRecipeModel, error)
-	GetNextRecipeStep(ctx context.Context, uID uint) (models.CurrentStepRecipeModel, error)
-	GetPrevRecipeStep(ctx context.Context, uID uint) (models.CurrentStepRecipeModel, error)
-	GetCurrentStep(ctx context.Context, uID uint) (models.CurrentStepRecipeModel, error)
-	AddTimerToRecipe(ctx context.Context, uID uint, StepNum int, timeSec int, description string) error
-	DeleteTimerFromRecipe(ctx context.Context, uID uint, StepNum int) error
-	GetTimersRecipe(ctx context.Context, uID uint) ([]models.TimerRecipeModel, error)
-	GetCurrentRecipeStepByNum(ctx context.Context, uID uint, stepNum int) (
+	GetDefaultSessionID(ctx context.Context, uID uint) (int, error)
+	GetSessionOwnerID(ctx context.Context, uID uint, sessionID int) (uint, error)
+	SetJoinCode(ctx context.Context, uID uint, sessionID int, code string, ttl time.Duration) error
+	JoinSession(ctx context.Context, uID uint, code string) (int, error)
+	LeaveSession(ctx context.Context, uID uint, sessionID int) error
+	GetSessionMembers(ctx context.Context, sessionID int) ([]uint, error)
+	TouchSession(ctx context.Context, sessionID int) error
+	GetCookingSessions(ctx context.Context, uID uint) ([]models.CurrentRecipeModel, error)
+	EndCooking(ctx context.Context, uID uint, sessionID int) (int, bool, error)
+	StartCooking(ctx context.Context, uID uint, recipeID int, isGenerated bool, isDefault bool,
+		servings int) (int, error)
+	GetCurrentRecipe(ctx context.Context, uID uint, sessionID int) (models.CurrentRecipeModel, error)
+	GetNextRecipeStep(ctx context.Context, uID uint, sessionID int) (models.CurrentStepRecipeModel, error)
+	GetPrevRecipeStep(ctx context.Context, uID uint, sessionID int) (models.CurrentStepRecipeModel, error)
+	GetCurrentStep(ctx context.Context, uID uint, sessionID int) (models.CurrentStepRecipeModel, error)
+	GoToRecipeStep(ctx context.Context, uID uint, sessionID int, stepNum int) (models.CurrentStepRecipeModel, error)
+	SetStepDone(ctx context.Context, uID uint, sessionID int, stepNum int, isDone bool) error
+	GetDoneSteps(ctx context.Context, uID uint, sessionID int) ([]int, error)
+	GetRecipeSteps(ctx context.Context, uID uint, sessionID int) ([]models.CurrentStepRecipeModel, error)
+	GetCheckedPrepItems(ctx context.Context, sessionID int) ([]string, error)
+	SetPrepItemChecked(ctx context.Context, sessionID int, itemID string, isChecked bool) error
+	SetAutoTimers(ctx context.Context, uID uint, sessionID int, enabled bool) error
+	AddAutoTimer(ctx context.Context, uID uint, sessionID int, stepNum int, timeSec int,
+		description string) (models.TimerRecipeModel, bool, error)
+	AddTimerToRecipe(ctx context.Context, uID uint, sessionID int, StepNum int, timeSec int, description string,
+		label string) (models.TimerRecipeModel, error)
+	DeleteTimerFromRecipe(ctx context.Context, uID uint, sessionID int, StepNum int) error
+	DeleteTimerByID(ctx context.Context, uID uint, sessionID int, timerID int) error
+	PauseTimer(ctx context.Context, uID uint, sessionID int, timerID int) (models.TimerRecipeModel, error)
+	ResumeTimer(ctx context.Context, uID uint, sessionID int, timerID int) (models.TimerRecipeModel, error)
+	ExtendTimer(ctx context.Context, uID uint, sessionID int, timerID int, timeSec int) (models.TimerRecipeModel, error)
+	GetTimersRecipe(ctx context.Context, uID uint, sessionID int) ([]models.TimerRecipeModel, error)
+	GetCurrentRecipeStepByNum(ctx context.Context, uID uint, sessionID int, stepNum int) (
 		models.CurrentStepRecipeModel, error,
 	)
-	AddRecipeToHistory(ctx context.Context, userID uint, recipeID int, isGenerated bool) error
+	AddRecipeToHistory(ctx context.Context, userID uint, recipeID int, isGenerated bool, sessionID int,
+		feedback models.CookingFeedbackModel) error
+}
+
+type JoinAttemptRepo interface {
+	RegisterJoinAttempt(ctx context.Context, uID uint) error
+}
+
+type CookingPhotoRepo interface {
+	UploadImage(ctx context.Context, filename string, entity string, image models.ImageModel) error
+	DeleteImage(ctx context.Context, filename string, entity string) error
+}
+
+const (
+	joinCodeLength      = 6
+	joinCodeAttempts    = 3
+	cookingPhotoEntity  = "history"
+	maxCookingNotesSize = 2000
+	minCookingRating    = 1
+	maxCookingRating    = 5
+)
+
+type CookingEventsBroker interface {
+	Publish(topic int, event dto.CookingEventDto)
+	Subscribe(topic int) (<-chan dto.CookingEventDto, func())
+	Topics() []int
 }
 
 type CookingRecipeUsecase struct {
 	repo                CookingRecipeRepo
 	favoriteRecipesRepo FavoriteRecipesRepo
+	eventsBroker        CookingEventsBroker
+	photoRepo           CookingPhotoRepo
+	joinAttemptRepo     JoinAttemptRepo
+	joinCodeTTL         time.Duration
 }
 
-func NewCookingRecipeUsecase(repo CookingRecipeRepo, favoriteRecipesRepo FavoriteRecipesRepo) *CookingRecipeUsecase {
+func NewCookingRecipeUsecase(repo CookingRecipeRepo, favoriteRecipesRepo FavoriteRecipesRepo,
+	eventsBroker CookingEventsBroker, photoRepo CookingPhotoRepo, joinAttemptRepo JoinAttemptRepo,
+	cfg *config.Config) *CookingRecipeUsecase {
 	return &CookingRecipeUsecase{
 		repo:                repo,
 		favoriteRecipesRepo: favoriteRecipesRepo,
+		eventsBroker:        eventsBroker,
+		photoRepo:           photoRepo,
+		joinAttemptRepo:     joinAttemptRepo,
+		joinCodeTTL:         cfg.JoinCodeTTL,
 	}
 }
 
+func (u *CookingRecipeUsecase) publish(sessionID int, eventType string, data interface{}) {
+	u.eventsBroker.Publish(sessionID, dto.CookingEventDto{
+		Type:      eventType,
+		SessionID: sessionID,
+		Data:      data,
+	})
+}
+
 func (u *CookingRecipeUsecase) GetAllRecipe(ctx context.Context, num int) ([]dto.RecipeDto, error) {
 	recipeModels, err := u.repo.GetAllRecipe(ctx, num)
 
@@ -83,18 +157,42 @@ func (u *CookingRecipeUsecase) GetRecipeByID(ctx context.Context, id int) (dto.R
 	return recipeDto[0], nil
 }
 
-func (u *CookingRecipeUsecase) StartCookingRecipe(ctx context.Context, recipeID int) (dto.CurrentStepRecipeDto, error) {
+// getSession возвращает владельца и id сессии. Участники совместной готовки
+// работают с шагами и таймерами от имени владельца сессии.
+func (u *CookingRecipeUsecase) getSession(ctx context.Context, uID uint, sessionID int) (uint, int, error) {
+	ownerID := uID
+	var err error
+
+	if sessionID == dto.DefaultSessionID {
+		sessionID, err = u.repo.GetDefaultSessionID(ctx, uID)
+	} else {
+		ownerID, err = u.repo.GetSessionOwnerID(ctx, uID, sessionID)
+	}
+
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err = u.repo.TouchSession(ctx, sessionID); err != nil {
+		return 0, 0, err
+	}
+
+	return ownerID, sessionID, nil
+}
+
+func (u *CookingRecipeUsecase) StartCookingRecipe(ctx context.Context, recipeID int,
+	servings int) (dto.CurrentStepRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
-	err = u.repo.StartCooking(ctx, uID, recipeID, false)
+	sessionID, err := u.repo.StartCooking(ctx, uID, recipeID, false, true, servings)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
-	currentRecipeStepModel, err := u.repo.GetCurrentStep(ctx, uID)
+	currentRecipeStepModel, err := u.repo.GetCurrentStep(ctx, uID, sessionID)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
@@ -104,27 +202,234 @@ func (u *CookingRecipeUsecase) StartCookingRecipe(ctx context.Context, recipeID
 	return currentRecipeStep, nil
 }
 
-func (u *CookingRecipeUsecase) EndCookingRecipe(ctx context.Context) error {
+func (u *CookingRecipeUsecase) StartCookingSession(ctx context.Context, recipeID int, isGenerated bool,
+	servings int) (dto.CurrentRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	sessionID, err := u.repo.StartCooking(ctx, uID, recipeID, isGenerated, false, servings)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	return u.getCurrentRecipe(ctx, uID, sessionID)
+}
+
+func (u *CookingRecipeUsecase) GetCookingSessions(ctx context.Context) ([]dto.CurrentRecipeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := u.repo.GetCookingSessions(ctx, uID)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.ConvertCurrentRecipesToDTO(sessions), nil
+}
+
+func (u *CookingRecipeUsecase) EndCookingRecipe(ctx context.Context, sessionID int,
+	feedbackData dto.CookingFeedbackDto) error {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	feedback, err := getCookingFeedback(feedbackData)
 	if err != nil {
 		return err
 	}
 
-	recipeID, IsGenerated, err := u.repo.EndCooking(ctx, uID)
+	ownerID, sessionID, err := u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	if feedbackData.Photo != nil {
+		feedback.Photo, err = u.uploadCookingPhoto(ctx, *feedbackData.Photo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ownerID != uID {
+		err = u.leaveCookingSession(ctx, uID, ownerID, sessionID, feedback)
+	} else {
+		err = u.endCookingSession(ctx, uID, sessionID, feedback)
+	}
+
+	if err != nil && feedback.Photo != "" {
+		_ = u.photoRepo.DeleteImage(ctx, feedback.Photo, cookingPhotoEntity)
+	}
+
+	return err
+}
+
+func getCookingFeedback(feedbackData dto.CookingFeedbackDto) (models.CookingFeedbackModel, error) {
+	if feedbackData.Rating != 0 &&
+		(feedbackData.Rating < minCookingRating || feedbackData.Rating > maxCookingRating) {
+		return models.CookingFeedbackModel{}, internalErrors.ErrInvalidRating
+	}
+
+	notes := strings.TrimSpace(bluemonday.StrictPolicy().Sanitize(feedbackData.Notes))
+	if len([]rune(notes)) > maxCookingNotesSize {
+		return models.CookingFeedbackModel{}, internalErrors.ErrNotesTooLong
+	}
+
+	return models.CookingFeedbackModel{
+		Rating: feedbackData.Rating,
+		Notes:  notes,
+	}, nil
+}
+
+func (u *CookingRecipeUsecase) uploadCookingPhoto(ctx context.Context, photo dto.Image) (string, error) {
+	ext, ok := utils.GetImageExtension(photo.ContentType)
+	if !ok {
+		return "", internalErrors.ErrInvalidImage
+	}
+
+	filename := uuid.New().String() + ext
+
+	err := u.photoRepo.UploadImage(ctx, filename, cookingPhotoEntity, models.ImageModel{
+		ImageSize:   photo.ImageSize,
+		Image:       photo.Image,
+		ContentType: photo.ContentType,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
+
+func (u *CookingRecipeUsecase) endCookingSession(ctx context.Context, uID uint, sessionID int,
+	feedback models.CookingFeedbackModel) error {
+	members, err := u.repo.GetSessionMembers(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+
+	recipeID, IsGenerated, err := u.repo.EndCooking(ctx, uID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	u.publish(sessionID, dto.EventSessionEnded, nil)
+
+	for _, memberID := range members {
+		err = u.repo.AddRecipeToHistory(ctx, memberID, recipeID, IsGenerated, sessionID, models.CookingFeedbackModel{})
+		if err != nil {
+			return err
+		}
+	}
+
+	return u.repo.AddRecipeToHistory(ctx, uID, recipeID, IsGenerated, sessionID, feedback)
+}
+
+func (u *CookingRecipeUsecase) leaveCookingSession(ctx context.Context, uID uint, ownerID uint,
+	sessionID int, feedback models.CookingFeedbackModel) error {
+	currentRecipe, err := u.repo.GetCurrentRecipe(ctx, ownerID, sessionID)
 	if err != nil {
 		return err
 	}
 
-	return u.repo.AddRecipeToHistory(ctx, uID, recipeID, IsGenerated)
+	if err = u.repo.LeaveSession(ctx, uID, sessionID); err != nil {
+		return err
+	}
+
+	u.publish(sessionID, dto.EventMemberLeft, dto.SessionMemberDto{UserID: uID})
+
+	return u.repo.AddRecipeToHistory(ctx, uID, currentRecipe.ID, currentRecipe.IsGenerated, sessionID, feedback)
+}
+
+func (u *CookingRecipeUsecase) CreateJoinCode(ctx context.Context, sessionID int) (dto.JoinCodeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.JoinCodeDto{}, err
+	}
+
+	ownerID, sessionID, err := u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.JoinCodeDto{}, err
+	}
+
+	if ownerID != uID {
+		return dto.JoinCodeDto{}, internalErrors.ErrNotSessionOwner
+	}
+
+	for attempt := 0; attempt < joinCodeAttempts; attempt++ {
+		code, err := utils.GenerateJoinCode(joinCodeLength)
+		if err != nil {
+			return dto.JoinCodeDto{}, internalErrors.ErrFailedToCreateJoinCode
+		}
+
+		err = u.repo.SetJoinCode(ctx, uID, sessionID, code, u.joinCodeTTL)
+		if errors.Is(err, internalErrors.ErrJoinCodeAlreadyExists) {
+			continue
+		}
+		if err != nil {
+			return dto.JoinCodeDto{}, err
+		}
+
+		return dto.JoinCodeDto{Code: code, ExpiresAt: time.Now().Add(u.joinCodeTTL)}, nil
+	}
+
+	return dto.JoinCodeDto{}, internalErrors.ErrFailedToCreateJoinCode
+}
+
+func (u *CookingRecipeUsecase) JoinCookingSession(ctx context.Context, code string) (dto.CurrentRecipeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	// Коды короткие, поэтому число попыток ограничено, иначе их можно перебрать.
+	if err = u.joinAttemptRepo.RegisterJoinAttempt(ctx, uID); err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	sessionID, err := u.repo.JoinSession(ctx, uID, strings.ToUpper(strings.TrimSpace(code)))
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	u.publish(sessionID, dto.EventMemberJoined, dto.SessionMemberDto{UserID: uID})
+
+	return u.GetCurrentRecipe(ctx, sessionID)
 }
 
-func (u *CookingRecipeUsecase) GetCurrentRecipe(ctx context.Context) (dto.CurrentRecipeDto, error) {
+func (u *CookingRecipeUsecase) GetCurrentRecipe(ctx context.Context, sessionID int) (dto.CurrentRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 	if err != nil {
 		return dto.CurrentRecipeDto{}, err
 	}
 
-	currentRecipe, err := u.repo.GetCurrentRecipe(ctx, uID)
+	ownerID, sessionID, err := u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	currentRecipeDto, err := u.getCurrentRecipe(ctx, ownerID, sessionID)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	currentRecipeDto.IsGuest = ownerID != uID
+
+	return currentRecipeDto, nil
+}
+
+func (u *CookingRecipeUsecase) getCurrentRecipe(ctx context.Context, uID uint,
+	sessionID int) (dto.CurrentRecipeDto, error) {
+	currentRecipe, err := u.repo.GetCurrentRecipe(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	currentRecipe.DoneSteps, err = u.repo.GetDoneSteps(ctx, uID, sessionID)
 	if err != nil {
 		return dto.CurrentRecipeDto{}, err
 	}
@@ -134,78 +439,402 @@ func (u *CookingRecipeUsecase) GetCurrentRecipe(ctx context.Context) (dto.Curren
 	return currentRecipeDto, nil
 }
 
-func (u *CookingRecipeUsecase) NextStepRecipe(ctx context.Context) (dto.CurrentStepRecipeDto, error) {
+func (u *CookingRecipeUsecase) NextStepRecipe(ctx context.Context, sessionID int) (dto.CurrentStepRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
-	nextStep, err := u.repo.GetNextRecipeStep(ctx, uID)
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentStepRecipeDto{}, err
+	}
+
+	nextStep, err := u.repo.GetNextRecipeStep(ctx, uID, sessionID)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
 	nextStepDto := models.ConvertCurrentStepToDTO(nextStep)
 
+	u.publish(sessionID, dto.EventStepChanged, nextStepDto)
+
+	u.startAutoTimer(ctx, uID, sessionID, nextStep)
+
 	return nextStepDto, nil
 }
 
-func (u *CookingRecipeUsecase) PreviousStepRecipe(ctx context.Context) (dto.CurrentStepRecipeDto, error) {
+func (u *CookingRecipeUsecase) PreviousStepRecipe(ctx context.Context,
+	sessionID int) (dto.CurrentStepRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
-	prevStep, err := u.repo.GetPrevRecipeStep(ctx, uID)
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentStepRecipeDto{}, err
+	}
+
+	prevStep, err := u.repo.GetPrevRecipeStep(ctx, uID, sessionID)
 	if err != nil {
 		return dto.CurrentStepRecipeDto{}, err
 	}
 
 	prevStepDto := models.ConvertCurrentStepToDTO(prevStep)
 
+	u.publish(sessionID, dto.EventStepChanged, prevStepDto)
+
+	u.startAutoTimer(ctx, uID, sessionID, prevStep)
+
 	return prevStepDto, nil
 }
 
-func (u *CookingRecipeUsecase) AddTimerRecipe(ctx context.Context, stepNum int, timeSec int) error {
+func (u *CookingRecipeUsecase) GoToStepRecipe(ctx context.Context, sessionID int,
+	stepNum int) (dto.CurrentStepRecipeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.CurrentStepRecipeDto{}, err
+	}
+
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentStepRecipeDto{}, err
+	}
+
+	step, err := u.repo.GoToRecipeStep(ctx, uID, sessionID, stepNum)
+	if err != nil {
+		return dto.CurrentStepRecipeDto{}, err
+	}
+
+	stepDto := models.ConvertCurrentStepToDTO(step)
+
+	u.publish(sessionID, dto.EventStepChanged, stepDto)
+
+	u.startAutoTimer(ctx, uID, sessionID, step)
+
+	return stepDto, nil
+}
+
+func (u *CookingRecipeUsecase) MarkStepRecipe(ctx context.Context, sessionID int, stepNum int,
+	isDone bool) (dto.CurrentRecipeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	err = u.repo.SetStepDone(ctx, uID, sessionID, stepNum, isDone)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	currentRecipeDto, err := u.getCurrentRecipe(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	u.publish(sessionID, dto.EventStepChecked, currentRecipeDto.Progress)
+
+	return currentRecipeDto, nil
+}
+
+func (u *CookingRecipeUsecase) GetRecipePrep(ctx context.Context, recipeID int) (dto.PrepChecklistDto, error) {
+	recipeModels, err := u.repo.GetRecipeByID(ctx, recipeID)
+	if err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	return buildRecipePrep(recipeModels[0], recipeID, false)
+}
+
+func buildRecipePrep(recipe models.RecipeModel, recipeID int, isGenerated bool) (dto.PrepChecklistDto, error) {
+	steps, err := utils.ParseRecipeSteps(recipe.Steps)
+	if err != nil {
+		return dto.PrepChecklistDto{}, internalErrors.ErrFailedToGetPrepChecklist
+	}
+
+	items, err := utils.BuildPrepChecklist(recipe.Ingredients, steps)
+	if err != nil {
+		return dto.PrepChecklistDto{}, internalErrors.ErrFailedToGetPrepChecklist
+	}
+
+	return models.ConvertPrepChecklistToDTO(models.PrepChecklistModel{
+		RecipeID:    recipeID,
+		Name:        recipe.Name,
+		IsGenerated: isGenerated,
+		Items:       items,
+	}), nil
+}
+
+func (u *CookingRecipeUsecase) getSessionPrep(ctx context.Context, uID uint,
+	sessionID int) (models.PrepChecklistModel, error) {
+	currentRecipe, err := u.repo.GetCurrentRecipe(ctx, uID, sessionID)
+	if err != nil {
+		return models.PrepChecklistModel{}, err
+	}
+
+	steps, err := u.repo.GetRecipeSteps(ctx, uID, sessionID)
+	if err != nil {
+		return models.PrepChecklistModel{}, err
+	}
+
+	items, err := utils.BuildPrepChecklist(currentRecipe.Ingredients, steps)
+	if err != nil {
+		return models.PrepChecklistModel{}, internalErrors.ErrFailedToGetPrepChecklist
+	}
+
+	checkedItems, err := u.repo.GetCheckedPrepItems(ctx, sessionID)
+	if err != nil {
+		return models.PrepChecklistModel{}, err
+	}
+
+	checkedSet := make(map[string]struct{}, len(checkedItems))
+	for _, itemID := range checkedItems {
+		checkedSet[itemID] = struct{}{}
+	}
+
+	for i := range items {
+		_, items[i].Checked = checkedSet[items[i].ID]
+	}
+
+	return models.PrepChecklistModel{
+		SessionID:   sessionID,
+		RecipeID:    currentRecipe.ID,
+		Name:        currentRecipe.Name,
+		IsGenerated: currentRecipe.IsGenerated,
+		Items:       items,
+	}, nil
+}
+
+func (u *CookingRecipeUsecase) GetPrepRecipe(ctx context.Context, sessionID int) (dto.PrepChecklistDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	checklist, err := u.getSessionPrep(ctx, uID, sessionID)
+	if err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	return models.ConvertPrepChecklistToDTO(checklist), nil
+}
+
+func (u *CookingRecipeUsecase) CheckPrepItemRecipe(ctx context.Context, sessionID int, itemID string,
+	isChecked bool) (dto.PrepChecklistDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	checklist, err := u.getSessionPrep(ctx, uID, sessionID)
+	if err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	itemIdx := -1
+	for i, item := range checklist.Items {
+		if item.ID == itemID {
+			itemIdx = i
+			break
+		}
+	}
+
+	if itemIdx == -1 {
+		return dto.PrepChecklistDto{}, internalErrors.ErrPrepItemNotFound
+	}
+
+	if err = u.repo.SetPrepItemChecked(ctx, sessionID, itemID, isChecked); err != nil {
+		return dto.PrepChecklistDto{}, err
+	}
+
+	checklist.Items[itemIdx].Checked = isChecked
+
+	u.publish(sessionID, dto.EventPrepChecked, models.ConvertPrepItemToDTO(checklist.Items[itemIdx]))
+
+	return models.ConvertPrepChecklistToDTO(checklist), nil
+}
+
+func (u *CookingRecipeUsecase) SetAutoTimersRecipe(ctx context.Context, sessionID int,
+	enabled bool) (dto.CurrentRecipeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	if err = u.repo.SetAutoTimers(ctx, uID, sessionID, enabled); err != nil {
+		return dto.CurrentRecipeDto{}, err
+	}
+
+	if enabled {
+		currentStep, err := u.repo.GetCurrentStep(ctx, uID, sessionID)
+		if err != nil {
+			return dto.CurrentRecipeDto{}, err
+		}
+
+		u.startAutoTimer(ctx, uID, sessionID, currentStep)
+	}
+
+	return u.GetCurrentRecipe(ctx, sessionID)
+}
+
+// startAutoTimer запускает таймер по длительности шага, если в сессии включены автотаймеры.
+// Ошибка уже залогирована в репозитории, переход на шаг из-за неё не отменяется.
+func (u *CookingRecipeUsecase) startAutoTimer(ctx context.Context, uID uint, sessionID int,
+	step models.CurrentStepRecipeModel) {
+	timeSec, ok := utils.StepLengthToSeconds(step.Length)
+	if !ok {
+		return
+	}
+
+	timer, created, err := u.repo.AddAutoTimer(ctx, uID, sessionID, step.NumStep, timeSec, step.Step)
+	if err != nil || !created {
+		return
+	}
+
+	u.publish(sessionID, dto.EventTimerCreated, models.ConvertTimerToDTO(timer))
+}
+
+func (u *CookingRecipeUsecase) AddTimerRecipe(ctx context.Context, sessionID int, stepNum int, timeSec int,
+	label string) (dto.TimerRecipeDto, error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return dto.TimerRecipeDto{}, err
+	}
+
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return dto.TimerRecipeDto{}, err
+	}
+
+	recipeStep, err := u.repo.GetCurrentRecipeStepByNum(ctx, uID, sessionID, stepNum)
+	if err != nil {
+		return dto.TimerRecipeDto{}, err
+	}
+
+	sanitizer := bluemonday.UGCPolicy()
+
+	label = sanitizer.Sanitize(label)
+
+	timer, err := u.repo.AddTimerToRecipe(ctx, uID, sessionID, stepNum, timeSec, recipeStep.Step, label)
+	if err != nil {
+		return dto.TimerRecipeDto{}, err
+	}
+
+	timerDto := models.ConvertTimerToDTO(timer)
+
+	u.publish(sessionID, dto.EventTimerCreated, timerDto)
+
+	return timerDto, nil
+}
+
+func (u *CookingRecipeUsecase) DeleteTimerRecipe(ctx context.Context, sessionID int, timerID int, stepNum int) error {
 	uID, err := utils.GetUserIDFromContext(ctx)
 	if err != nil {
 		return err
 	}
 
-	recipeStep, err := u.repo.GetCurrentRecipeStepByNum(ctx, uID, stepNum)
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
 	if err != nil {
 		return err
 	}
 
-	err = u.repo.AddTimerToRecipe(ctx, uID, stepNum, timeSec, recipeStep.Step)
+	// без id таймера удаляем все таймеры шага, как раньше
+	if timerID == 0 {
+		err = u.repo.DeleteTimerFromRecipe(ctx, uID, sessionID, stepNum)
+	} else {
+		err = u.repo.DeleteTimerByID(ctx, uID, sessionID, timerID)
+	}
+
 	if err != nil {
 		return err
 	}
 
+	u.publish(sessionID, dto.EventTimerDeleted, dto.TimerRecipeDto{ID: timerID, StepNum: stepNum})
+
 	return nil
 }
 
-func (u *CookingRecipeUsecase) DeleteTimerRecipe(ctx context.Context, stepNum int) error {
+func (u *CookingRecipeUsecase) PauseTimerRecipe(ctx context.Context, sessionID int,
+	timerID int) (dto.TimerRecipeDto, error) {
+	return u.updateTimer(ctx, sessionID, dto.EventTimerPaused,
+		func(uID uint, sessionID int) (models.TimerRecipeModel, error) {
+			return u.repo.PauseTimer(ctx, uID, sessionID, timerID)
+		})
+}
+
+func (u *CookingRecipeUsecase) ResumeTimerRecipe(ctx context.Context, sessionID int,
+	timerID int) (dto.TimerRecipeDto, error) {
+	return u.updateTimer(ctx, sessionID, dto.EventTimerResumed,
+		func(uID uint, sessionID int) (models.TimerRecipeModel, error) {
+			return u.repo.ResumeTimer(ctx, uID, sessionID, timerID)
+		})
+}
+
+func (u *CookingRecipeUsecase) ExtendTimerRecipe(ctx context.Context, sessionID int, timerID int,
+	timeSec int) (dto.TimerRecipeDto, error) {
+	return u.updateTimer(ctx, sessionID, dto.EventTimerExtended,
+		func(uID uint, sessionID int) (models.TimerRecipeModel, error) {
+			return u.repo.ExtendTimer(ctx, uID, sessionID, timerID, timeSec)
+		})
+}
+
+func (u *CookingRecipeUsecase) updateTimer(ctx context.Context, sessionID int, eventType string,
+	update func(uID uint, sessionID int) (models.TimerRecipeModel, error)) (dto.TimerRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 	if err != nil {
-		return err
+		return dto.TimerRecipeDto{}, err
 	}
 
-	err = u.repo.DeleteTimerFromRecipe(ctx, uID, stepNum)
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
 	if err != nil {
-		return err
+		return dto.TimerRecipeDto{}, err
 	}
 
-	return nil
+	timer, err := update(uID, sessionID)
+	if err != nil {
+		return dto.TimerRecipeDto{}, err
+	}
+
+	timerDto := models.ConvertTimerToDTO(timer)
+
+	u.publish(sessionID, eventType, timerDto)
+
+	return timerDto, nil
 }
 
-func (u *CookingRecipeUsecase) GetTimersRecipe(ctx context.Context) ([]dto.TimerRecipeDto, error) {
+func (u *CookingRecipeUsecase) GetTimersRecipe(ctx context.Context, sessionID int) ([]dto.TimerRecipeDto, error) {
 	uID, err := utils.GetUserIDFromContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	timersModels, err := u.repo.GetTimersRecipe(ctx, uID)
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	timersModels, err := u.repo.GetTimersRecipe(ctx, uID, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -214,3 +843,24 @@ func (u *CookingRecipeUsecase) GetTimersRecipe(ctx context.Context) ([]dto.Timer
 
 	return timersDto, nil
 }
+
+func (u *CookingRecipeUsecase) SubscribeCookingEvents(ctx context.Context,
+	sessionID int) (<-chan dto.CookingEventDto, func(), error) {
+	uID, err := utils.GetUserIDFromContext(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uID, sessionID, err = u.getSession(ctx, uID, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err = u.repo.GetCurrentRecipe(ctx, uID, sessionID); err != nil {
+		return nil, nil, err
+	}
+
+	events, unsubscribe := u.eventsBroker.Subscribe(sessionID)
+
+	return events, unsubscribe, nil
+}
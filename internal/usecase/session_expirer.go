@@ -0,0 +1,87 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+type SessionExpirerRepo interface {
+	ExpireSessions(ctx context.Context, ttl time.Duration) ([]int, error)
+	ClearExpiredJoinCodes(ctx context.Context) error
+}
+
+type SessionExpirer struct {
+	repo         SessionExpirerRepo
+	eventsBroker CookingEventsBroker
+	ttl          time.Duration
+	interval     time.Duration
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+func NewSessionExpirer(repo SessionExpirerRepo, eventsBroker CookingEventsBroker,
+	cfg *config.Config) *SessionExpirer {
+	return &SessionExpirer{
+		repo:         repo,
+		eventsBroker: eventsBroker,
+		ttl:          cfg.SessionInactivityTTL,
+		interval:     cfg.SessionExpiryInterval,
+	}
+}
+
+func (e *SessionExpirer) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel = cancel
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+
+		e.expire(ctx)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				e.expire(ctx)
+			}
+		}
+	}()
+}
+
+func (e *SessionExpirer) Close() error {
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.wg.Wait()
+	return nil
+}
+
+func (e *SessionExpirer) expire(ctx context.Context) {
+	if err := e.repo.ClearExpiredJoinCodes(ctx); err != nil {
+		logger.Error(ctx, fmt.Sprintf("session expirer failed to clear join codes: %v", err))
+	}
+
+	sessionIDs, err := e.repo.ExpireSessions(ctx, e.ttl)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("session expirer failed to expire sessions: %v", err))
+		return
+	}
+
+	for _, sessionID := range sessionIDs {
+		e.eventsBroker.Publish(sessionID, dto.CookingEventDto{
+			Type:      dto.EventSessionEnded,
+			SessionID: sessionID,
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+type TimerSchedulerRepo interface {
+	ExpireTimers(ctx context.Context, now time.Time) ([]models.TimerRecipeModel, error)
+	GetTimersBySessionIDs(ctx context.Context, sessionIDs []int) ([]models.TimerRecipeModel, error)
+}
+
+type TimerScheduler struct {
+	repo         TimerSchedulerRepo
+	eventsBroker CookingEventsBroker
+	interval     time.Duration
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+func NewTimerScheduler(repo TimerSchedulerRepo, eventsBroker CookingEventsBroker,
+	cfg *config.Config) *TimerScheduler {
+	return &TimerScheduler{
+		repo:         repo,
+		eventsBroker: eventsBroker,
+		interval:     cfg.TimerSchedulerInterval,
+	}
+}
+
+func (s *TimerScheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.fireExpired(ctx, now)
+				s.tick(ctx)
+			}
+		}
+	}()
+}
+
+func (s *TimerScheduler) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+	return nil
+}
+
+func (s *TimerScheduler) fireExpired(ctx context.Context, now time.Time) {
+	timers, err := s.repo.ExpireTimers(ctx, now)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("timer scheduler failed to expire timers: %v", err))
+		return
+	}
+
+	for _, timer := range timers {
+		s.eventsBroker.Publish(timer.SessionID, dto.CookingEventDto{
+			Type:      dto.EventTimerExpired,
+			SessionID: timer.SessionID,
+			Data:      models.ConvertTimerToDTO(timer),
+		})
+	}
+}
+
+func (s *TimerScheduler) tick(ctx context.Context) {
+	sessionIDs := s.eventsBroker.Topics()
+	if len(sessionIDs) == 0 {
+		return
+	}
+
+	timers, err := s.repo.GetTimersBySessionIDs(ctx, sessionIDs)
+	if err != nil {
+		logger.Error(ctx, fmt.Sprintf("timer scheduler failed to get timers: %v", err))
+		return
+	}
+
+	timersBySession := make(map[int][]models.TimerRecipeModel)
+	for _, timer := range timers {
+		timersBySession[timer.SessionID] = append(timersBySession[timer.SessionID], timer)
+	}
+
+	for sessionID, sessionTimers := range timersBySession {
+		s.eventsBroker.Publish(sessionID, dto.CookingEventDto{
+			Type:      dto.EventTimerTick,
+			SessionID: sessionID,
+			Data:      models.ConvertTimersToDTO(sessionTimers),
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+	"github.com/Olegsandrik/Exponenta/internal/utils"
+	"github.com/Olegsandrik/Exponenta/logger"
+)
+
+const (
+	voiceIntentUnknown = iota
+	voiceIntentNextStep
+	voiceIntentPrevStep
+	voiceIntentEndCooking
+	voiceIntentStopTimer
+	voiceIntentStartTimer
+	voiceIntentGetTimers
+	voiceIntentGoToStep
+	voiceIntentRepeatStep
+	voiceIntentQuestion
+)
+
+const (
+	voiceModeRulesFirst = "rules_first"
+	voiceModeLLMFirst   = "llm_first"
+	voiceModeRulesOnly  = "rules_only"
+	voiceModeLLMOnly    = "llm_only"
+
+	voiceSourceRules = "rules"
+	voiceSourceLLM   = "llm"
+)
+
+type VoiceRepo interface {
+	RecognizeIntent(ctx context.Context, text string) (int, error)
+	TranscribeAudio(ctx context.Context, audio models.VoiceAudioModel) (string, error)
+}
+
+type VoiceSpeechRepo interface {
+	GetCachedSpeech(ctx context.Context, text string) (models.SpeechModel, error)
+	CacheSpeech(ctx context.Context, text string, speech models.SpeechModel) error
+	SynthesizeSpeech(ctx context.Context, text string) (models.SpeechModel, error)
+}
+
+type VoiceCookingUsecase interface {
+	GetCurrentRecipe(ctx context.Context, sessionID int) (dto.CurrentRecipeDto, error)
+	NextStepRecipe(ctx context.Context, sessionID int) (dto.CurrentStepRecipeDto, error)
+	PreviousStepRecipe(ctx context.Context, sessionID int) (dto.CurrentStepRecipeDto, error)
+	GoToStepRecipe(ctx context.Context, sessionID int, stepNum int) (dto.CurrentStepRecipeDto, error)
+	EndCookingRecipe(ctx context.Context, sessionID int, feedback dto.CookingFeedbackDto) error
+	AddTimerRecipe(ctx context.Context, sessionID int, stepNum int, timeSec int, label string) (
+		dto.TimerRecipeDto, error)
+	DeleteTimerRecipe(ctx context.Context, sessionID int, timerID int, stepNum int) error
+	GetTimersRecipe(ctx context.Context, sessionID int) ([]dto.TimerRecipeDto, error)
+}
+
+type VoiceUsecase struct {
+	repo           VoiceRepo
+	speechRepo     VoiceSpeechRepo
+	cookingUsecase VoiceCookingUsecase
+	classifier     *VoiceRuleClassifier
+	mode           string
+	threshold      float64
+}
+
+func NewVoiceUsecase(repo VoiceRepo, speechRepo VoiceSpeechRepo, cookingUsecase VoiceCookingUsecase,
+	cfg *config.Config) *VoiceUsecase {
+	return &VoiceUsecase{
+		repo:           repo,
+		speechRepo:     speechRepo,
+		cookingUsecase: cookingUsecase,
+		classifier:     NewVoiceRuleClassifier(),
+		mode:           cfg.VoiceClassifierMode,
+		threshold:      cfg.VoiceRulesThreshold,
+	}
+}
+
+// getText возвращает текст команды, при необходимости распознавая его из аудио.
+func (u *VoiceUsecase) getText(ctx context.Context, voice dto.VoiceDto) (string, error) {
+	text := voice.Text
+
+	if voice.Audio != nil {
+		var err error
+
+		text, err = u.repo.TranscribeAudio(ctx, models.ConvertDTOToVoiceAudio(*voice.Audio))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", internalErrors.ErrVoiceTextEmpty
+	}
+
+	return text, nil
+}
+
+func (u *VoiceUsecase) RecognizeIntent(ctx context.Context, voice dto.VoiceDto) (int, error) {
+	text, err := u.getText(ctx, voice)
+	if err != nil {
+		return 0, err
+	}
+
+	intent, err := u.recognize(ctx, text, utils.ExtractVoiceSlots(text))
+	if err != nil {
+		return 0, err
+	}
+
+	return intent.Intent, nil
+}
+
+// recognize определяет интент локальными правилами и через LLM в зависимости от режима.
+// В режиме rules_first LLM не вызывается, если уверенность правил не ниже порога.
+// При недоступности LLM используется ответ правил, только если их уверенность не ниже порога:
+// по слабому совпадению нельзя, например, завершить готовку.
+func (u *VoiceUsecase) recognize(ctx context.Context, text string,
+	slots models.VoiceSlotsModel) (models.VoiceIntentModel, error) {
+	ruleIntent, confidence := u.classifier.Classify(text, slots)
+	rules := models.VoiceIntentModel{Intent: ruleIntent, Confidence: confidence, Source: voiceSourceRules}
+
+	if u.mode == voiceModeRulesOnly {
+		u.logDecision(ctx, text, rules, rules, "rules only")
+		return rules, nil
+	}
+
+	if u.mode != voiceModeLLMFirst && u.mode != voiceModeLLMOnly &&
+		ruleIntent != voiceIntentUnknown && confidence >= u.threshold {
+		u.logDecision(ctx, text, rules, rules, "rules confident, llm skipped")
+		return rules, nil
+	}
+
+	llmIntent, err := u.repo.RecognizeIntent(ctx, text)
+	if err != nil {
+		if u.mode == voiceModeLLMOnly || ruleIntent == voiceIntentUnknown || confidence < u.threshold {
+			u.logDecision(ctx, text, rules, models.VoiceIntentModel{}, "llm failed, no confident fallback")
+			return models.VoiceIntentModel{}, err
+		}
+
+		u.logDecision(ctx, text, rules, rules, "llm failed, fallback to rules")
+		return rules, nil
+	}
+
+	llm := models.VoiceIntentModel{Intent: llmIntent, Confidence: 1, Source: voiceSourceLLM}
+	u.logDecision(ctx, text, rules, llm, "llm")
+
+	return llm, nil
+}
+
+func (u *VoiceUsecase) logDecision(ctx context.Context, text string, rules models.VoiceIntentModel,
+	decision models.VoiceIntentModel, reason string) {
+	logger.Info(ctx, fmt.Sprintf(
+		"voice intent decision: %s, mode: %s, text: %q, rules intent: %d, rules confidence: %.2f, "+
+			"threshold: %.2f, intent: %d, source: %s",
+		reason, u.mode, text, rules.Intent, rules.Confidence, u.threshold, decision.Intent, decision.Source))
+}
+
+func (u *VoiceUsecase) ExecuteCommand(ctx context.Context, sessionID int,
+	voice dto.VoiceDto) (dto.VoiceCommandDto, error) {
+	if _, err := utils.GetUserIDFromContext(ctx); err != nil {
+		return dto.VoiceCommandDto{}, err
+	}
+
+	text, err := u.getText(ctx, voice)
+	if err != nil {
+		return dto.VoiceCommandDto{}, err
+	}
+
+	slots := utils.ExtractVoiceSlots(text)
+
+	intent, err := u.recognize(ctx, text, slots)
+	if err != nil {
+		return dto.VoiceCommandDto{}, err
+	}
+
+	command := dto.VoiceCommandDto{
+		Intent:     intent.Intent,
+		Source:     intent.Source,
+		Confidence: intent.Confidence,
+		Text:       text,
+		Slots:      models.ConvertVoiceSlotsToDTO(slots),
+	}
+
+	command.Action, command.Result, err = u.executeIntent(ctx, sessionID, intent.Intent, text, slots)
+	if err != nil {
+		return dto.VoiceCommandDto{}, err
+	}
+
+	return command, nil
+}
+
+func (u *VoiceUsecase) executeIntent(ctx context.Context, sessionID int, intent int, text string,
+	slots models.VoiceSlotsModel) (string, interface{}, error) {
+	// "перейди к шагу 3" модель может распознать как просто переключение шага
+	if intent == voiceIntentNextStep && slots.StepNum > 0 {
+		intent = voiceIntentGoToStep
+	}
+
+	switch intent {
+	case voiceIntentGoToStep:
+		if slots.StepNum == 0 {
+			return dto.VoiceActionGoToStep, nil, internalErrors.ErrVoiceStepNumUnknown
+		}
+		step, err := u.cookingUsecase.GoToStepRecipe(ctx, sessionID, slots.StepNum)
+		return dto.VoiceActionGoToStep, step, err
+	case voiceIntentRepeatStep:
+		currentRecipe, err := u.cookingUsecase.GetCurrentRecipe(ctx, sessionID)
+		return dto.VoiceActionRepeatStep, currentRecipe.CurrentStep, err
+	case voiceIntentQuestion:
+		answer, err := u.answerQuestion(ctx, sessionID, text)
+		return dto.VoiceActionAnswerQuestion, answer, err
+	case voiceIntentNextStep:
+		step, err := u.cookingUsecase.NextStepRecipe(ctx, sessionID)
+		return dto.VoiceActionNextStep, step, err
+	case voiceIntentPrevStep:
+		step, err := u.cookingUsecase.PreviousStepRecipe(ctx, sessionID)
+		return dto.VoiceActionPrevStep, step, err
+	case voiceIntentEndCooking:
+		err := u.cookingUsecase.EndCookingRecipe(ctx, sessionID, dto.CookingFeedbackDto{})
+		return dto.VoiceActionEndCooking, nil, err
+	case voiceIntentStopTimer:
+		timers, err := u.stopTimer(ctx, sessionID)
+		return dto.VoiceActionStopTimer, timers, err
+	case voiceIntentStartTimer:
+		timer, err := u.startTimer(ctx, sessionID, slots)
+		return dto.VoiceActionStartTimer, timer, err
+	case voiceIntentGetTimers:
+		timers, err := u.cookingUsecase.GetTimersRecipe(ctx, sessionID)
+		return dto.VoiceActionGetTimers, timers, err
+	default:
+		return dto.VoiceActionUnknown, nil, nil
+	}
+}
+
+// answerQuestion отвечает на вопрос по текущему шагу из данных сессии, без обращения к LLM.
+func (u *VoiceUsecase) answerQuestion(ctx context.Context, sessionID int, text string) (dto.VoiceAnswerDto, error) {
+	currentRecipe, err := u.cookingUsecase.GetCurrentRecipe(ctx, sessionID)
+	if err != nil {
+		return dto.VoiceAnswerDto{}, err
+	}
+
+	return dto.VoiceAnswerDto{
+		Answer:  utils.AnswerStepQuestion(text, currentRecipe),
+		StepNum: currentRecipe.CurrentStep.NumStep,
+	}, nil
+}
+
+// startTimer заводит таймер на названную длительность, а если ее нет - на длительность текущего шага.
+func (u *VoiceUsecase) startTimer(ctx context.Context, sessionID int,
+	slots models.VoiceSlotsModel) (dto.TimerRecipeDto, error) {
+	currentRecipe, err := u.cookingUsecase.GetCurrentRecipe(ctx, sessionID)
+	if err != nil {
+		return dto.TimerRecipeDto{}, err
+	}
+
+	timeSec := slots.DurationSec
+	if timeSec <= 0 {
+		var ok bool
+		timeSec, ok = utils.StepLengthToSeconds(currentRecipe.CurrentStep.Length)
+		if !ok {
+			return dto.TimerRecipeDto{}, internalErrors.ErrVoiceTimerLengthUnknown
+		}
+	}
+
+	stepNum := currentRecipe.CurrentStep.NumStep
+	if slots.StepNum > 0 {
+		stepNum = slots.StepNum
+	}
+
+	return u.cookingUsecase.AddTimerRecipe(ctx, currentRecipe.SessionID, stepNum, timeSec, slots.Label)
+}
+
+// stopTimer останавливает таймеры текущего шага, а если их нет - самый старый таймер сессии.
+func (u *VoiceUsecase) stopTimer(ctx context.Context, sessionID int) ([]dto.TimerRecipeDto, error) {
+	currentRecipe, err := u.cookingUsecase.GetCurrentRecipe(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID = currentRecipe.SessionID
+
+	timers, err := u.cookingUsecase.GetTimersRecipe(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(timers) == 0 {
+		return nil, internalErrors.ErrVoiceNoTimers
+	}
+
+	timerID, stepNum := timers[0].ID, timers[0].StepNum
+	for _, timer := range timers {
+		if timer.StepNum == currentRecipe.CurrentStep.NumStep {
+			timerID, stepNum = 0, timer.StepNum
+			break
+		}
+	}
+
+	if err = u.cookingUsecase.DeleteTimerRecipe(ctx, sessionID, timerID, stepNum); err != nil {
+		return nil, err
+	}
+
+	return u.cookingUsecase.GetTimersRecipe(ctx, sessionID)
+}
+
+func (u *VoiceUsecase) SpeakCurrentStep(ctx context.Context, sessionID int) (dto.SpeechDto, error) {
+	currentRecipe, err := u.cookingUsecase.GetCurrentRecipe(ctx, sessionID)
+	if err != nil {
+		return dto.SpeechDto{}, err
+	}
+
+	return u.speak(ctx, utils.StepSpeechText(currentRecipe.CurrentStep))
+}
+
+func (u *VoiceUsecase) SpeakTimer(ctx context.Context, sessionID int, timerID int) (dto.SpeechDto, error) {
+	currentRecipe, err := u.cookingUsecase.GetCurrentRecipe(ctx, sessionID)
+	if err != nil {
+		return dto.SpeechDto{}, err
+	}
+
+	timers, err := u.cookingUsecase.GetTimersRecipe(ctx, currentRecipe.SessionID)
+	if err != nil {
+		return dto.SpeechDto{}, err
+	}
+
+	for _, timer := range timers {
+		if timer.ID == timerID {
+			return u.speak(ctx, utils.TimerSpeechText(timer))
+		}
+	}
+
+	return dto.SpeechDto{}, internalErrors.ErrTimerNotFound
+}
+
+// speak отдает озвучку из кеша, а если ее там нет - синтезирует и кладет в кеш.
+// Ошибки кеша не мешают ответу: в худшем случае текст синтезируется заново.
+func (u *VoiceUsecase) speak(ctx context.Context, text string) (dto.SpeechDto, error) {
+	if text == "" {
+		return dto.SpeechDto{}, internalErrors.ErrNothingToSpeak
+	}
+
+	speech, err := u.speechRepo.GetCachedSpeech(ctx, text)
+	if err == nil {
+		return models.ConvertSpeechToDTO(speech), nil
+	}
+
+	speech, err = u.speechRepo.SynthesizeSpeech(ctx, text)
+	if err != nil {
+		return dto.SpeechDto{}, err
+	}
+
+	if err = u.speechRepo.CacheSpeech(ctx, text, speech); err != nil {
+		logger.Error(ctx, fmt.Sprintf("failed to cache speech: %v", err))
+	}
+
+	if _, err = speech.Audio.Seek(0, io.SeekStart); err != nil {
+		return dto.SpeechDto{}, internalErrors.ErrFailedToSynthesizeSpeech
+	}
+
+	return models.ConvertSpeechToDTO(speech), nil
+}
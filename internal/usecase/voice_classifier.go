@@ -0,0 +1,130 @@
+package usecase
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dlclark/regexp2"
+
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+const (
+	voiceRuleStrong = 0.9
+	voiceRuleWeak   = 0.6
+
+	// voiceRuleConflictPenalty снижает уверенность, если текст подходит под несколько интентов.
+	voiceRuleConflictPenalty = 0.5
+	voiceRuleMatchTimeout    = 50 * time.Millisecond
+)
+
+type voiceRule struct {
+	intent int
+	weight float64
+	re     *regexp2.Regexp
+}
+
+type VoiceRuleClassifier struct {
+	rules []voiceRule
+}
+
+func NewVoiceRuleClassifier() *VoiceRuleClassifier {
+	patterns := []struct {
+		intent  int
+		weight  float64
+		pattern string
+	}{
+		{voiceIntentNextStep, voiceRuleStrong, `\b(следующ\w*|дальше|далее|вперед)\b`},
+		{voiceIntentNextStep, voiceRuleWeak, `\b(продолж\w*|готово|сделал\w*|поехали)\b`},
+		{voiceIntentPrevStep, voiceRuleStrong, `\b(предыдущ\w*|назад|прошл\w*\s+шаг\w*)\b`},
+		{voiceIntentPrevStep, voiceRuleWeak, `\b(вернись|вернуться|верни)\b`},
+		{voiceIntentEndCooking, voiceRuleStrong,
+			`\b(законч|заверш|прекрат|останов)\w*\s+(\w+\s+)?(готовк\w*|рецепт\w*|приготовлени\w*|сессию)\b`},
+		{voiceIntentEndCooking, voiceRuleWeak, `\b(все\s+готово|блюдо\s+готово|конец|я\s+закончил\w*)\b`},
+		{voiceIntentStopTimer, voiceRuleStrong,
+			`\b(выключ|останов|сбрось|сбрас|отключ|убер|удал|отмен|стоп)\w*\s+(\w+\s+)?таймер\w*\b`},
+		{voiceIntentStopTimer, voiceRuleWeak, `\bтаймер\w*\s+(стоп|хватит|выключ\w*)\b`},
+		{voiceIntentStartTimer, voiceRuleStrong,
+			`\b(постав|засек|запуст|завед|включ|установ|начн|добав)\w*\s+(\w+\s+)?таймер\w*\b`},
+		{voiceIntentStartTimer, voiceRuleStrong, `\b(засеки|отсчитай|напомни\s+через)\b`},
+		{voiceIntentStartTimer, voiceRuleWeak, `\bтаймер\w*\s+на\b`},
+		{voiceIntentGetTimers, voiceRuleStrong,
+			`\b(как\w*|сколько|покажи|список|все|оставш\w*|активн\w*)\b.*\bтаймер\w*\b`},
+		{voiceIntentGetTimers, voiceRuleWeak, `\bсколько\s+(еще\s+)?(осталось|времени)\b`},
+		{voiceIntentGoToStep, voiceRuleStrong,
+			`\b(перейд|переключ|открой|покажи|давай|иди|вернись|верни)\w*\s+(\w+\s+){0,2}шаг\w*\b`},
+		{voiceIntentRepeatStep, voiceRuleStrong,
+			`\b(повтори\w*|прочитай|прочти|зачитай|еще\s+раз)\b`},
+		{voiceIntentRepeatStep, voiceRuleWeak,
+			`\b(что\s+(сейчас\s+)?(нужно|надо)\s+делать|какой\s+(сейчас\s+)?шаг|текущий\s+шаг)\b`},
+		{voiceIntentQuestion, voiceRuleStrong,
+			`\b(сколько|какая|какой|какую|каким|какое|как\s+долго|нужн\w*\s+ли|до\s+какой|на\s+каком)\b`},
+		{voiceIntentQuestion, voiceRuleWeak, `\?\s*$`},
+	}
+
+	rules := make([]voiceRule, 0, len(patterns))
+	for _, p := range patterns {
+		re := regexp2.MustCompile(p.pattern, regexp2.IgnoreCase)
+		re.MatchTimeout = voiceRuleMatchTimeout
+
+		rules = append(rules, voiceRule{intent: p.intent, weight: p.weight, re: re})
+	}
+
+	return &VoiceRuleClassifier{rules: rules}
+}
+
+// Classify возвращает интент и уверенность от 0 до 1. Интент перехода к шагу
+// засчитывается только если в тексте удалось найти номер шага.
+func (c *VoiceRuleClassifier) Classify(text string, slots models.VoiceSlotsModel) (int, float64) {
+	text = strings.ToLower(strings.ReplaceAll(text, "ё", "е"))
+
+	scores := make(map[int]float64)
+
+	for _, rule := range c.rules {
+		if rule.intent == voiceIntentGoToStep && slots.StepNum == 0 {
+			continue
+		}
+
+		matched, err := rule.re.MatchString(text)
+		if err != nil || !matched {
+			continue
+		}
+
+		if rule.weight > scores[rule.intent] {
+			scores[rule.intent] = rule.weight
+		}
+	}
+
+	// "вернись к шагу 2" подходит и под предыдущий шаг, но номер шага важнее
+	if _, ok := scores[voiceIntentGoToStep]; ok {
+		delete(scores, voiceIntentNextStep)
+		delete(scores, voiceIntentPrevStep)
+	}
+
+	// "поставь таймер на 5 минут" не является вопросом о таймерах
+	if _, ok := scores[voiceIntentStartTimer]; ok && scores[voiceIntentGetTimers] < voiceRuleStrong {
+		delete(scores, voiceIntentGetTimers)
+	}
+
+	// "сколько осталось на таймере" и "какой сейчас шаг" - не вопросы о содержании шага
+	if scores[voiceIntentGetTimers] > 0 || scores[voiceIntentRepeatStep] > 0 {
+		delete(scores, voiceIntentQuestion)
+	}
+
+	bestIntent, best, second := voiceIntentUnknown, 0.0, 0.0
+	for intent, score := range scores {
+		switch {
+		case score > best || score == best && intent < bestIntent:
+			second = best
+			bestIntent, best = intent, score
+		case score > second:
+			second = score
+		}
+	}
+
+	if second > 0 {
+		best -= second * voiceRuleConflictPenalty
+	}
+
+	return bestIntent, best
+}
@@ -0,0 +1,120 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Olegsandrik/Exponenta/config"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/llm"
+	"github.com/Olegsandrik/Exponenta/internal/adapters/speech"
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+	internalErrors "github.com/Olegsandrik/Exponenta/internal/internalerrors"
+	"github.com/Olegsandrik/Exponenta/internal/repository"
+)
+
+func TestVoiceUsecaseRecognizeIntent(t *testing.T) {
+	tests := []struct {
+		name       string
+		mode       string
+		voice      dto.VoiceDto
+		recognized string
+		llmAnswer  string
+		want       int
+		wantErr    error
+	}{
+		{
+			name:      "confident rules skip llm",
+			mode:      voiceModeRulesFirst,
+			voice:     dto.VoiceDto{Text: "следующий шаг"},
+			llmAnswer: "not a number",
+			want:      voiceIntentNextStep,
+		},
+		{
+			name:       "audio is transcribed",
+			mode:       voiceModeRulesOnly,
+			voice:      dto.VoiceDto{Audio: &dto.VoiceAudioDto{ContentType: "audio/ogg"}},
+			recognized: "следующий шаг",
+			want:       voiceIntentNextStep,
+		},
+		{
+			name:       "transcribed audio is sent to llm",
+			mode:       voiceModeLLMOnly,
+			voice:      dto.VoiceDto{Audio: &dto.VoiceAudioDto{ContentType: "audio/ogg"}},
+			recognized: "следующий шаг",
+			llmAnswer:  "2",
+			want:       voiceIntentPrevStep,
+		},
+		{
+			name:       "audio replaces text",
+			mode:       voiceModeRulesOnly,
+			voice:      dto.VoiceDto{Text: "следующий шаг", Audio: &dto.VoiceAudioDto{}},
+			recognized: "  ",
+			wantErr:    internalErrors.ErrVoiceTextEmpty,
+		},
+		{
+			name:    "empty text",
+			mode:    voiceModeRulesFirst,
+			voice:   dto.VoiceDto{Text: " \n"},
+			wantErr: internalErrors.ErrVoiceTextEmpty,
+		},
+		{
+			name:      "llm answer in markdown",
+			mode:      voiceModeLLMFirst,
+			voice:     dto.VoiceDto{Text: "ммм"},
+			llmAnswer: "```\n3\n```",
+			want:      voiceIntentEndCooking,
+		},
+		{
+			name:      "unexpected llm answer without rules fallback",
+			mode:      voiceModeLLMOnly,
+			voice:     dto.VoiceDto{Text: "следующий шаг"},
+			llmAnswer: "дальше",
+			wantErr:   internalErrors.ErrFailedToRecognizeVoice,
+		},
+		{
+			name:      "unexpected llm answer with rules fallback",
+			mode:      voiceModeLLMFirst,
+			voice:     dto.VoiceDto{Text: "следующий шаг"},
+			llmAnswer: "дальше",
+			want:      voiceIntentNextStep,
+		},
+		{
+			name:      "unexpected llm answer with weak rules",
+			mode:      voiceModeRulesFirst,
+			voice:     dto.VoiceDto{Text: "конец"},
+			llmAnswer: "дальше",
+			wantErr:   internalErrors.ErrFailedToRecognizeVoice,
+		},
+		{
+			name:      "unexpected llm answer with weak rules in llm first mode",
+			mode:      voiceModeLLMFirst,
+			voice:     dto.VoiceDto{Text: "конец"},
+			llmAnswer: "дальше",
+			wantErr:   internalErrors.ErrFailedToRecognizeVoice,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{VoiceClassifierMode: tt.mode, VoiceRulesThreshold: 0.8}
+			provider := llm.NewFakeProvider(map[string]string{llm.UseCaseVoice: tt.llmAnswer})
+			repo := repository.NewVoiceRepo(cfg, provider, repository.NewKeyPool(cfg),
+				speech.NewFakeRecognizer(tt.recognized))
+
+			if tt.voice.Audio != nil {
+				tt.voice.Audio.Audio = strings.NewReader("audio")
+			}
+
+			got, err := NewVoiceUsecase(repo, nil, nil, cfg).RecognizeIntent(context.Background(), tt.voice)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RecognizeIntent() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("RecognizeIntent() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
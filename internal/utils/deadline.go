@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"net/http"
+	"time"
+)
+
+// ExtendDeadlines переносит дедлайны чтения запроса и записи ответа, выставленные сервером через
+// ReadTimeout и WriteTimeout, на timeout от текущего момента.
+func ExtendDeadlines(w http.ResponseWriter, timeout time.Duration) error {
+	rc := http.NewResponseController(w)
+	deadline := time.Now().Add(timeout)
+
+	if err := rc.SetReadDeadline(deadline); err != nil {
+		return err
+	}
+
+	return rc.SetWriteDeadline(deadline)
+}
+
+// ExtendWriteDeadline переносит дедлайн записи ответа на timeout от текущего момента.
+func ExtendWriteDeadline(w http.ResponseWriter, timeout time.Duration) error {
+	return http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout))
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const minLemmaLen = 3
+
+// russianEndings - окончания существительных и прилагательных, от длинных к коротким.
+func russianEndings() []string {
+	return []string{
+		"иями", "ями", "ами", "ого", "его", "ому", "ему", "ыми", "ими",
+		"ей", "ий", "ый", "ой", "ая", "яя", "ое", "ее", "ые", "ие", "ов", "ев", "ам", "ям", "ах", "ях",
+		"ом", "ем", "ию", "ью", "ия", "ую", "юю",
+		"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+	}
+}
+
+// lemmatizeWord отрезает окончание, чтобы "курица", "курицу" и "курицы" давали одну основу.
+func lemmatizeWord(word string) string {
+	for _, ending := range russianEndings() {
+		stem, ok := strings.CutSuffix(word, ending)
+		if ok && len([]rune(stem)) >= minLemmaLen {
+			return stem
+		}
+	}
+
+	return word
+}
+
+func lemmatizeText(text string) []string {
+	text = strings.ToLower(strings.ReplaceAll(text, "ё", "е"))
+
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for i, word := range words {
+		words[i] = lemmatizeWord(word)
+	}
+
+	return words
+}
+
+// NormalizeGenerationRequest приводит продукты и пожелания к виду, не зависящему от регистра, порядка
+// продуктов, повторов и падежей: "Курица, рис" и "рис, курицу" дают одну и ту же строку.
+func NormalizeGenerationRequest(products []string, query string) string {
+	seen := make(map[string]bool, len(products))
+	normalized := make([]string, 0, len(products))
+
+	for _, product := range products {
+		words := lemmatizeText(product)
+		if len(words) == 0 {
+			continue
+		}
+
+		product = strings.Join(words, " ")
+		if seen[product] {
+			continue
+		}
+
+		seen[product] = true
+		normalized = append(normalized, product)
+	}
+
+	sort.Strings(normalized)
+
+	return strings.Join(normalized, ",") + "|" + strings.Join(lemmatizeText(query), " ")
+}
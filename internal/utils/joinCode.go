@@ -0,0 +1,24 @@
+package utils
+
+import (
+	"crypto/rand"
+	"math/big"
+)
+
+// Без похожих символов (0/O, 1/I), чтобы код было удобно продиктовать.
+const joinCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func GenerateJoinCode(length int) (string, error) {
+	code := make([]byte, length)
+	alphabetLen := big.NewInt(int64(len(joinCodeAlphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabetLen)
+		if err != nil {
+			return "", err
+		}
+		code[i] = joinCodeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+)
+
+var llmFenceRegex = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*(.*?)\\s*```")
+
+// TrimLLMResponse убирает из ответа модели markdown-обертку вида ```json ... ```.
+func TrimLLMResponse(respData string) string {
+	respData = strings.TrimSpace(respData)
+
+	if fenced := llmFenceRegex.FindStringSubmatch(respData); fenced != nil {
+		respData = fenced[1]
+	}
+
+	return strings.TrimSpace(respData)
+}
+
+// ExtractLLMJSON достает из ответа модели JSON-объект: без markdown-обертки и пояснений вокруг него.
+// Переводы строк и табуляции заменяются пробелами, так как модели иногда ставят их внутри строк.
+func ExtractLLMJSON(respData string) string {
+	respData = TrimLLMResponse(respData)
+
+	start, end := strings.Index(respData, "{"), strings.LastIndex(respData, "}")
+	if start >= 0 && end > start {
+		respData = respData[start : end+1]
+	}
+
+	return strings.NewReplacer("\r", " ", "\n", " ", "\t", " ").Replace(respData)
+}
@@ -0,0 +1,208 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+type prepIngredient struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Image  string  `json:"image"`
+	Amount float64 `json:"amount"`
+	Unit   string  `json:"unit"`
+}
+
+type prepStepItem struct {
+	ID            int    `json:"id"`
+	Name          string `json:"name"`
+	LocalizedName string `json:"localizedName"`
+	Image         string `json:"image"`
+}
+
+type prepStep struct {
+	NumStep     int             `json:"number"`
+	Ingredients json.RawMessage `json:"ingredients"`
+	Equipment   json.RawMessage `json:"equipment"`
+}
+
+// prepChecklist хранит позиции списка. Один ингредиент в разных единицах - это разные позиции
+// под одним ключом: byID хранит все позиции ключа, а byName ведет от названия к ключу.
+type prepChecklist struct {
+	items  []models.PrepItemModel
+	byID   map[string][]int
+	byName map[string]string
+}
+
+func normalizePrepName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func prepItemID(kind string, id int, name string) string {
+	if id != 0 {
+		return fmt.Sprintf("%s:%d", kind, id)
+	}
+	return fmt.Sprintf("%s:%s", kind, normalizePrepName(name))
+}
+
+func (c *prepChecklist) find(kind string, id int, names ...string) (string, bool) {
+	if key := prepItemID(kind, id, ""); id != 0 && len(c.byID[key]) > 0 {
+		return key, true
+	}
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if key, ok := c.byName[kind+":"+normalizePrepName(name)]; ok {
+			return key, true
+		}
+	}
+
+	return "", false
+}
+
+// add добавляет позицию под ключом key. Первая позиция ключа получает id, равный key, следующие
+// (тот же ингредиент в другой единице) - key с единицей измерения, чтобы отметки не путались.
+func (c *prepChecklist) add(key string, item models.PrepItemModel, names ...string) {
+	item.ID = key
+	if len(c.byID[key]) > 0 {
+		item.ID = key + ":" + normalizePrepName(item.Unit)
+	}
+
+	c.items = append(c.items, item)
+
+	c.byID[key] = append(c.byID[key], len(c.items)-1)
+	for _, name := range names {
+		if name != "" {
+			c.byName[item.Kind+":"+normalizePrepName(name)] = key
+		}
+	}
+}
+
+func (c *prepChecklist) addStepItems(kind string, stepNum int, rawItems json.RawMessage) error {
+	if len(rawItems) == 0 || string(rawItems) == "null" {
+		return nil
+	}
+
+	var stepItems []prepStepItem
+
+	if err := json.Unmarshal(rawItems, &stepItems); err != nil {
+		return err
+	}
+
+	for _, stepItem := range stepItems {
+		name := stepItem.LocalizedName
+		if name == "" {
+			name = stepItem.Name
+		}
+
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+
+		key, ok := c.find(kind, stepItem.ID, stepItem.Name, stepItem.LocalizedName)
+		if !ok {
+			key = prepItemID(kind, stepItem.ID, name)
+			c.add(key, models.PrepItemModel{
+				Kind:  kind,
+				Name:  name,
+				Image: stepItem.Image,
+			}, stepItem.Name, stepItem.LocalizedName)
+		}
+
+		// Шаг относится ко всем позициям ингредиента, в какой бы единице они ни были.
+		for _, i := range c.byID[key] {
+			steps := c.items[i].Steps
+			if len(steps) == 0 || steps[len(steps)-1] != stepNum {
+				c.items[i].Steps = append(steps, stepNum)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ParseRecipeSteps разбирает шаги рецепта в том виде, в котором они хранятся в recipes и generated_recipes.
+func ParseRecipeSteps(steps string) ([]models.CurrentStepRecipeModel, error) {
+	if strings.TrimSpace(steps) == "" {
+		return nil, nil
+	}
+
+	var stepItems []prepStep
+
+	if err := json.Unmarshal([]byte(steps), &stepItems); err != nil {
+		return nil, err
+	}
+
+	stepModels := make([]models.CurrentStepRecipeModel, len(stepItems))
+	for i, step := range stepItems {
+		stepModels[i] = models.CurrentStepRecipeModel{
+			NumStep:     step.NumStep,
+			Ingredients: step.Ingredients,
+			Equipment:   step.Equipment,
+		}
+	}
+
+	return stepModels, nil
+}
+
+// BuildPrepChecklist собирает ингредиенты рецепта и ингредиенты и оборудование всех шагов
+// в один список без повторов. Одинаковые позиции сопоставляются по id, а если его нет - по названию.
+func BuildPrepChecklist(ingredients json.RawMessage,
+	steps []models.CurrentStepRecipeModel) ([]models.PrepItemModel, error) {
+	checklist := prepChecklist{
+		items:  make([]models.PrepItemModel, 0),
+		byID:   make(map[string][]int),
+		byName: make(map[string]string),
+	}
+
+	if len(ingredients) > 0 && string(ingredients) != "null" {
+		var recipeIngredients []prepIngredient
+
+		if err := json.Unmarshal(ingredients, &recipeIngredients); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal recipe ingredients: %w", err)
+		}
+
+		for _, ingredient := range recipeIngredients {
+			key, ok := checklist.find(models.PrepKindIngredient, ingredient.ID, ingredient.Name)
+			if !ok {
+				key = prepItemID(models.PrepKindIngredient, ingredient.ID, ingredient.Name)
+			}
+
+			indexes := checklist.byID[key]
+
+			sameUnit := slices.IndexFunc(indexes, func(i int) bool {
+				return normalizePrepName(checklist.items[i].Unit) == normalizePrepName(ingredient.Unit)
+			})
+			if sameUnit >= 0 {
+				checklist.items[indexes[sameUnit]].Amount += ingredient.Amount
+				continue
+			}
+
+			checklist.add(key, models.PrepItemModel{
+				Kind:   models.PrepKindIngredient,
+				Name:   ingredient.Name,
+				Image:  ingredient.Image,
+				Amount: ingredient.Amount,
+				Unit:   ingredient.Unit,
+			}, ingredient.Name)
+		}
+	}
+
+	for _, step := range steps {
+		if err := checklist.addStepItems(models.PrepKindIngredient, step.NumStep, step.Ingredients); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ingredients of step %d: %w", step.NumStep, err)
+		}
+
+		if err := checklist.addStepItems(models.PrepKindEquipment, step.NumStep, step.Equipment); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal equipment of step %d: %w", step.NumStep, err)
+		}
+	}
+
+	return checklist.items, nil
+}
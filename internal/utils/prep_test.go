@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+func TestBuildPrepChecklist(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingredients string
+		steps       []models.CurrentStepRecipeModel
+		want        []models.PrepItemModel
+	}{
+		{
+			name: "same unit amounts are summed",
+			ingredients: `[{"id":1,"name":"мука","amount":200,"unit":"г"},` +
+				`{"id":1,"name":"мука","amount":50,"unit":"г"}]`,
+			want: []models.PrepItemModel{
+				{ID: "ingredient:1", Kind: models.PrepKindIngredient, Name: "мука", Amount: 250, Unit: "г"},
+			},
+		},
+		{
+			name: "different units are kept as separate items",
+			ingredients: `[{"id":1,"name":"мука","amount":200,"unit":"г"},` +
+				`{"id":1,"name":"мука","amount":2,"unit":"ст.л."}]`,
+			steps: []models.CurrentStepRecipeModel{
+				{NumStep: 1, Ingredients: json.RawMessage(`[{"id":1,"name":"мука"}]`)},
+			},
+			want: []models.PrepItemModel{
+				{ID: "ingredient:1", Kind: models.PrepKindIngredient, Name: "мука", Amount: 200, Unit: "г",
+					Steps: []int{1}},
+				{ID: "ingredient:1:ст.л.", Kind: models.PrepKindIngredient, Name: "мука", Amount: 2, Unit: "ст.л.",
+					Steps: []int{1}},
+			},
+		},
+		{
+			name:        "items without id are matched by name",
+			ingredients: `[{"name":"Соль","amount":1,"unit":"ч.л."}]`,
+			steps: []models.CurrentStepRecipeModel{
+				{
+					NumStep:     2,
+					Ingredients: json.RawMessage(`[{"name":"соль"}]`),
+					Equipment:   json.RawMessage(`[{"id":7,"name":"pan","localizedName":"сковорода"}]`),
+				},
+			},
+			want: []models.PrepItemModel{
+				{ID: "ingredient:соль", Kind: models.PrepKindIngredient, Name: "Соль", Amount: 1, Unit: "ч.л.",
+					Steps: []int{2}},
+				{ID: "equipment:7", Kind: models.PrepKindEquipment, Name: "сковорода", Steps: []int{2}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := BuildPrepChecklist(json.RawMessage(tt.ingredients), tt.steps)
+			if err != nil {
+				t.Fatalf("BuildPrepChecklist() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildPrepChecklist() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPrepChecklistInvalidJSON(t *testing.T) {
+	tests := []struct {
+		name        string
+		ingredients string
+		steps       []models.CurrentStepRecipeModel
+	}{
+		{
+			name:        "recipe ingredients",
+			ingredients: `{`,
+		},
+		{
+			name:  "step ingredients",
+			steps: []models.CurrentStepRecipeModel{{NumStep: 1, Ingredients: json.RawMessage(`{"id":1}`)}},
+		},
+		{
+			name:  "step equipment",
+			steps: []models.CurrentStepRecipeModel{{NumStep: 1, Equipment: json.RawMessage(`"pan"`)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := BuildPrepChecklist(json.RawMessage(tt.ingredients), tt.steps); err == nil {
+				t.Error("BuildPrepChecklist() error = nil, want error")
+			}
+		})
+	}
+}
@@ -0,0 +1,391 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+const (
+	// Шаги считаются одним и тем же измененным шагом, если совпадает хотя бы половина слов.
+	stepMatchThreshold = 0.5
+	amountEpsilon      = 1e-9
+)
+
+type stepMove int8
+
+const (
+	stepMoveMatch stepMove = iota
+	stepMoveRemove
+	stepMoveInsert
+)
+
+type diffStep struct {
+	Number int    `json:"number"`
+	Step   string `json:"step"`
+}
+
+// BuildRecipeDiff сравнивает две версии рецепта: ингредиенты сопоставляются по названию, шаги - по
+// похожести текста с сохранением порядка, так что вставка шага в середину не делает измененными все следующие.
+func BuildRecipeDiff(from models.RecipeModel, to models.RecipeModel) (models.RecipeDiffModel, error) {
+	diff := models.RecipeDiffModel{
+		RecipeID:    from.ID,
+		FromVersion: from.Version,
+		ToVersion:   to.Version,
+	}
+
+	if from.Name != to.Name {
+		diff.Name = &models.StringChangeModel{From: from.Name, To: to.Name}
+	}
+
+	if from.ServingsNum != to.ServingsNum {
+		diff.Servings = &models.IntChangeModel{From: from.ServingsNum, To: to.ServingsNum}
+	}
+
+	if from.CookingTime != to.CookingTime {
+		diff.ReadyInMinutes = &models.IntChangeModel{From: from.CookingTime, To: to.CookingTime}
+	}
+
+	var err error
+
+	if diff.Diets, err = diffStringLists(from.Diets, to.Diets); err != nil {
+		return models.RecipeDiffModel{}, fmt.Errorf("failed to diff diets: %w", err)
+	}
+
+	if diff.DishTypes, err = diffStringLists(from.DishTypes, to.DishTypes); err != nil {
+		return models.RecipeDiffModel{}, fmt.Errorf("failed to diff dish types: %w", err)
+	}
+
+	if err = diffIngredients(&diff, from.Ingredients, to.Ingredients); err != nil {
+		return models.RecipeDiffModel{}, fmt.Errorf("failed to diff ingredients: %w", err)
+	}
+
+	if err = diffSteps(&diff, from.Steps, to.Steps); err != nil {
+		return models.RecipeDiffModel{}, fmt.Errorf("failed to diff steps: %w", err)
+	}
+
+	return diff, nil
+}
+
+func isEmptyJSON(raw []byte) bool {
+	trimmed := strings.TrimSpace(string(raw))
+	return trimmed == "" || trimmed == "null"
+}
+
+func diffStringLists(from string, to string) (models.ListChangeModel, error) {
+	var fromItems, toItems []string
+
+	if !isEmptyJSON([]byte(from)) {
+		if err := json.Unmarshal([]byte(from), &fromItems); err != nil {
+			return models.ListChangeModel{}, err
+		}
+	}
+
+	if !isEmptyJSON([]byte(to)) {
+		if err := json.Unmarshal([]byte(to), &toItems); err != nil {
+			return models.ListChangeModel{}, err
+		}
+	}
+
+	return models.ListChangeModel{
+		Added:   subtractNames(toItems, fromItems),
+		Removed: subtractNames(fromItems, toItems),
+	}, nil
+}
+
+func subtractNames(items []string, exclude []string) []string {
+	excluded := make(map[string]struct{}, len(exclude))
+	for _, item := range exclude {
+		excluded[normalizePrepName(item)] = struct{}{}
+	}
+
+	var result []string
+
+	for _, item := range items {
+		if _, ok := excluded[normalizePrepName(item)]; !ok {
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
+
+func parseDiffIngredients(raw []byte) ([]prepIngredient, error) {
+	if isEmptyJSON(raw) {
+		return nil, nil
+	}
+
+	var ingredients []prepIngredient
+
+	if err := json.Unmarshal(raw, &ingredients); err != nil {
+		return nil, err
+	}
+
+	return ingredients, nil
+}
+
+// diffIngredients сопоставляет ингредиенты по названию. Одноименные ингредиенты сопоставляются по порядку.
+func diffIngredients(diff *models.RecipeDiffModel, fromRaw []byte, toRaw []byte) error {
+	fromIngredients, err := parseDiffIngredients(fromRaw)
+	if err != nil {
+		return err
+	}
+
+	toIngredients, err := parseDiffIngredients(toRaw)
+	if err != nil {
+		return err
+	}
+
+	toByName := make(map[string][]int, len(toIngredients))
+	for i, ingredient := range toIngredients {
+		name := normalizePrepName(ingredient.Name)
+		toByName[name] = append(toByName[name], i)
+	}
+
+	matched := make([]bool, len(toIngredients))
+
+	for _, fromIngredient := range fromIngredients {
+		name := normalizePrepName(fromIngredient.Name)
+
+		candidates := toByName[name]
+		if len(candidates) == 0 {
+			diff.RemovedIngredients = append(diff.RemovedIngredients, models.IngredientDiffModel{
+				Name:       fromIngredient.Name,
+				FromAmount: fromIngredient.Amount,
+				FromUnit:   fromIngredient.Unit,
+			})
+			continue
+		}
+
+		toIdx := candidates[0]
+		toByName[name] = candidates[1:]
+		matched[toIdx] = true
+
+		toIngredient := toIngredients[toIdx]
+
+		if math.Abs(fromIngredient.Amount-toIngredient.Amount) > amountEpsilon ||
+			normalizePrepName(fromIngredient.Unit) != normalizePrepName(toIngredient.Unit) {
+			diff.ChangedIngredients = append(diff.ChangedIngredients, models.IngredientDiffModel{
+				Name:       toIngredient.Name,
+				FromAmount: fromIngredient.Amount,
+				FromUnit:   fromIngredient.Unit,
+				ToAmount:   toIngredient.Amount,
+				ToUnit:     toIngredient.Unit,
+			})
+		}
+	}
+
+	for i, toIngredient := range toIngredients {
+		if !matched[i] {
+			diff.AddedIngredients = append(diff.AddedIngredients, models.IngredientDiffModel{
+				Name:     toIngredient.Name,
+				ToAmount: toIngredient.Amount,
+				ToUnit:   toIngredient.Unit,
+			})
+		}
+	}
+
+	return nil
+}
+
+func parseDiffSteps(raw string) ([]diffStep, error) {
+	if isEmptyJSON([]byte(raw)) {
+		return nil, nil
+	}
+
+	var steps []diffStep
+
+	if err := json.Unmarshal([]byte(raw), &steps); err != nil {
+		return nil, err
+	}
+
+	for i := range steps {
+		if steps[i].Number <= 0 {
+			steps[i].Number = i + 1
+		}
+	}
+
+	return steps, nil
+}
+
+// diffSteps выравнивает шаги двух версий: ищет сопоставление с сохранением порядка, в котором
+// суммарная похожесть сопоставленных шагов максимальна, а пары похожи не меньше чем на stepMatchThreshold.
+func diffSteps(diff *models.RecipeDiffModel, fromRaw string, toRaw string) error {
+	fromSteps, err := parseDiffSteps(fromRaw)
+	if err != nil {
+		return err
+	}
+
+	toSteps, err := parseDiffSteps(toRaw)
+	if err != nil {
+		return err
+	}
+
+	n, m := len(fromSteps), len(toSteps)
+
+	fromWords := make([][]string, n)
+	for i, step := range fromSteps {
+		fromWords[i] = strings.Fields(step.Step)
+	}
+
+	toWords := make([][]string, m)
+	for j, step := range toSteps {
+		toWords[j] = strings.Fields(step.Step)
+	}
+
+	// Выбранный ход запоминается при заполнении таблицы: при восстановлении пути сравнивать суммы float
+	// на равенство нельзя, они зависят от порядка сложения.
+	score := make([][]float64, n+1)
+	moves := make([][]stepMove, n+1)
+	score[0] = make([]float64, m+1)
+	moves[0] = make([]stepMove, m+1)
+
+	for j := 1; j <= m; j++ {
+		moves[0][j] = stepMoveInsert
+	}
+
+	for i := 0; i < n; i++ {
+		score[i+1] = make([]float64, m+1)
+		moves[i+1] = make([]stepMove, m+1)
+		moves[i+1][0] = stepMoveRemove
+
+		for j := 0; j < m; j++ {
+			best, move := math.Inf(-1), stepMoveMatch
+			if similarity := wordsSimilarity(fromWords[i], toWords[j]); similarity >= stepMatchThreshold {
+				best = score[i][j] + similarity
+			}
+
+			if score[i][j+1] > best {
+				best, move = score[i][j+1], stepMoveRemove
+			}
+
+			if score[i+1][j] > best {
+				best, move = score[i+1][j], stepMoveInsert
+			}
+
+			score[i+1][j+1], moves[i+1][j+1] = best, move
+		}
+	}
+
+	var removed, inserted, edited []models.StepDiffModel
+
+	i, j := n, m
+	for i > 0 || j > 0 {
+		switch moves[i][j] {
+		case stepMoveMatch:
+			if strings.Join(fromWords[i-1], " ") != strings.Join(toWords[j-1], " ") {
+				edited = append(edited, models.StepDiffModel{
+					FromNumber: fromSteps[i-1].Number,
+					ToNumber:   toSteps[j-1].Number,
+					FromStep:   fromSteps[i-1].Step,
+					ToStep:     toSteps[j-1].Step,
+					TextDiff:   DiffWords(fromWords[i-1], toWords[j-1]),
+				})
+			}
+			i--
+			j--
+		case stepMoveRemove:
+			removed = append(removed, models.StepDiffModel{
+				FromNumber: fromSteps[i-1].Number,
+				FromStep:   fromSteps[i-1].Step,
+			})
+			i--
+		default:
+			inserted = append(inserted, models.StepDiffModel{
+				ToNumber: toSteps[j-1].Number,
+				ToStep:   toSteps[j-1].Step,
+			})
+			j--
+		}
+	}
+
+	diff.RemovedSteps = reverseStepDiffs(removed)
+	diff.InsertedSteps = reverseStepDiffs(inserted)
+	diff.EditedSteps = reverseStepDiffs(edited)
+
+	return nil
+}
+
+func reverseStepDiffs(steps []models.StepDiffModel) []models.StepDiffModel {
+	for left, right := 0, len(steps)-1; left < right; left, right = left+1, right-1 {
+		steps[left], steps[right] = steps[right], steps[left]
+	}
+
+	return steps
+}
+
+// wordsLCS возвращает таблицу длин наибольших общих подпоследовательностей суффиксов from и to.
+func wordsLCS(from []string, to []string, equal func(a, b string) bool) [][]int {
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if equal(from[i], to[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	return lcs
+}
+
+func wordsSimilarity(from []string, to []string) float64 {
+	if len(from)+len(to) == 0 {
+		return 1
+	}
+
+	lcs := wordsLCS(from, to, func(a, b string) bool {
+		return strings.EqualFold(strings.Trim(a, ".,;:!?"), strings.Trim(b, ".,;:!?"))
+	})
+
+	return 2 * float64(lcs[0][0]) / float64(len(from)+len(to))
+}
+
+// DiffWords строит пословный diff двух текстов. Соседние слова с одной операцией склеиваются через пробел.
+func DiffWords(from []string, to []string) []models.TextDiffOpModel {
+	lcs := wordsLCS(from, to, func(a, b string) bool { return a == b })
+
+	var ops []models.TextDiffOpModel
+
+	push := func(op string, word string) {
+		if len(ops) > 0 && ops[len(ops)-1].Op == op {
+			ops[len(ops)-1].Text += " " + word
+			return
+		}
+		ops = append(ops, models.TextDiffOpModel{Op: op, Text: word})
+	}
+
+	i, j := 0, 0
+	for i < len(from) && j < len(to) {
+		switch {
+		case from[i] == to[j]:
+			push(models.TextDiffEqual, from[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			push(models.TextDiffDelete, from[i])
+			i++
+		default:
+			push(models.TextDiffInsert, to[j])
+			j++
+		}
+	}
+
+	for ; i < len(from); i++ {
+		push(models.TextDiffDelete, from[i])
+	}
+
+	for ; j < len(to); j++ {
+		push(models.TextDiffInsert, to[j])
+	}
+
+	return ops
+}
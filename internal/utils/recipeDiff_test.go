@@ -0,0 +1,288 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+const (
+	stepBeat  = "Взбейте яйца с молоком."
+	stepHeat  = "Разогрейте сковороду с маслом."
+	stepFry   = "Вылейте смесь и жарьте 5 минут."
+	stepSalt  = "Посолите и поперчите смесь."
+	stepGreen = "Нарежьте зелень."
+)
+
+// stepsJSON собирает шаги рецепта в том виде, в котором они хранятся в версии.
+func stepsJSON(steps ...string) string {
+	items := make([]diffStep, 0, len(steps))
+	for i, step := range steps {
+		items = append(items, diffStep{Number: i + 1, Step: step})
+	}
+
+	raw, _ := json.Marshal(items)
+
+	return string(raw)
+}
+
+func TestDiffSteps(t *testing.T) {
+	tests := []struct {
+		name         string
+		from         string
+		to           string
+		wantRemoved  []models.StepDiffModel
+		wantInserted []models.StepDiffModel
+		wantEdited   []models.StepDiffModel
+	}{
+		{
+			name: "same steps",
+			from: stepsJSON(stepBeat, stepHeat, stepFry),
+			to:   stepsJSON(stepBeat, stepHeat, stepFry),
+		},
+		{
+			name:         "step inserted in the middle",
+			from:         stepsJSON(stepBeat, stepHeat, stepFry),
+			to:           stepsJSON(stepBeat, stepSalt, stepHeat, stepFry),
+			wantInserted: []models.StepDiffModel{{ToNumber: 2, ToStep: stepSalt}},
+		},
+		{
+			name:        "first step removed",
+			from:        stepsJSON(stepBeat, stepHeat, stepFry),
+			to:          stepsJSON(stepHeat, stepFry),
+			wantRemoved: []models.StepDiffModel{{FromNumber: 1, FromStep: stepBeat}},
+		},
+		{
+			name: "step edited",
+			from: stepsJSON(stepBeat, stepHeat, stepFry),
+			to:   stepsJSON(stepBeat, stepHeat, "Вылейте смесь и жарьте 7 минут."),
+			wantEdited: []models.StepDiffModel{{
+				FromNumber: 3,
+				ToNumber:   3,
+				FromStep:   stepFry,
+				ToStep:     "Вылейте смесь и жарьте 7 минут.",
+				TextDiff: []models.TextDiffOpModel{
+					{Op: models.TextDiffEqual, Text: "Вылейте смесь и жарьте"},
+					{Op: models.TextDiffDelete, Text: "5"},
+					{Op: models.TextDiffInsert, Text: "7"},
+					{Op: models.TextDiffEqual, Text: "минут."},
+				},
+			}},
+		},
+		{
+			name:         "dissimilar step is removed and inserted",
+			from:         stepsJSON(stepBeat, stepHeat, stepFry),
+			to:           stepsJSON(stepBeat, stepGreen, stepFry),
+			wantRemoved:  []models.StepDiffModel{{FromNumber: 2, FromStep: stepHeat}},
+			wantInserted: []models.StepDiffModel{{ToNumber: 2, ToStep: stepGreen}},
+		},
+		{
+			name:         "inserted, removed and edited steps",
+			from:         stepsJSON(stepBeat, stepHeat, stepFry),
+			to:           stepsJSON(stepSalt, stepBeat, "Вылейте смесь и жарьте 5 минут под крышкой."),
+			wantRemoved:  []models.StepDiffModel{{FromNumber: 2, FromStep: stepHeat}},
+			wantInserted: []models.StepDiffModel{{ToNumber: 1, ToStep: stepSalt}},
+			wantEdited: []models.StepDiffModel{{
+				FromNumber: 3,
+				ToNumber:   3,
+				FromStep:   stepFry,
+				ToStep:     "Вылейте смесь и жарьте 5 минут под крышкой.",
+				TextDiff: []models.TextDiffOpModel{
+					{Op: models.TextDiffEqual, Text: "Вылейте смесь и жарьте 5"},
+					{Op: models.TextDiffDelete, Text: "минут."},
+					{Op: models.TextDiffInsert, Text: "минут под крышкой."},
+				},
+			}},
+		},
+		{
+			name:         "steps without previous version",
+			from:         "null",
+			to:           `[{"step":"` + stepBeat + `"},{"step":"` + stepFry + `"}]`,
+			wantInserted: []models.StepDiffModel{{ToNumber: 1, ToStep: stepBeat}, {ToNumber: 2, ToStep: stepFry}},
+		},
+		{
+			// При равной похожести с последующим шагом сопоставляется последний из повторов.
+			name:        "repeated step removed",
+			from:        stepsJSON(stepBeat, stepBeat, stepFry),
+			to:          stepsJSON(stepBeat, stepFry),
+			wantRemoved: []models.StepDiffModel{{FromNumber: 1, FromStep: stepBeat}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var diff models.RecipeDiffModel
+
+			if err := diffSteps(&diff, tt.from, tt.to); err != nil {
+				t.Fatalf("diffSteps() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(diff.RemovedSteps, tt.wantRemoved) {
+				t.Errorf("diffSteps() removed = %+v, want %+v", diff.RemovedSteps, tt.wantRemoved)
+			}
+
+			if !reflect.DeepEqual(diff.InsertedSteps, tt.wantInserted) {
+				t.Errorf("diffSteps() inserted = %+v, want %+v", diff.InsertedSteps, tt.wantInserted)
+			}
+
+			if !reflect.DeepEqual(diff.EditedSteps, tt.wantEdited) {
+				t.Errorf("diffSteps() edited = %+v, want %+v", diff.EditedSteps, tt.wantEdited)
+			}
+		})
+	}
+}
+
+func TestDiffStepsInvalidJSON(t *testing.T) {
+	var diff models.RecipeDiffModel
+
+	if err := diffSteps(&diff, stepsJSON(stepBeat), `{"step":1}`); err == nil {
+		t.Error("diffSteps() error = nil, want error")
+	}
+}
+
+func TestDiffWords(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want []models.TextDiffOpModel
+	}{
+		{
+			name: "equal texts",
+			from: "жарьте 5 минут",
+			to:   "жарьте 5 минут",
+			want: []models.TextDiffOpModel{{Op: models.TextDiffEqual, Text: "жарьте 5 минут"}},
+		},
+		{
+			name: "words inserted",
+			from: "жарьте минут",
+			to:   "жарьте 5 минут",
+			want: []models.TextDiffOpModel{
+				{Op: models.TextDiffEqual, Text: "жарьте"},
+				{Op: models.TextDiffInsert, Text: "5"},
+				{Op: models.TextDiffEqual, Text: "минут"},
+			},
+		},
+		{
+			name: "words removed at the end",
+			from: "жарьте 5 минут под крышкой",
+			to:   "жарьте 5 минут",
+			want: []models.TextDiffOpModel{
+				{Op: models.TextDiffEqual, Text: "жарьте 5 минут"},
+				{Op: models.TextDiffDelete, Text: "под крышкой"},
+			},
+		},
+		{
+			name: "text replaced",
+			from: "посолите",
+			to:   "поперчите смесь",
+			want: []models.TextDiffOpModel{
+				{Op: models.TextDiffDelete, Text: "посолите"},
+				{Op: models.TextDiffInsert, Text: "поперчите смесь"},
+			},
+		},
+		{
+			name: "empty texts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DiffWords(strings.Fields(tt.from), strings.Fields(tt.to))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DiffWords() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffIngredients(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        string
+		to          string
+		wantAdded   []models.IngredientDiffModel
+		wantRemoved []models.IngredientDiffModel
+		wantChanged []models.IngredientDiffModel
+	}{
+		{
+			name: "same ingredients with different case and spaces",
+			from: `[{"name":"Мука","amount":200,"unit":"г"}]`,
+			to:   `[{"name":" мука ","amount":200,"unit":"Г"}]`,
+		},
+		{
+			name: "amount changed",
+			from: `[{"name":"мука","amount":200,"unit":"г"}]`,
+			to:   `[{"name":"мука","amount":250,"unit":"г"}]`,
+			wantChanged: []models.IngredientDiffModel{
+				{Name: "мука", FromAmount: 200, FromUnit: "г", ToAmount: 250, ToUnit: "г"},
+			},
+		},
+		{
+			name: "unit changed",
+			from: `[{"name":"мука","amount":2,"unit":"ст.л."}]`,
+			to:   `[{"name":"мука","amount":2,"unit":"стакан"}]`,
+			wantChanged: []models.IngredientDiffModel{
+				{Name: "мука", FromAmount: 2, FromUnit: "ст.л.", ToAmount: 2, ToUnit: "стакан"},
+			},
+		},
+		{
+			name:        "added and removed",
+			from:        `[{"name":"соль","amount":1,"unit":"ч.л."}]`,
+			to:          `[{"name":"перец","amount":0.5,"unit":"ч.л."}]`,
+			wantAdded:   []models.IngredientDiffModel{{Name: "перец", ToAmount: 0.5, ToUnit: "ч.л."}},
+			wantRemoved: []models.IngredientDiffModel{{Name: "соль", FromAmount: 1, FromUnit: "ч.л."}},
+		},
+		{
+			name: "duplicate ingredients are matched in order",
+			from: `[{"name":"сахар","amount":100,"unit":"г"},{"name":"сахар","amount":1,"unit":"ст.л."}]`,
+			to:   `[{"name":"сахар","amount":100,"unit":"г"},{"name":"сахар","amount":2,"unit":"ст.л."}]`,
+			wantChanged: []models.IngredientDiffModel{
+				{Name: "сахар", FromAmount: 1, FromUnit: "ст.л.", ToAmount: 2, ToUnit: "ст.л."},
+			},
+		},
+		{
+			name:        "duplicate ingredient removed",
+			from:        `[{"name":"сахар","amount":100,"unit":"г"},{"name":"сахар","amount":1,"unit":"ст.л."}]`,
+			to:          `[{"name":"сахар","amount":100,"unit":"г"}]`,
+			wantRemoved: []models.IngredientDiffModel{{Name: "сахар", FromAmount: 1, FromUnit: "ст.л."}},
+		},
+		{
+			name:      "duplicate ingredient added",
+			from:      `[{"name":"сахар","amount":100,"unit":"г"}]`,
+			to:        `[{"name":"сахар","amount":100,"unit":"г"},{"name":"сахар","amount":1,"unit":"ст.л."}]`,
+			wantAdded: []models.IngredientDiffModel{{Name: "сахар", ToAmount: 1, ToUnit: "ст.л."}},
+		},
+		{
+			name:      "no previous ingredients",
+			from:      "null",
+			to:        `[{"name":"яйцо","amount":2}]`,
+			wantAdded: []models.IngredientDiffModel{{Name: "яйцо", ToAmount: 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var diff models.RecipeDiffModel
+
+			if err := diffIngredients(&diff, []byte(tt.from), []byte(tt.to)); err != nil {
+				t.Fatalf("diffIngredients() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(diff.AddedIngredients, tt.wantAdded) {
+				t.Errorf("diffIngredients() added = %+v, want %+v", diff.AddedIngredients, tt.wantAdded)
+			}
+
+			if !reflect.DeepEqual(diff.RemovedIngredients, tt.wantRemoved) {
+				t.Errorf("diffIngredients() removed = %+v, want %+v", diff.RemovedIngredients, tt.wantRemoved)
+			}
+
+			if !reflect.DeepEqual(diff.ChangedIngredients, tt.wantChanged) {
+				t.Errorf("diffIngredients() changed = %+v, want %+v", diff.ChangedIngredients, tt.wantChanged)
+			}
+		})
+	}
+}
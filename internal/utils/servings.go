@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+func normalizeUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	unit = strings.ReplaceAll(unit, ".", "")
+	return strings.ReplaceAll(unit, " ", "")
+}
+
+func isPieceUnit(unit string) bool {
+	switch normalizeUnit(unit) {
+	case "", "шт", "штук", "штука", "штуки", "зубчик", "зубчика", "зубчиков",
+		"piece", "pieces", "pc", "pcs", "large", "medium", "small", "clove", "cloves", "whole",
+		"serving", "servings":
+		return true
+	}
+	return false
+}
+
+func isSpoonUnit(unit string) bool {
+	unit = normalizeUnit(unit)
+
+	switch unit {
+	case "чл", "стл", "t", "tsp", "tsps", "tbs", "tbsp", "tbsps",
+		"teaspoon", "teaspoons", "tablespoon", "tablespoons":
+		return true
+	}
+
+	return strings.Contains(unit, "ложк")
+}
+
+// ScaleIngredientAmount пересчитывает количество ингредиента на другое число порций.
+// Штуки округляются до целых (меньше одной - до половинок), ложки - до четвертей,
+// граммы и миллилитры - в зависимости от величины.
+func ScaleIngredientAmount(amount float64, unit string, factor float64) float64 {
+	scaled := amount * factor
+
+	if scaled <= 0 {
+		return 0
+	}
+
+	switch {
+	case isPieceUnit(unit):
+		if scaled < 1 {
+			return math.Max(math.Round(scaled*2)/2, 0.5)
+		}
+		return math.Round(scaled)
+	case isSpoonUnit(unit):
+		return math.Max(math.Round(scaled*4)/4, 0.25)
+	case scaled >= 100:
+		return math.Round(scaled/5) * 5
+	case scaled >= 10:
+		return math.Round(scaled)
+	default:
+		return math.Max(math.Round(scaled*10)/10, 0.1)
+	}
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+)
+
+// StepSpeechText готовит текст шага для озвучки.
+func StepSpeechText(step dto.CurrentStepRecipeDto) string {
+	text := strings.TrimSpace(step.Step)
+	if text == "" {
+		return ""
+	}
+
+	if step.NumStep == 0 {
+		return text
+	}
+
+	return fmt.Sprintf("Шаг %d. %s", step.NumStep, text)
+}
+
+// TimerSpeechText готовит объявление таймера. Остаток больше минуты округляется вверх до минут,
+// чтобы одинаковые объявления брались из кеша озвучки.
+func TimerSpeechText(timer dto.TimerRecipeDto) string {
+	name := "Таймер"
+	switch {
+	case timer.Label != "":
+		name = fmt.Sprintf("Таймер «%s»", timer.Label)
+	case timer.StepNum > 0:
+		name = fmt.Sprintf("Таймер шага %d", timer.StepNum)
+	}
+
+	seconds, ok := StepLengthToSeconds(timer.Length)
+	if !ok || seconds <= 0 {
+		return name + " закончился."
+	}
+
+	if seconds >= 60 {
+		seconds = (seconds + 59) / 60 * 60
+	}
+
+	if timer.IsPaused {
+		return fmt.Sprintf("%s на паузе, осталось %s.", name, formatDurationRus(seconds))
+	}
+
+	return fmt.Sprintf("%s: осталось %s.", name, formatDurationRus(seconds))
+}
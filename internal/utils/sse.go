@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func PrepareSSEResponse(w http.ResponseWriter) (*http.ResponseController, error) {
+	rc := http.NewResponseController(w)
+
+	// У сервера выставлен WriteTimeout, для стрима событий снимаем дедлайн.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	return rc, rc.Flush()
+}
+
+func WriteSSEEvent(w http.ResponseWriter, rc *http.ResponseController, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	if _, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
+
+func WriteSSEKeepAlive(w http.ResponseWriter, rc *http.ResponseController) error {
+	if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
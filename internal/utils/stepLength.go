@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+)
+
+type stepLength struct {
+	Number float64 `json:"number"`
+	Unit   string  `json:"unit"`
+}
+
+// StepLengthToSeconds переводит длительность шага вида {"number":5,"unit":"minutes"} в секунды.
+func StepLengthToSeconds(length json.RawMessage) (int, bool) {
+	if len(length) == 0 {
+		return 0, false
+	}
+
+	var stepLen stepLength
+
+	if err := json.Unmarshal(length, &stepLen); err != nil || stepLen.Number <= 0 {
+		return 0, false
+	}
+
+	var multiplier float64
+
+	switch strings.ToLower(strings.TrimSpace(stepLen.Unit)) {
+	case "seconds", "second", "sec", "secs", "s", "сек", "секунда", "секунды", "секунд":
+		multiplier = 1
+	case "minutes", "minute", "min", "mins", "m", "мин", "минута", "минуты", "минут":
+		multiplier = 60
+	case "hours", "hour", "hr", "hrs", "h", "ч", "час", "часа", "часов":
+		multiplier = 3600
+	default:
+		return 0, false
+	}
+
+	return int(math.Round(stepLen.Number * multiplier)), true
+}
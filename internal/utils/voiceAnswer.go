@@ -0,0 +1,270 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Olegsandrik/Exponenta/internal/delivery/dto"
+)
+
+const minIngredientStemLen = 3
+
+type answerIngredient struct {
+	Name          string  `json:"name"`
+	LocalizedName string  `json:"localizedName"`
+	Amount        float64 `json:"amount"`
+	Unit          string  `json:"unit"`
+}
+
+func (i answerIngredient) title() string {
+	if i.LocalizedName != "" {
+		return i.LocalizedName
+	}
+	return i.Name
+}
+
+func parseAnswerIngredients(raw json.RawMessage) []answerIngredient {
+	var ingredients []answerIngredient
+
+	if len(raw) == 0 || json.Unmarshal(raw, &ingredients) != nil {
+		return nil
+	}
+
+	return ingredients
+}
+
+func ingredientStem(word string) string {
+	runes := []rune(word)
+
+	cut := len(runes) - 1
+	if len(runes) > 4 {
+		cut = len(runes) - 2
+	}
+	if cut < minIngredientStemLen {
+		cut = minIngredientStemLen
+	}
+	if cut > len(runes) {
+		cut = len(runes)
+	}
+
+	return string(runes[:cut])
+}
+
+// ingredientMatchScore считает, сколько слов из названия ингредиента упомянуто в вопросе.
+func ingredientMatchScore(tokens []string, ingredient answerIngredient) int {
+	score := 0
+
+	for _, name := range []string{ingredient.Name, ingredient.LocalizedName} {
+		nameScore := 0
+		for _, word := range tokenizeVoiceText(name) {
+			if utf8.RuneCountInString(word) < minIngredientStemLen {
+				continue
+			}
+
+			stem := ingredientStem(word)
+			for _, token := range tokens {
+				if strings.HasPrefix(token, stem) {
+					nameScore++
+					break
+				}
+			}
+		}
+
+		if nameScore > score {
+			score = nameScore
+		}
+	}
+
+	return score
+}
+
+func findAnswerIngredient(tokens []string, groups ...[]answerIngredient) (answerIngredient, bool) {
+	var (
+		best      answerIngredient
+		bestScore int
+	)
+
+	for _, ingredients := range groups {
+		for _, ingredient := range ingredients {
+			if score := ingredientMatchScore(tokens, ingredient); score > bestScore {
+				best, bestScore = ingredient, score
+			}
+		}
+	}
+
+	return best, bestScore > 0
+}
+
+// fillStepAmounts подставляет количество из ингредиентов рецепта: в шагах оно обычно не указано.
+func fillStepAmounts(stepIngredients []answerIngredient, recipeIngredients []answerIngredient) {
+	for i, ingredient := range stepIngredients {
+		if ingredient.Amount > 0 {
+			continue
+		}
+
+		tokens := tokenizeVoiceText(ingredient.Name + " " + ingredient.LocalizedName)
+		if recipeIngredient, ok := findAnswerIngredient(tokens, recipeIngredients); ok {
+			stepIngredients[i].Amount = recipeIngredient.Amount
+			stepIngredients[i].Unit = recipeIngredient.Unit
+		}
+	}
+}
+
+func formatAmount(amount float64) string {
+	return strings.ReplaceAll(strconv.FormatFloat(amount, 'f', -1, 64), ".", ",")
+}
+
+func formatIngredientAmount(ingredient answerIngredient) string {
+	if ingredient.Amount <= 0 {
+		return fmt.Sprintf("%s - количество в рецепте не указано", ingredient.title())
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%s - %s %s", ingredient.title(), formatAmount(ingredient.Amount),
+		ingredient.Unit))
+}
+
+func pluralRus(n int, one, few, many string) string {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return many
+	}
+
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	default:
+		return many
+	}
+}
+
+func formatDurationRus(seconds int) string {
+	minutes, seconds := seconds/60, seconds%60
+
+	parts := make([]string, 0, 2)
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", minutes, pluralRus(minutes, "минута", "минуты", "минут")))
+	}
+	if seconds > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", seconds, pluralRus(seconds, "секунда", "секунды", "секунд")))
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// findStepSentence возвращает первое предложение шага, подходящее под pattern.
+func findStepSentence(step string, pattern string) (string, bool) {
+	re := regexp.MustCompile(pattern)
+
+	for _, sentence := range regexp.MustCompile(`[.!?;]+`).Split(step, -1) {
+		sentence = strings.TrimSpace(sentence)
+		if sentence != "" && re.MatchString(strings.ToLower(sentence)) {
+			return sentence, true
+		}
+	}
+
+	return "", false
+}
+
+func hasAnyPrefix(tokens []string, prefixes ...string) bool {
+	for _, token := range tokens {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(token, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func answerTemperature(step string) string {
+	sentence, ok := findStepSentence(step, `\d+\s*(°|градус)|огн|духовк|нагре|разогре|температур`)
+	if !ok {
+		return "В этом шаге температура не указана."
+	}
+	return fmt.Sprintf("В рецепте сказано: %s.", sentence)
+}
+
+func answerTime(step dto.CurrentStepRecipeDto) string {
+	if seconds, ok := StepLengthToSeconds(step.Length); ok {
+		return fmt.Sprintf("Этот шаг занимает %s.", formatDurationRus(seconds))
+	}
+
+	sentence, ok := findStepSentence(step.Step, `\d+\s*(мин|сек|час)|минут|секунд|час`)
+	if !ok {
+		return "В этом шаге время не указано."
+	}
+	return fmt.Sprintf("В рецепте сказано: %s.", sentence)
+}
+
+func answerEquipment(step dto.CurrentStepRecipeDto) string {
+	equipment := parseAnswerIngredients(step.Equipment)
+
+	names := make([]string, 0, len(equipment))
+	for _, item := range equipment {
+		if item.title() != "" {
+			names = append(names, item.title())
+		}
+	}
+
+	if len(names) == 0 {
+		return "В этом шаге посуда и инструменты не указаны."
+	}
+	return fmt.Sprintf("Понадобится: %s.", strings.Join(names, ", "))
+}
+
+func answerStepIngredients(stepIngredients []answerIngredient) (string, bool) {
+	if len(stepIngredients) == 0 {
+		return "", false
+	}
+
+	amounts := make([]string, 0, len(stepIngredients))
+	for _, ingredient := range stepIngredients {
+		amounts = append(amounts, formatIngredientAmount(ingredient))
+	}
+
+	return fmt.Sprintf("На этом шаге нужно: %s.", strings.Join(amounts, ", ")), true
+}
+
+// AnswerStepQuestion отвечает на вопрос по текущему шагу только из данных сессии:
+// ингредиентов шага, ингредиентов рецепта с количеством и текста шага.
+func AnswerStepQuestion(question string, recipe dto.CurrentRecipeDto) string {
+	tokens := tokenizeVoiceText(question)
+	step := recipe.CurrentStep
+
+	stepIngredients := parseAnswerIngredients(step.Ingredients)
+	recipeIngredients := parseAnswerIngredients(recipe.Ingredients)
+	fillStepAmounts(stepIngredients, recipeIngredients)
+
+	switch {
+	case hasAnyPrefix(tokens, "температур", "градус", "огн", "огон", "духовк", "разогр", "нагре"):
+		return answerTemperature(step.Step)
+	case hasAnyPrefix(tokens, "долго", "времен", "время", "минут", "секунд", "час"):
+		return answerTime(step)
+	}
+
+	if ingredient, ok := findAnswerIngredient(tokens, stepIngredients, recipeIngredients); ok {
+		return formatIngredientAmount(ingredient) + "."
+	}
+
+	if hasAnyPrefix(tokens, "чем", "посуд", "сковород", "кастрюл", "инструмент", "оборудован", "миск", "форм") {
+		return answerEquipment(step)
+	}
+
+	if hasAnyPrefix(tokens, "сколько", "ингредиент", "продукт", "добав", "полож") {
+		if answer, ok := answerStepIngredients(stepIngredients); ok {
+			return answer
+		}
+	}
+
+	if step.Step == "" {
+		return "Не нашел ответа в рецепте."
+	}
+
+	return fmt.Sprintf("Не нашел ответа в рецепте. Текущий шаг: %s", step.Step)
+}
@@ -0,0 +1,291 @@
+package utils
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Olegsandrik/Exponenta/internal/usecase/models"
+)
+
+const (
+	voiceHalf    = 0.5
+	voiceQuarter = 0.25
+)
+
+var (
+	voiceDecimalCommaRegex = regexp.MustCompile(`(\d+),(\d+)`)
+	voiceNumberSuffixRegex = regexp.MustCompile(`(\d)([^\d.\s])`)
+)
+
+func tokenizeVoiceText(text string) []string {
+	text = strings.ToLower(strings.ReplaceAll(text, "ё", "е"))
+	text = voiceDecimalCommaRegex.ReplaceAllString(text, "$1.$2")
+	text = voiceNumberSuffixRegex.ReplaceAllString(text, "$1 $2")
+
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !(r == '.' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'а' && r <= 'я')
+	})
+}
+
+func cardinalNumber(word string) (float64, bool) {
+	switch word {
+	case "ноль", "нуль":
+		return 0, true
+	case "один", "одна", "одну", "одной", "одного", "одно":
+		return 1, true
+	case "два", "две", "двух", "пару", "пара", "парочку":
+		return 2, true
+	case "три", "трех":
+		return 3, true
+	case "четыре", "четырех":
+		return 4, true
+	case "пять", "пяти":
+		return 5, true
+	case "шесть", "шести":
+		return 6, true
+	case "семь", "семи":
+		return 7, true
+	case "восемь", "восьми":
+		return 8, true
+	case "девять", "девяти":
+		return 9, true
+	case "десять", "десяти":
+		return 10, true
+	case "одиннадцать":
+		return 11, true
+	case "двенадцать":
+		return 12, true
+	case "тринадцать":
+		return 13, true
+	case "четырнадцать":
+		return 14, true
+	case "пятнадцать":
+		return 15, true
+	case "шестнадцать":
+		return 16, true
+	case "семнадцать":
+		return 17, true
+	case "восемнадцать":
+		return 18, true
+	case "девятнадцать":
+		return 19, true
+	case "двадцать", "двадцати":
+		return 20, true
+	case "тридцать", "тридцати":
+		return 30, true
+	case "сорок", "сорока":
+		return 40, true
+	case "пятьдесят":
+		return 50, true
+	case "шестьдесят":
+		return 60, true
+	case "семьдесят":
+		return 70, true
+	case "восемьдесят":
+		return 80, true
+	case "девяносто":
+		return 90, true
+	case "сто", "ста":
+		return 100, true
+	case "полторы", "полтора":
+		return 1 + voiceHalf, true
+	case "пол", "половину", "половина":
+		return voiceHalf, true
+	case "четверть":
+		return voiceQuarter, true
+	}
+
+	if value, err := strconv.ParseFloat(strings.Trim(word, "."), 64); err == nil {
+		return value, true
+	}
+
+	return 0, false
+}
+
+func ordinalNumber(word string) (int, bool) {
+	stems := []string{"перв", "втор", "трет", "четверт", "пят", "шест", "седьм", "восьм", "девят", "десят"}
+
+	for i, stem := range stems {
+		if !strings.HasPrefix(word, stem) {
+			continue
+		}
+
+		switch strings.TrimPrefix(word, stem) {
+		case "ый", "ой", "ий", "ого", "ому", "ом", "ая", "ую", "его", "ему", "ем", "ья", "ью", "ье", "ьего",
+			"ьему", "ьем":
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
+
+func durationUnit(word string) (float64, bool) {
+	switch {
+	case word == "сек" || strings.HasPrefix(word, "секунд"):
+		return 1, true
+	case word == "мин" || strings.HasPrefix(word, "минут"):
+		return 60, true
+	case word == "ч" || word == "час" || word == "часа" || word == "часов" || word == "часу" ||
+		strings.HasPrefix(word, "часик"):
+		return 3600, true
+	}
+
+	return 0, false
+}
+
+// halfDurationUnit разбирает слитные формы вроде "полчаса" и "полминуты".
+func halfDurationUnit(word string) (float64, bool) {
+	if !strings.HasPrefix(word, "пол") || word == "пол" {
+		return 0, false
+	}
+
+	unit, ok := durationUnit(strings.TrimPrefix(word, "пол"))
+	if !ok {
+		return 0, false
+	}
+
+	return unit * voiceHalf, true
+}
+
+// parseVoiceDuration находит в тексте длительность ("на пять минут", "полторы минуты",
+// "1 час 20 минут", "пять с половиной минут") и возвращает ее в секундах.
+func parseVoiceDuration(tokens []string) int {
+	var (
+		total     float64
+		pending   float64
+		hasNumber bool
+	)
+
+	for i := 0; i < len(tokens); i++ {
+		word := tokens[i]
+
+		if word == "с" && i+1 < len(tokens) && strings.HasPrefix(tokens[i+1], "половин") && hasNumber {
+			pending += voiceHalf
+			i++
+			continue
+		}
+
+		if seconds, ok := halfDurationUnit(word); ok {
+			total += seconds
+			pending, hasNumber = 0, false
+			continue
+		}
+
+		if unit, ok := durationUnit(word); ok {
+			if !hasNumber {
+				pending = 1
+			}
+			total += pending * unit
+			pending, hasNumber = 0, false
+			continue
+		}
+
+		if value, ok := cardinalNumber(word); ok {
+			pending += value
+			hasNumber = true
+			continue
+		}
+
+		pending, hasNumber = 0, false
+	}
+
+	return int(math.Round(total))
+}
+
+func voiceNumber(word string) (int, bool) {
+	if value, ok := ordinalNumber(word); ok {
+		return value, true
+	}
+
+	value, ok := cardinalNumber(word)
+	if !ok || value < 1 || value != math.Trunc(value) {
+		return 0, false
+	}
+
+	return int(value), true
+}
+
+// parseVoiceStepNum находит номер шага рядом со словом "шаг": "шаг три", "к третьему шагу", "на 3 шаг".
+func parseVoiceStepNum(tokens []string) int {
+	for i, word := range tokens {
+		if !strings.HasPrefix(word, "шаг") {
+			continue
+		}
+
+		for j := i + 1; j < len(tokens) && j <= i+2; j++ {
+			if j+1 < len(tokens) {
+				if _, isDuration := durationUnit(tokens[j+1]); isDuration {
+					break
+				}
+			}
+			if stepNum, ok := voiceNumber(tokens[j]); ok {
+				return stepNum
+			}
+		}
+
+		for j := i - 1; j >= 0 && j >= i-2; j-- {
+			if stepNum, ok := voiceNumber(tokens[j]); ok {
+				return stepNum
+			}
+		}
+	}
+
+	return 0
+}
+
+func isVoiceLabelStop(word string) bool {
+	if _, ok := cardinalNumber(word); ok {
+		return true
+	}
+	if _, ok := durationUnit(word); ok {
+		return true
+	}
+	if _, ok := halfDurationUnit(word); ok {
+		return true
+	}
+
+	switch word {
+	case "на", "через", "таймер", "таймера", "пожалуйста", "и", "а", "этот", "эту", "это", "этого", "текущий",
+		"текущего", "номер":
+		return true
+	}
+
+	return strings.HasPrefix(word, "шаг")
+}
+
+// parseVoiceTimerLabel достает название таймера: "таймер для пасты", "таймер на макароны", "с названием соус".
+func parseVoiceTimerLabel(tokens []string) string {
+	for i, word := range tokens {
+		if word != "для" && word != "на" && word != "названием" {
+			continue
+		}
+
+		label := make([]string, 0)
+		for _, next := range tokens[i+1:] {
+			if isVoiceLabelStop(next) {
+				break
+			}
+			label = append(label, next)
+		}
+
+		if len(label) > 0 {
+			return strings.Join(label, " ")
+		}
+	}
+
+	return ""
+}
+
+// ExtractVoiceSlots разбирает из текста голосовой команды длительность таймера, номер шага и название таймера.
+func ExtractVoiceSlots(text string) models.VoiceSlotsModel {
+	tokens := tokenizeVoiceText(text)
+
+	return models.VoiceSlotsModel{
+		DurationSec: parseVoiceDuration(tokens),
+		StepNum:     parseVoiceStepNum(tokens),
+		Label:       parseVoiceTimerLabel(tokens),
+	}
+}